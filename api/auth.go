@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+)
+
+type authorizingKey struct{}
+
+// authenticate returns ctx carrying the principal of the credentials in
+// header, whose calls are then authorized. It fails for invalid
+// credentials, and for missing ones when authentication is required.
+// Without an authenticator, ctx is returned as it is.
+func authenticate(ctx context.Context, authenticator *auth.Authenticator, header http.Header) (context.Context, error) {
+	if authenticator == nil {
+		return ctx, nil
+	}
+	ctx = context.WithValue(ctx, authorizingKey{}, true)
+	principal, err := authenticator.Authenticate(header)
+	if err != nil {
+		logging.FromContext(ctx).Warn("authentication failed", "error", err.Error())
+		return ctx, unauthenticated("%v", err)
+	}
+	if principal == nil {
+		if authenticator.Required() {
+			return ctx, unauthenticated("credentials are required: send a bearer token or an %s header", auth.APIKeyHeader)
+		}
+		return ctx, nil
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// authorize fails unless the caller of ctx is allowed permission: with
+// ErrUnauthenticated for an anonymous caller and ErrForbidden for one
+// whose roles do not allow it. Callers served without an authenticator,
+// and direct callers of the resolvers such as hotelctl, are allowed
+// everything.
+func authorize(ctx context.Context, permission auth.Permission) error {
+	if authorizing, _ := ctx.Value(authorizingKey{}).(bool); !authorizing {
+		return nil
+	}
+	principal := auth.FromContext(ctx)
+	if principal.Can(permission) {
+		return nil
+	}
+	if principal == nil {
+		return unauthenticated("credentials are required for %s", permission)
+	}
+	return forbidden("the roles of %s do not allow %s", principal.Subject, permission)
+}
+
+// reservationOwner returns the guest whose reservations the caller of ctx
+// may access: every guest, as "", with permission all, or else the caller
+// itself with permission own.
+func reservationOwner(ctx context.Context, all auth.Permission, own auth.Permission) (string, error) {
+	if authorize(ctx, all) == nil {
+		return "", nil
+	}
+	if err := authorize(ctx, own); err != nil {
+		return "", err
+	}
+	return auth.FromContext(ctx).ID(), nil
+}
+
+// guestOf returns the guest the reservations made by the caller of ctx
+// belong to, the ID of its principal, or "" for an anonymous caller.
+func guestOf(ctx context.Context) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.ID()
+	}
+	return ""
+}
+
+// isPublicPath reports whether path is served without credentials.
+func isPublicPath(path string) bool {
+	return path == "/openapi.yaml"
+}
+
+// serveAuthenticated authenticates r with authenticator, then serves it
+// with serve, or answers it with a 401 in the format of the API it was
+// sent to.
+func serveAuthenticated(ctx context.Context, authenticator *auth.Authenticator, r httpRequest, serve func(context.Context) httpResponse) httpResponse {
+	if isPublicPath(r.Path) {
+		return serve(ctx)
+	}
+	ctx, err := authenticate(ctx, authenticator, r.Header)
+	if err == nil {
+		return serve(ctx)
+	}
+
+	var response httpResponse
+	if isRESTPath(r.Path) {
+		response = restErrorBody(http.StatusUnauthorized, errorCodes[ErrUnauthenticated], err.Error())
+	} else {
+		response = jsonResponse(http.StatusUnauthorized, contentTypeJSON,
+			&graphql.Result{Errors: []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": errorCodes[ErrUnauthenticated]},
+			}}})
+	}
+	response.Header.Set("WWW-Authenticate", `Bearer realm="hotel"`)
+	return response
+}
+
+// authenticateCalls returns the gRPC counterpart of serveAuthenticated,
+// reading the credentials from the authorization and x-api-key metadata.
+func authenticateCalls(authenticator *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		header := http.Header{}
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, key := range []string{"Authorization", auth.APIKeyHeader} {
+			if values := md.Get(key); len(values) > 0 {
+				header.Set(key, values[0])
+			}
+		}
+		ctx, err := authenticate(ctx, authenticator, header)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(ctx, req)
+	}
+}
@@ -1,21 +1,14 @@
 package api
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/graphql-go/graphql"
-	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/ratelimit"
+	"github.com/willsams/go-hotel-reservation-service/store"
 )
 
-type Room struct {
-	ID           string  `db:"id"`
-	NumBeds      int     `db:"num_beds"`
-	AllowSmoking bool    `db:"allow_smoking"`
-	DailyRate    float64 `db:"daily_rate"`
-	CleaningFee  float64 `db:"cleaning_fee"`
-	TotalCharge  float64 `db:"total_charge"`
-}
+type Room = store.Room
 
 var roomType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Room",
@@ -38,50 +31,174 @@ var roomType = graphql.NewObject(graphql.ObjectConfig{
 		"TotalCharge": &graphql.Field{
 			Type: graphql.Float,
 			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-				room, _ := params.Source.(*Room)
+				room, ok := roomSource(params.Source)
+				if !ok {
+					return nil, nil
+				}
+				// Availability searches price the whole stay; elsewhere quote a single night.
+				if room.TotalCharge != 0 {
+					return room.TotalCharge, nil
+				}
 				return room.DailyRate + room.CleaningFee, nil
 			},
 		},
 	},
 })
 
-func daysBetween(startDateStr string, endDateStr string) int {
-	startDate, _ := time.Parse("2006-01-02", startDateStr)
-	endDate, _ := time.Parse("2006-01-02", endDateStr)
-	days := int(endDate.Sub(startDate).Hours() / 24)
-	return days
+func init() {
+	roomType.AddFieldConfig("reservations", &graphql.Field{
+		Type:        graphql.NewList(reservationType),
+		Description: "Reservations of the room, optionally only those overlapping dateRange; guests only get their own",
+		Args: graphql.FieldConfigArgument{
+			"dateRange": &graphql.ArgumentConfig{Type: dateRangeInputType},
+		},
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			room, ok := roomSource(params.Source)
+			if !ok {
+				return nil, nil
+			}
+			owner, err := reservationOwner(resolveContext(params), auth.ReadReservations, auth.ReadOwnReservations)
+			if err != nil {
+				return nil, err
+			}
+
+			key := roomReservationsKey{RoomID: room.ID}
+			if dateRange, ok := params.Args["dateRange"].(map[string]interface{}); ok {
+				start, err := dateArg(dateRange, "start")
+				if err != nil {
+					return nil, err
+				}
+				end, err := dateArg(dateRange, "end")
+				if err != nil {
+					return nil, err
+				}
+				if !end.After(start) {
+					return nil, invalidInput("dateRange end %s must be after start %s", end.Format(dateLayout), start.Format(dateLayout))
+				}
+				key.Start, key.End = start.Format(dateLayout), end.Format(dateLayout)
+			}
+
+			l, err := loadersFrom(params.Context)
+			if err != nil {
+				return nil, err
+			}
+
+			load := l.roomReservations.load(key)
+			return func() (interface{}, error) {
+				reservations, err := load()
+				if err != nil {
+					return nil, err
+				}
+				own := []Reservation{}
+				for _, reservation := range reservations {
+					if owner == "" || reservation.GuestID == owner {
+						own = append(own, reservation)
+					}
+				}
+				return own, nil
+			}, nil
+		},
+	})
+}
+
+func roomSource(source interface{}) (Room, bool) {
+	switch room := source.(type) {
+	case Room:
+		return room, true
+	case *Room:
+		if room != nil {
+			return *room, true
+		}
+	}
+	return Room{}, false
 }
 
-func GetAvailableRooms(db *sqlx.DB) func(params graphql.ResolveParams) (interface{}, error) {
+func GetAvailableRooms(rooms store.RoomRepository) func(params graphql.ResolveParams) (interface{}, error) {
 	return func(params graphql.ResolveParams) (interface{}, error) {
-		startDate, _ := params.Args["startDate"].(string)
+		if err := authorize(resolveContext(params), auth.SearchRooms); err != nil {
+			return nil, err
+		}
+		if err := takeToken(resolveContext(params), ratelimit.Searches); err != nil {
+			return nil, err
+		}
+		start, end, err := stayArgs(params.Args, "startDate", "endDate")
+		if err != nil {
+			return nil, err
+		}
 		numBeds, _ := params.Args["numBeds"].(int)
 		allowSmoking, _ := params.Args["allowSmoking"].(bool)
-		endDate, _ := params.Args["endDate"].(string)
 
-		numDays := daysBetween(startDate, endDate)
+		available, err := rooms.AvailableRooms(resolveContext(params), store.AvailabilityQuery{
+			Start:        start.Format(dateLayout),
+			End:          end.Format(dateLayout),
+			Nights:       nightsBetween(start, end),
+			NumBeds:      numBeds,
+			AllowSmoking: allowSmoking,
+		})
+		if err != nil {
+			return nil, err
+		}
 
-		// Query the database for available rooms
-		query := fmt.Sprintf(`select distinct ro.id, ro.num_beds, ro.allow_smoking, ro.daily_rate, ro.cleaning_fee,
-				((ro.daily_rate * %d + ro.cleaning_fee)) as total_charge
-			from rooms as ro
-			where ro.allow_smoking = %t
-				and ro.num_beds >= %d
-				and ro.id not in (
-				select room_id 
-				from reservations 
-				where '%s' between checkin_date and checkout_date
-					
-				and '%s'  between checkin_date and checkout_date
-				)
-			order by 6, 2`, numDays, allowSmoking, numBeds, endDate, startDate)
+		if len(available) == 0 {
+			availabilitySearches.WithLabelValues("empty").Inc()
+		} else {
+			availabilitySearches.WithLabelValues("found").Inc()
+		}
+		return available, nil
+	}
+}
 
-		var rooms []Room
-		err := db.Select(&rooms, query)
+// GetRooms lists every room, by id.
+func GetRooms(rooms store.RoomRepository) func(params graphql.ResolveParams) (interface{}, error) {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		ctx := resolveContext(params)
+		if err := authorize(ctx, auth.SearchRooms); err != nil {
+			return nil, err
+		}
+		list, err := rooms.ListRooms(ctx)
 		if err != nil {
 			return nil, err
 		}
+		if list == nil {
+			list = []Room{}
+		}
+		return list, nil
+	}
+}
 
-		return rooms, nil
+// SetRoomRates changes the daily rate and cleaning fee of the room with
+// the roomId argument to whole amounts. Only admins may.
+func SetRoomRates(rooms store.RoomRepository) func(params graphql.ResolveParams) (interface{}, error) {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		ctx := resolveContext(params)
+		if err := authorize(ctx, auth.SetRoomRates); err != nil {
+			return nil, err
+		}
+		roomID, _ := params.Args["roomId"].(string)
+		dailyRate, _ := params.Args["dailyRate"].(float64)
+		cleaningFee, _ := params.Args["cleaningFee"].(float64)
+		if dailyRate < 0 || cleaningFee < 0 {
+			return nil, invalidInput("dailyRate and cleaningFee cannot be negative")
+		}
+		if err := checkWholeAmount("dailyRate", dailyRate); err != nil {
+			return nil, err
+		}
+		if err := checkWholeAmount("cleaningFee", cleaningFee); err != nil {
+			return nil, err
+		}
+
+		found, err := rooms.Rooms(ctx, []string{roomID})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, notFound("room %s does not exist", roomID)
+		}
+		room := found[0]
+		room.DailyRate, room.CleaningFee, room.TotalCharge = dailyRate, cleaningFee, 0
+		if err := rooms.SaveRoom(ctx, room); err != nil {
+			return nil, err
+		}
+		return room, nil
 	}
 }
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/config"
+)
+
+// Settings are what the servers and the Lambda handler apply to the
+// requests they serve. Each handler keeps its own, so that handlers with
+// different settings can run side by side. The zero value applies none of
+// them: no limits, persisted queries, authentication or rate limits, and
+// allows WebSockets from no origin.
+type Settings struct {
+	// Config is the configuration of the process, whose API and GRPC
+	// sections set the ports, timeouts, diagnostics token and WebSocket
+	// origins of the servers and which /diagnostics reports, redacted.
+	Config config.Config
+	// Limits bound the depth and complexity of GraphQL operations.
+	Limits Limits
+	// PersistedQueries resolves persisted query hashes, nil ignoring them.
+	PersistedQueries *PersistedQueryStore
+	// Authenticator checks the credentials of every request. Without one,
+	// every request is anonymous and allowed everything, as in development.
+	Authenticator *auth.Authenticator
+	// RateLimits is how often each client may search availability and book.
+	RateLimits config.RateLimit
+}
+
+// NewSettings returns the GraphQL limits, persisted query, authentication
+// and rate limit settings of cfg.
+func NewSettings(cfg config.Config) (Settings, error) {
+	persisted, err := NewPersistedQueryStore(cfg.PersistedQueries)
+	if err != nil {
+		return Settings{}, err
+	}
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		Config:           cfg,
+		Limits:           Limits{MaxDepth: cfg.GraphQL.MaxDepth, MaxComplexity: cfg.GraphQL.MaxComplexity},
+		PersistedQueries: persisted,
+		Authenticator:    authenticator,
+		RateLimits:       cfg.RateLimit,
+	}, nil
+}
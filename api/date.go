@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const dateLayout = "2006-01-02"
+
+// MaxStayNights is the longest stay, in nights, that may be searched for or booked.
+var MaxStayNights = 90
+
+// Now is the clock used to decide whether an arrival date is in the past.
+var Now = time.Now
+
+// DateScalar is an ISO-8601 calendar date such as 2023-03-01. Values that are
+// not real dates are rejected before any resolver runs.
+var DateScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Date",
+	Description: "An ISO-8601 calendar date (YYYY-MM-DD)",
+	Serialize: func(value interface{}) interface{} {
+		date, err := toDate(value)
+		if err != nil {
+			return nil
+		}
+		return date.Format(dateLayout)
+	},
+	ParseValue: func(value interface{}) interface{} {
+		date, err := toDate(value)
+		if err != nil {
+			return nil
+		}
+		return date
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		value, ok := valueAST.(*ast.StringValue)
+		if !ok {
+			return nil
+		}
+		date, err := parseDate(value.Value)
+		if err != nil {
+			return nil
+		}
+		return date
+	},
+})
+
+func parseDate(value string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return date, nil
+}
+
+func toDate(value interface{}) (time.Time, error) {
+	switch value := value.(type) {
+	case time.Time:
+		return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC), nil
+	case *time.Time:
+		if value == nil {
+			return time.Time{}, fmt.Errorf("missing date")
+		}
+		return toDate(*value)
+	case string:
+		return parseDate(value)
+	case *string:
+		if value == nil {
+			return time.Time{}, fmt.Errorf("missing date")
+		}
+		return parseDate(*value)
+	default:
+		return time.Time{}, fmt.Errorf("invalid date %v, expected YYYY-MM-DD", value)
+	}
+}
+
+// dateArg reads a date argument, accepting both the time.Time produced by
+// DateScalar and a plain string from callers that bypass the schema.
+func dateArg(args map[string]interface{}, name string) (time.Time, error) {
+	value, ok := args[name]
+	if !ok || value == nil {
+		return time.Time{}, invalidInput("%s is required", name)
+	}
+	date, err := toDate(value)
+	if err != nil {
+		return time.Time{}, invalidInput("%s: %v", name, err)
+	}
+	return date, nil
+}
+
+func nightsBetween(checkin time.Time, checkout time.Time) int {
+	return int(checkout.Sub(checkin).Hours() / 24)
+}
+
+// validateStay checks that a stay ends after it begins, does not begin in
+// the past and is no longer than MaxStayNights.
+func validateStay(checkin time.Time, checkout time.Time) error {
+	if !checkout.After(checkin) {
+		return invalidInput("checkout date %s must be after checkin date %s",
+			checkout.Format(dateLayout), checkin.Format(dateLayout))
+	}
+
+	today, _ := toDate(Now())
+	if checkin.Before(today) {
+		return invalidInput("checkin date %s is in the past", checkin.Format(dateLayout))
+	}
+
+	if nights := nightsBetween(checkin, checkout); nights > MaxStayNights {
+		return invalidInput("stay of %d nights exceeds the maximum of %d", nights, MaxStayNights)
+	}
+	return nil
+}
+
+// stayArgs reads and validates a pair of date arguments.
+func stayArgs(args map[string]interface{}, checkinName string, checkoutName string) (time.Time, time.Time, error) {
+	checkin, err := dateArg(args, checkinName)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	checkout, err := dateArg(args, checkoutName)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if err := validateStay(checkin, checkout); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return checkin, checkout, nil
+}
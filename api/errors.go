@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Resolver errors are classified so that every transport can report them
+// properly: GraphQL adds a code to the error extensions and the REST API
+// picks the HTTP status from it. Unclassified errors are internal.
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	// ErrUnauthenticated is returned for requests without valid credentials
+	// where they are required.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is returned for callers whose roles do not allow what
+	// they asked for.
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited is returned for clients that used up a rate limit
+	// budget.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+var errorCodes = map[error]string{
+	ErrInvalidInput:    "BAD_USER_INPUT",
+	ErrNotFound:        "NOT_FOUND",
+	ErrConflict:        "CONFLICT",
+	ErrUnauthenticated: "UNAUTHENTICATED",
+	ErrForbidden:       "FORBIDDEN",
+	ErrRateLimited:     "RATE_LIMITED",
+}
+
+type classifiedError struct {
+	kind    error
+	message string
+}
+
+func (e *classifiedError) Error() string {
+	return e.message
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Extensions implements gqlerrors.ExtendedError.
+func (e *classifiedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": errorCodes[e.kind]}
+}
+
+func invalidInput(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrInvalidInput, message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrConflict, message: fmt.Sprintf(format, args...)}
+}
+
+func unauthenticated(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrUnauthenticated, message: fmt.Sprintf(format, args...)}
+}
+
+func forbidden(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrForbidden, message: fmt.Sprintf(format, args...)}
+}
+
+func rateLimited(format string, args ...interface{}) error {
+	return &classifiedError{kind: ErrRateLimited, message: fmt.Sprintf(format, args...)}
+}
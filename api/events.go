@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+)
+
+const (
+	ReservationCreatedAction   = "created"
+	ReservationUpdatedAction   = "updated"
+	ReservationCancelledAction = "cancelled"
+)
+
+// SubscriberBufferSize is how many events a subscriber may fall behind before
+// it is disconnected.
+const SubscriberBufferSize = 64
+
+// ReservationEvent is published whenever a reservation mutation succeeds.
+type ReservationEvent struct {
+	Action      string
+	Reservation Reservation
+}
+
+// RoomAvailabilityEvent reports that a room was booked (Available is false)
+// or released (Available is true) between Start and End.
+type RoomAvailabilityEvent struct {
+	RoomID    string
+	Start     string
+	End       string
+	Available bool
+}
+
+// Hub fans published events out to every subscriber whose filter accepts
+// them. Publishing never blocks: a subscriber that cannot keep up has its
+// channel closed and must subscribe again.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*hubSubscriber]struct{}
+	bufferSize  int
+}
+
+type hubSubscriber struct {
+	events chan interface{}
+	filter func(event interface{}) bool
+}
+
+func NewHub(bufferSize int) *Hub {
+	return &Hub{subscribers: map[*hubSubscriber]struct{}{}, bufferSize: bufferSize}
+}
+
+// Events is the hub reservation mutations publish to and GraphQL
+// subscriptions listen on.
+var Events = NewHub(SubscriberBufferSize)
+
+// Subscribe returns a channel of the events accepted by filter (all events
+// when filter is nil). The channel is closed once ctx is done.
+func (h *Hub) Subscribe(ctx context.Context, filter func(event interface{}) bool) chan interface{} {
+	subscriber := &hubSubscriber{events: make(chan interface{}, h.bufferSize), filter: filter}
+
+	h.mu.Lock()
+	h.subscribers[subscriber] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.remove(subscriber)
+	}()
+
+	return subscriber.events
+}
+
+// Publish delivers event to every interested subscriber.
+func (h *Hub) Publish(event interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for subscriber := range h.subscribers {
+		if subscriber.filter != nil && !subscriber.filter(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			delete(h.subscribers, subscriber)
+			close(subscriber.events)
+		}
+	}
+}
+
+// Len returns the number of active subscribers.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+func (h *Hub) remove(subscriber *hubSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[subscriber]; ok {
+		delete(h.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+var reservationActionType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ReservationAction",
+	Values: graphql.EnumValueConfigMap{
+		"CREATED":   &graphql.EnumValueConfig{Value: ReservationCreatedAction},
+		"UPDATED":   &graphql.EnumValueConfig{Value: ReservationUpdatedAction},
+		"CANCELLED": &graphql.EnumValueConfig{Value: ReservationCancelledAction},
+	},
+})
+
+var reservationEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReservationEvent",
+	Fields: graphql.Fields{
+		"action":      &graphql.Field{Type: graphql.NewNonNull(reservationActionType)},
+		"reservation": &graphql.Field{Type: graphql.NewNonNull(reservationType)},
+	},
+})
+
+var roomAvailabilityEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RoomAvailabilityEvent",
+	Fields: graphql.Fields{
+		"roomId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"room": &graphql.Field{
+			Type: roomType,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				event, _ := params.Source.(RoomAvailabilityEvent)
+				l, err := loadersFrom(params.Context)
+				if err != nil {
+					return nil, err
+				}
+
+				load := l.rooms.load(event.RoomID)
+				return func() (interface{}, error) {
+					room, err := load()
+					if room == nil || err != nil {
+						return nil, err
+					}
+					return room, nil
+				}, nil
+			},
+		},
+		"start":     &graphql.Field{Type: graphql.NewNonNull(DateScalar)},
+		"end":       &graphql.Field{Type: graphql.NewNonNull(DateScalar)},
+		"available": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+func publishReservationEvent(action string, reservation Reservation) {
+	Events.Publish(ReservationEvent{Action: action, Reservation: reservation})
+}
+
+// publishReservationMoved publishes the update of a reservation from
+// previous. When the update moved the stay to other dates or another room,
+// the room and dates it left are first published as released.
+func publishReservationMoved(previous, reservation Reservation) {
+	if previous.RoomID != reservation.RoomID || previous.CheckinDate != reservation.CheckinDate ||
+		previous.CheckoutDate != reservation.CheckoutDate {
+		Events.Publish(RoomAvailabilityEvent{
+			RoomID:    previous.RoomID,
+			Start:     previous.CheckinDate,
+			End:       previous.CheckoutDate,
+			Available: true,
+		})
+	}
+	publishReservationEvent(ReservationUpdatedAction, reservation)
+}
+
+// SubscribeToReservationChanges streams the reservation events of hub,
+// optionally only those for the rooms in the roomIds argument. Guests only
+// get the events of their own reservations.
+func SubscribeToReservationChanges(hub *Hub) func(params graphql.ResolveParams) (interface{}, error) {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		owner, err := reservationOwner(resolveContext(params), auth.ReadReservations, auth.ReadOwnReservations)
+		if err != nil {
+			return nil, err
+		}
+		roomIDs := stringList(params.Args["roomIds"])
+		return hub.Subscribe(params.Context, func(event interface{}) bool {
+			reservationEvent, ok := event.(ReservationEvent)
+			if !ok || (owner != "" && reservationEvent.Reservation.GuestID != owner) {
+				return false
+			}
+			return len(roomIDs) == 0 || containsString(roomIDs, reservationEvent.Reservation.RoomID)
+		}), nil
+	}
+}
+
+// SubscribeToRoomAvailability streams the events of hub that book or release
+// at least one night within the dateRange argument.
+func SubscribeToRoomAvailability(hub *Hub) func(params graphql.ResolveParams) (interface{}, error) {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		if err := authorize(resolveContext(params), auth.SearchRooms); err != nil {
+			return nil, err
+		}
+		dateRange, _ := params.Args["dateRange"].(map[string]interface{})
+		start, err := dateArg(dateRange, "start")
+		if err != nil {
+			return nil, err
+		}
+		end, err := dateArg(dateRange, "end")
+		if err != nil {
+			return nil, err
+		}
+		startDate, endDate := start.Format(dateLayout), end.Format(dateLayout)
+		roomIDs := stringList(params.Args["roomIds"])
+
+		return hub.Subscribe(params.Context, func(event interface{}) bool {
+			availability, ok := roomAvailability(event)
+			if !ok {
+				return false
+			}
+			if len(roomIDs) > 0 && !containsString(roomIDs, availability.RoomID) {
+				return false
+			}
+			return availability.Start < endDate && availability.End > startDate
+		}), nil
+	}
+}
+
+// roomAvailabilityFromEvent maps an event onto the room it affects.
+func roomAvailabilityFromEvent(params graphql.ResolveParams) (interface{}, error) {
+	availability, _ := roomAvailability(params.Source)
+	return availability, nil
+}
+
+// roomAvailability returns the room and dates event books or releases: a
+// reservation event books its stay, unless it cancels it, and a room
+// availability event is published as is.
+func roomAvailability(event interface{}) (RoomAvailabilityEvent, bool) {
+	switch event := event.(type) {
+	case RoomAvailabilityEvent:
+		return event, true
+	case ReservationEvent:
+		return RoomAvailabilityEvent{
+			RoomID:    event.Reservation.RoomID,
+			Start:     event.Reservation.CheckinDate,
+			End:       event.Reservation.CheckoutDate,
+			Available: event.Action == ReservationCancelledAction,
+		}, true
+	}
+	return RoomAvailabilityEvent{}, false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,16 +2,18 @@ package api
 
 import (
 	"github.com/graphql-go/graphql"
-	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
 )
 
-func AppSchema(db *sqlx.DB) (graphql.Schema, error) {
+// AppSchema builds the schema resolving against repos.
+func AppSchema(repos store.Repositories) (graphql.Schema, error) {
 	rootQuery := graphql.ObjectConfig{Name: "RootQuery", Fields: graphql.Fields{
 		"availableRooms": &graphql.Field{
 			Type: graphql.NewList(roomType),
 			Args: graphql.FieldConfigArgument{
 				"startDate": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.String),
+					Type: graphql.NewNonNull(DateScalar),
 				},
 				"numBeds": &graphql.ArgumentConfig{
 					Type: graphql.NewNonNull(graphql.Int),
@@ -20,14 +22,20 @@ func AppSchema(db *sqlx.DB) (graphql.Schema, error) {
 					Type: graphql.NewNonNull(graphql.Boolean),
 				},
 				"endDate": &graphql.ArgumentConfig{
-					Type: graphql.NewNonNull(graphql.String),
+					Type: graphql.NewNonNull(DateScalar),
 				},
 			},
-			Resolve: GetAvailableRooms(db),
+			Resolve: GetAvailableRooms(repos.Rooms),
+		},
+		"rooms": &graphql.Field{
+			Type:        graphql.NewList(roomType),
+			Description: "Every room, by id",
+			Resolve:     GetRooms(repos.Rooms),
 		},
 		"reservations": &graphql.Field{
-			Type:    graphql.NewList(reservationType),
-			Resolve: GetAllReservations(db),
+			Type:    graphql.NewNonNull(reservationConnectionType),
+			Args:    reservationsArgs,
+			Resolve: GetAllReservations(repos.Reservations),
 		},
 		"reservation": &graphql.Field{
 			Type: reservationType,
@@ -36,7 +44,7 @@ func AppSchema(db *sqlx.DB) (graphql.Schema, error) {
 					Type: graphql.NewNonNull(graphql.String),
 				},
 			},
-			Resolve: GetReservation(db),
+			Resolve: GetReservation(repos.Reservations),
 		},
 	}}
 
@@ -49,14 +57,106 @@ func AppSchema(db *sqlx.DB) (graphql.Schema, error) {
 					Type: graphql.NewNonNull(ReservationInputType),
 				},
 			},
-			Resolve: CreateReservation(db),
+			Resolve: CreateReservation(repos.Rooms, repos.Reservations),
+		},
+		"updateReservation": &graphql.Field{
+			Type:        reservationType,
+			Description: "Change the room, dates or charge of a confirmed reservation",
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(ReservationUpdateInputType),
+				},
+			},
+			Resolve: UpdateReservation(repos.Rooms, repos.Reservations),
+		},
+		"cancelReservation": &graphql.Field{
+			Type:        reservationType,
+			Description: "Cancel a reservation, releasing its room",
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Resolve: CancelReservation(repos.Reservations),
+		},
+		"blockRoom": &graphql.Field{
+			Type:        reservationType,
+			Description: "Take a room out of service for the nights from startDate to endDate; cancel the block to release it",
+			Args: graphql.FieldConfigArgument{
+				"roomId": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"startDate": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(DateScalar),
+				},
+				"endDate": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(DateScalar),
+				},
+			},
+			Resolve: BlockRoom(repos.Reservations),
+		},
+		"setRoomRates": &graphql.Field{
+			Type:        roomType,
+			Description: "Change the daily rate and cleaning fee of a room to whole amounts; admins only",
+			Args: graphql.FieldConfigArgument{
+				"roomId": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"dailyRate": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Float),
+				},
+				"cleaningFee": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Float),
+				},
+			},
+			Resolve: SetRoomRates(repos.Rooms),
+		},
+	}}
+
+	rootSubscription := graphql.ObjectConfig{Name: "RootSubscription", Fields: graphql.Fields{
+		"reservationChanged": &graphql.Field{
+			Type:        graphql.NewNonNull(reservationEventType),
+			Description: "Reservations being created, updated or cancelled",
+			Args: graphql.FieldConfigArgument{
+				"roomIds": &graphql.ArgumentConfig{
+					Type: graphql.NewList(graphql.NewNonNull(graphql.String)),
+				},
+			},
+			Subscribe: SubscribeToReservationChanges(Events),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return params.Source, nil
+			},
+		},
+		"roomAvailabilityChanged": &graphql.Field{
+			Type:        graphql.NewNonNull(roomAvailabilityEventType),
+			Description: "Rooms being booked or released for nights within dateRange",
+			Args: graphql.FieldConfigArgument{
+				"dateRange": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(dateRangeInputType),
+				},
+				"roomIds": &graphql.ArgumentConfig{
+					Type: graphql.NewList(graphql.NewNonNull(graphql.String)),
+				},
+			},
+			Subscribe: SubscribeToRoomAvailability(Events),
+			Resolve:   roomAvailabilityFromEvent,
 		},
 	}}
 
 	schemaConfig := graphql.SchemaConfig{
-		Query:    graphql.NewObject(rootQuery),
-		Mutation: graphql.NewObject(rootMutation),
+		Query:        graphql.NewObject(rootQuery),
+		Mutation:     graphql.NewObject(rootMutation),
+		Subscription: graphql.NewObject(rootSubscription),
+		Extensions:   []graphql.Extension{loaderExtension{repos: repos}, metricsExtension{}},
 	}
 
-	return graphql.NewSchema(schemaConfig)
+	schema, err := graphql.NewSchema(schemaConfig)
+	if err != nil {
+		return schema, err
+	}
+	traceResolvers(schema)
+	return schema, nil
 }
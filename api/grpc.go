@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/hotelpb"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+var reservationStatuses = map[string]hotelpb.ReservationStatus{
+	ReservationConfirmed: hotelpb.ReservationStatus_RESERVATION_STATUS_CONFIRMED,
+	ReservationCancelled: hotelpb.ReservationStatus_RESERVATION_STATUS_CANCELLED,
+	ReservationBlocked:   hotelpb.ReservationStatus_RESERVATION_STATUS_BLOCKED,
+}
+
+// reservationServer implements the gRPC ReservationService with the same
+// resolvers as the GraphQL schema.
+type reservationServer struct {
+	hotelpb.UnimplementedReservationServiceServer
+	repos store.Repositories
+}
+
+func NewReservationServer(repos store.Repositories) hotelpb.ReservationServiceServer {
+	return &reservationServer{repos: repos}
+}
+
+func (s *reservationServer) SearchAvailability(ctx context.Context, req *hotelpb.SearchAvailabilityRequest) (*hotelpb.SearchAvailabilityResponse, error) {
+	result, err := GetAvailableRooms(s.repos.Rooms)(graphql.ResolveParams{Context: ctx, Args: map[string]interface{}{
+		"startDate":    req.StartDate,
+		"endDate":      req.EndDate,
+		"numBeds":      int(req.NumBeds),
+		"allowSmoking": req.AllowSmoking,
+	}})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	rooms, _ := result.([]Room)
+	response := &hotelpb.SearchAvailabilityResponse{Rooms: make([]*hotelpb.Room, len(rooms))}
+	for i, room := range rooms {
+		response.Rooms[i] = &hotelpb.Room{
+			Id:           room.ID,
+			NumBeds:      int32(room.NumBeds),
+			AllowSmoking: room.AllowSmoking,
+			DailyRate:    room.DailyRate,
+			CleaningFee:  room.CleaningFee,
+			TotalCharge:  room.TotalCharge,
+		}
+	}
+	return response, nil
+}
+
+func (s *reservationServer) CreateReservation(ctx context.Context, req *hotelpb.CreateReservationRequest) (*hotelpb.Reservation, error) {
+	args := map[string]interface{}{
+		"roomId":       req.RoomId,
+		"checkinDate":  req.CheckinDate,
+		"checkoutDate": req.CheckoutDate,
+	}
+	// proto3 cannot tell an unset charge from zero.
+	if req.TotalCharge != 0 {
+		args["totalCharge"] = req.TotalCharge
+	}
+	result, err := CreateReservation(s.repos.Rooms, s.repos.Reservations)(graphql.ResolveParams{Context: ctx, Args: args})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return reservationMessage(result.(Reservation)), nil
+}
+
+func (s *reservationServer) GetReservation(ctx context.Context, req *hotelpb.GetReservationRequest) (*hotelpb.Reservation, error) {
+	reservation, err := findOwnReservation(ctx, s.repos.Reservations, req.Id, auth.ReadReservations, auth.ReadOwnReservations)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return reservationMessage(reservation), nil
+}
+
+func (s *reservationServer) ListReservations(ctx context.Context, req *hotelpb.ListReservationsRequest) (*hotelpb.ListReservationsResponse, error) {
+	args := map[string]interface{}{}
+	if req.PageSize != 0 {
+		args["first"] = int(req.PageSize)
+	}
+	if req.PageToken != "" {
+		args["after"] = req.PageToken
+	}
+
+	filter := map[string]interface{}{}
+	if len(req.RoomIds) > 0 {
+		filter["roomIds"] = interfaceList(req.RoomIds)
+	}
+	if len(req.Statuses) > 0 {
+		var statuses []string
+		for _, requested := range req.Statuses {
+			for name, value := range reservationStatuses {
+				if value == requested {
+					statuses = append(statuses, name)
+				}
+			}
+		}
+		if len(statuses) != len(req.Statuses) {
+			return nil, status.Error(codes.InvalidArgument, "statuses must be CONFIRMED, CANCELLED or BLOCKED")
+		}
+		filter["status"] = interfaceList(statuses)
+	}
+	if req.OverlapsStart != "" || req.OverlapsEnd != "" {
+		filter["overlaps"] = map[string]interface{}{"start": req.OverlapsStart, "end": req.OverlapsEnd}
+	}
+	args["filter"] = filter
+
+	if req.OrderBy != "" {
+		field, direction, _ := strings.Cut(strings.TrimSpace(req.OrderBy), " ")
+		direction = strings.ToLower(strings.TrimSpace(direction))
+		if direction == "" {
+			direction = "asc"
+		}
+		if direction != "asc" && direction != "desc" {
+			return nil, status.Errorf(codes.InvalidArgument, "order_by direction must be asc or desc, not %q", direction)
+		}
+		args["orderBy"] = map[string]interface{}{"field": field, "direction": direction}
+	}
+
+	result, err := GetAllReservations(s.repos.Reservations)(graphql.ResolveParams{Context: ctx, Args: args})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	connection := result.(*ReservationConnection)
+	totalCount, err := connection.totalCount()
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	response := &hotelpb.ListReservationsResponse{TotalCount: int32(totalCount)}
+	for _, edge := range connection.Edges {
+		response.Reservations = append(response.Reservations, reservationMessage(edge.Node))
+	}
+	if connection.PageInfo.HasNextPage {
+		response.NextPageToken = *connection.PageInfo.EndCursor
+	}
+	return response, nil
+}
+
+func (s *reservationServer) CancelReservation(ctx context.Context, req *hotelpb.CancelReservationRequest) (*hotelpb.Reservation, error) {
+	result, err := CancelReservation(s.repos.Reservations)(graphql.ResolveParams{Context: ctx, Args: map[string]interface{}{"id": req.Id}})
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return reservationMessage(result.(Reservation)), nil
+}
+
+func reservationMessage(reservation Reservation) *hotelpb.Reservation {
+	return &hotelpb.Reservation{
+		Id:           reservation.ID,
+		RoomId:       reservation.RoomID,
+		CheckinDate:  reservation.CheckinDate,
+		CheckoutDate: reservation.CheckoutDate,
+		TotalCharge:  reservation.TotalCharge,
+		Status:       reservationStatuses[reservation.Status],
+	}
+}
+
+// grpcError maps a resolver error onto a gRPC status. Internal errors are
+// logged rather than shown to the client.
+func grpcError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		logging.FromContext(ctx).Error("grpc call failed", "error", err.Error())
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// GrpcApiHandler serves the gRPC ReservationService on the gRPC port of
+// settings, authenticating, rate limiting and tracing each call with them,
+// until ctx is done. It then stops accepting calls and waits up to the
+// shutdown timeout of settings for those in flight to finish. Server
+// reflection is enabled so that tools such as grpcurl can discover it.
+func GrpcApiHandler(ctx context.Context, settings Settings, repos store.Repositories) error {
+	server := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()), grpc.ChainUnaryInterceptor(
+		logCalls, authenticateCalls(settings.Authenticator), limitCalls(newRateLimiter(settings.RateLimits, repos))))
+	hotelpb.RegisterReservationServiceServer(server, NewReservationServer(repos))
+	reflection.Register(server)
+
+	grpcPort := strconv.Itoa(settings.Config.GRPC.Port)
+	listener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	logging.Logger().Info("listening", "url", "grpc://localhost:"+grpcPort)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	timeout := time.NewTimer(settings.Config.API.ShutdownTimeout.Duration)
+	defer timeout.Stop()
+	select {
+	case <-stopped:
+	case <-timeout.C:
+		server.Stop()
+	}
+	return <-serveErr
+}
@@ -2,70 +2,156 @@ package api
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
-	"log"
-	"os"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/graphql-go/graphql"
-	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/tracing"
 )
 
-// Define the GraphQL schema
-var schema, _ = AppSchema(dbConnect())
+// LambdaHandler is the API Gateway proxy handler started by lambda.Start.
+type LambdaHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// NewGraphQlApiHandler returns the Lambda handler serving GraphQL at /api
+// and the REST API on its own paths from repos, authenticating, rate
+// limiting, logging and tracing every invocation with settings. Create it
+// once, outside the handler, so that warm invocations reuse the schema and
+// the pool.
+func NewGraphQlApiHandler(settings Settings, repos store.Repositories) (LambdaHandler, error) {
+	schema, err := AppSchema(repos)
+	if err != nil {
+		return nil, err
+	}
+	pinger, _ := repos.Reservations.(store.Pinger)
+	health := newPoolHealthCheck(pinger)
+	limiter := newRateLimiter(settings.RateLimits, repos)
 
-// Define the available rooms handler
-func GraphQlApiHandler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	db := dbConnect()
-	defer db.Close()
+	serve := func(ctx context.Context, request events.APIGatewayProxyRequest) httpResponse {
+		r, err := fromAPIGatewayRequest(request)
+		if err != nil {
+			return jsonResponse(http.StatusBadRequest, contentTypeJSON, errorResult(err.Error()))
+		}
+		ctx, limiting := withRateLimiting(ctx, limiter, r.RemoteAddr)
+		return limiting.report(serveAuthenticated(ctx, settings.Authenticator, r, func(ctx context.Context) httpResponse {
+			if err := health.check(ctx); err != nil {
+				logging.FromContext(ctx).Error("database unavailable", "error", err.Error())
+				return jsonResponse(http.StatusServiceUnavailable, contentTypeJSON, errorResult("database unavailable"))
+			}
 
-	queryParameters := make(map[string]interface{})
-	for key, value := range request.QueryStringParameters {
-		queryParameters[key] = value
+			if isRESTPath(r.Path) {
+				return serveREST(ctx, repos, r)
+			}
+			return serveGraphQL(ctx, schema, settings, r)
+		}))
 	}
 
-	result := graphql.Do(graphql.Params{
-		Schema:         schema,
-		RequestString:  request.Body,
-		Context:        ctx,
-		VariableValues: queryParameters,
-	})
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		header := apiGatewayHeader(request)
+		ctx = logging.WithRequestID(ctx, lambdaRequestID(request, header))
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+		ctx, span := tracing.Tracer().Start(ctx, spanName(request.HTTPMethod, request.Path),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(request.HTTPMethod), semconv.HTTPTarget(request.Path)))
+		defer tracing.Flush(ctx)
+		defer span.End()
 
-	return buildAPIGatewayResponse(200, result)
+		response := serve(ctx, request)
+		if response.Header == nil {
+			response.Header = http.Header{}
+		}
+		response.Header.Set(logging.RequestIDHeader, logging.RequestID(ctx))
+		span.SetAttributes(semconv.HTTPStatusCode(response.StatusCode))
+		if response.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+		}
+		logRequest(ctx, request.HTTPMethod, request.Path, response.StatusCode, time.Since(start))
+		return buildAPIGatewayResponse(response)
+	}, nil
 }
 
-func dbConnect() *sqlx.DB {
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWD")
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbName := os.Getenv("DB_NAME")
+// lambdaRequestID is the correlation id of an invocation: the id API Gateway
+// gave the request, else the X-Request-Id it came with, else a new one.
+func lambdaRequestID(request events.APIGatewayProxyRequest, header http.Header) string {
+	if id := request.RequestContext.RequestID; id != "" {
+		return logging.RequestIDOr(id)
+	}
+	return logging.RequestIDOr(header.Get(logging.RequestIDHeader))
+}
 
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
+// fromAPIGatewayRequest converts an API Gateway proxy request into the
+// transport-neutral request understood by serveGraphQL.
+func fromAPIGatewayRequest(request events.APIGatewayProxyRequest) (httpRequest, error) {
+	header := apiGatewayHeader(request)
 
-	db, err := sqlx.Connect("postgres", dataSourceName)
-	if err != nil {
-		log.Fatal(err)
+	query := url.Values{}
+	for key, values := range request.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+	for key, value := range request.QueryStringParameters {
+		if query.Get(key) == "" {
+			query.Set(key, value)
+		}
 	}
-	return db
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return httpRequest{}, fmt.Errorf("request body is not valid base64: %w", err)
+		}
+		body = decoded
+	}
+
+	return httpRequest{
+		Method:     request.HTTPMethod,
+		Path:       request.Path,
+		Header:     header,
+		Query:      query,
+		Body:       body,
+		RemoteAddr: request.RequestContext.Identity.SourceIP,
+	}, nil
 }
 
-func buildAPIGatewayResponse(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
-	responseBody, err := json.Marshal(body)
-	if err != nil {
-		return events.APIGatewayProxyResponse{}, err
+// apiGatewayHeader merges the single and multi-value headers of request.
+func apiGatewayHeader(request events.APIGatewayProxyRequest) http.Header {
+	header := http.Header{}
+	for key, values := range request.MultiValueHeaders {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	for key, value := range request.Headers {
+		if header.Get(key) == "" {
+			header.Set(key, value)
+		}
+	}
+	return header
+}
+
+func buildAPIGatewayResponse(response httpResponse) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{
+		"X-YOURCOMPANY-Func-Reply": "graphql-api-handler",
+	}
+	for key := range response.Header {
+		headers[key] = response.Header.Get(key)
 	}
 
 	return events.APIGatewayProxyResponse{
-		StatusCode:      statusCode,
+		StatusCode:      response.StatusCode,
 		IsBase64Encoded: false,
-		Body:            string(responseBody),
-		Headers: map[string]string{
-			"Content-Type":             "application/json",
-			"X-YOURCOMPANY-Func-Reply": "graphql-api-handler",
-		},
+		Body:            string(response.Body),
+		Headers:         headers,
 	}, nil
 }
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// readinessTimeout bounds the checks of a readiness probe, which must
+// answer before the orchestrator gives up on it.
+const readinessTimeout = 2 * time.Second
+
+// started is when the process started, as reported by /diagnostics.
+var started = time.Now()
+
+// check is the outcome of one readiness check.
+type check struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Version *int64 `json:"version,omitempty"`
+	Latest  *int64 `json:"latest,omitempty"`
+}
+
+// readiness is the body of /readyz: ready when every check is ok.
+type readiness struct {
+	Status string           `json:"status"`
+	Checks map[string]check `json:"checks"`
+}
+
+// checkReadiness checks that the database of repos answers and that its
+// schema is migrated up to the latest migration of the binary. A schema
+// migrated further by a newer release still serves this one, so that a
+// rolling deployment does not take the old instances out of service.
+func checkReadiness(ctx context.Context, repos store.Repositories) readiness {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result := readiness{Status: "ready", Checks: map[string]check{}}
+	fail := func(name string, c check) {
+		result.Status = "unavailable"
+		result.Checks[name] = c
+		logging.FromContext(ctx).Warn("not ready", "check", name, "error", c.Error)
+	}
+
+	var ping func(context.Context) error
+	if pinger, ok := repos.Reservations.(store.Pinger); ok {
+		ping = pinger.Ping
+	} else if pooled, ok := repos.Reservations.(store.Pooled); ok {
+		ping = pooled.DB().PingContext
+	}
+	if ping != nil {
+		if err := ping(ctx); err != nil {
+			fail("database", check{Status: "failed", Error: err.Error()})
+			return result
+		}
+		result.Checks["database"] = check{Status: "ok"}
+	}
+
+	if migrated, ok := repos.Reservations.(store.Migrated); ok {
+		migrator := migrated.Migrator()
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			fail("migrations", check{Status: "failed", Error: err.Error()})
+			return result
+		}
+		latest := migrator.Latest()
+		c := check{Status: "ok", Version: &version, Latest: &latest}
+		switch {
+		case version < latest:
+			c.Status = "pending"
+			c.Error = fmt.Sprintf("the database is at version %d, run the migrations up to %d", version, latest)
+			fail("migrations", c)
+		case version > latest:
+			c.Status = "ahead"
+			result.Checks["migrations"] = c
+		default:
+			result.Checks["migrations"] = c
+		}
+	}
+	return result
+}
+
+// serveReadiness answers readiness probes: 200 when the instance can serve
+// requests, and 503 otherwise.
+func serveReadiness(repos store.Repositories) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := checkReadiness(r.Context(), repos)
+		statusCode := http.StatusOK
+		if result.Status != "ready" {
+			statusCode = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeResponse(w, restResponse(r.Context(), statusCode, result))
+	}
+}
+
+// serveLiveness answers liveness probes. It checks nothing beyond the
+// process answering: a lost database makes the instance unready, and
+// restarting it would not bring the database back.
+func serveLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeResponse(w, restResponse(r.Context(), http.StatusOK, map[string]string{"status": "ok"}))
+}
+
+// build describes the binary.
+type build struct {
+	GoVersion string `json:"goVersion"`
+	Path      string `json:"path"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// pool is the statistics of the connection pool.
+type pool struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+// diagnostics is the body of /diagnostics.
+type diagnostics struct {
+	Build     build         `json:"build"`
+	StartedAt time.Time     `json:"startedAt"`
+	Uptime    string        `json:"uptime"`
+	Config    config.Config `json:"config"`
+	Pool      *pool         `json:"pool,omitempty"`
+}
+
+func readBuild() build {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build{Version: "unknown"}
+	}
+	b := build{GoVersion: info.GoVersion, Path: info.Main.Path, Version: info.Main.Version}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			b.Revision = setting.Value
+		case "vcs.time":
+			b.Time = setting.Value
+		case "vcs.modified":
+			b.Modified = setting.Value == "true"
+		}
+	}
+	return b
+}
+
+// serveDiagnostics reports the build, cfg with its secrets redacted and the
+// connection pool of repos to the bearer of the diagnostics token of cfg.
+// Without a token it is not served at all.
+func serveDiagnostics(cfg config.Config, repos store.Repositories) http.HandlerFunc {
+	token := cfg.API.DiagnosticsToken
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !validBearer(r.Header.Get("Authorization"), token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="diagnostics"`)
+			writeResponse(w, restErrorBody(http.StatusUnauthorized, "UNAUTHENTICATED", "a valid bearer token is required"))
+			return
+		}
+
+		report := diagnostics{
+			Build:     readBuild(),
+			StartedAt: started.UTC(),
+			Uptime:    time.Since(started).Round(time.Second).String(),
+			Config:    cfg.Redacted(),
+		}
+		if pooled, ok := repos.Reservations.(store.Pooled); ok {
+			stats := pooled.DB().Stats()
+			report.Pool = &pool{
+				MaxOpenConnections: stats.MaxOpenConnections,
+				OpenConnections:    stats.OpenConnections,
+				InUse:              stats.InUse,
+				Idle:               stats.Idle,
+				WaitCount:          stats.WaitCount,
+				WaitDuration:       stats.WaitDuration.String(),
+				MaxIdleClosed:      stats.MaxIdleClosed,
+				MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+				MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeResponse(w, restResponse(r.Context(), http.StatusOK, report))
+	}
+}
+
+// validBearer reports whether the Authorization header carries token. The
+// digests are compared so that the time taken reveals neither the token
+// nor its length.
+func validBearer(authorization string, token string) bool {
+	scheme, credentials, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	got := sha256.Sum256([]byte(strings.TrimSpace(credentials)))
+	want := sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1
+}
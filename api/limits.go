@@ -0,0 +1,293 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Limits bound how deep and how expensive a single operation may be. A zero
+// value disables the corresponding check.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// fieldCost is the cost hint of a field. Cost is charged once per field and
+// the cost of its selections is multiplied by the number of items the field
+// is expected to return: the value of SizeArg when the client passes it,
+// otherwise ListSize.
+type fieldCost struct {
+	Cost     int
+	SizeArg  string
+	ListSize int
+}
+
+// defaultListSize is the number of items assumed for list fields without a hint.
+const defaultListSize = 10
+
+// fieldCosts are the cost hints, keyed by "Type.field". Fields without a
+// hint cost 1 when they return an object and nothing when they return a
+// scalar; every root field and list field must have one, see
+// FieldsWithoutCost.
+var fieldCosts = map[string]fieldCost{
+	"RootQuery.availableRooms":                 {Cost: 10, ListSize: 20},
+	"RootQuery.rooms":                          {Cost: 10, ListSize: 50},
+	"RootQuery.reservations":                   {Cost: 10, SizeArg: "first", ListSize: DefaultPageSize},
+	"RootQuery.reservation":                    {Cost: 1},
+	"ReservationConnection.edges":              {Cost: 0, ListSize: 1},
+	"ReservationConnection.totalCount":         {Cost: 10},
+	"Reservation.room":                         {Cost: 1},
+	"Room.reservations":                        {Cost: 5, ListSize: 10},
+	"RootMutation.createReservation":           {Cost: 10},
+	"RootMutation.updateReservation":           {Cost: 10},
+	"RootMutation.cancelReservation":           {Cost: 10},
+	"RootMutation.blockRoom":                   {Cost: 10},
+	"RootMutation.setRoomRates":                {Cost: 10},
+	"RootSubscription.reservationChanged":      {Cost: 10},
+	"RootSubscription.roomAvailabilityChanged": {Cost: 10},
+}
+
+// FieldsWithoutCost returns the root fields and list fields of schema that
+// have no cost hint, as "Type.field". The defaults suit neither: a root
+// field reaches the store, and a list multiplies the cost of its items.
+func FieldsWithoutCost(schema graphql.Schema) []string {
+	roots := map[string]bool{}
+	for _, root := range []*graphql.Object{schema.QueryType(), schema.MutationType(), schema.SubscriptionType()} {
+		if root != nil {
+			roots[root.Name()] = true
+		}
+	}
+
+	var missing []string
+	for name, ttype := range schema.TypeMap() {
+		object, ok := ttype.(*graphql.Object)
+		if !ok || strings.HasPrefix(name, "__") {
+			continue
+		}
+		for fieldName, field := range object.Fields() {
+			key := name + "." + fieldName
+			if _, ok := fieldCosts[key]; !ok && (roots[name] || isListType(field.Type)) {
+				missing = append(missing, key)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// queryCost is the result of analysing an operation.
+type queryCost struct {
+	Depth      int
+	Complexity int
+}
+
+type costAnalyzer struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// analyzeQuery computes the depth and complexity of the selected operation
+// of document. Introspection fields are free and do not add depth.
+func analyzeQuery(schema *graphql.Schema, document *ast.Document, operationName string, variables map[string]interface{}) (queryCost, bool) {
+	analyzer := costAnalyzer{
+		schema:    schema,
+		fragments: map[string]*ast.FragmentDefinition{},
+		variables: variables,
+	}
+
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			analyzer.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return queryCost{}, false
+	}
+
+	var root *graphql.Object
+	switch operation.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+	if root == nil {
+		return queryCost{}, false
+	}
+
+	complexity, depth := analyzer.selectionSet(root, operation.SelectionSet, 1, map[string]bool{})
+	return queryCost{Depth: depth, Complexity: complexity}, true
+}
+
+// selectionSet returns the cost of set and the deepest field depth within it.
+func (a *costAnalyzer) selectionSet(parent graphql.Type, set *ast.SelectionSet, depth int, visiting map[string]bool) (int, int) {
+	if set == nil {
+		return 0, depth - 1
+	}
+
+	complexity, maxDepth := 0, depth-1
+	for _, selection := range set.Selections {
+		var cost, d int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cost, d = a.field(parent, selection, depth, visiting)
+		case *ast.InlineFragment:
+			fragmentType := parent
+			if selection.TypeCondition != nil {
+				if t := a.schema.Type(selection.TypeCondition.Name.Value); t != nil {
+					fragmentType = t
+				}
+			}
+			cost, d = a.selectionSet(fragmentType, selection.SelectionSet, depth, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := a.fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			fragmentType := parent
+			if t := a.schema.Type(fragment.TypeCondition.Name.Value); t != nil {
+				fragmentType = t
+			}
+			cost, d = a.selectionSet(fragmentType, fragment.SelectionSet, depth, visiting)
+			delete(visiting, name)
+		}
+		complexity += cost
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	return complexity, maxDepth
+}
+
+func (a *costAnalyzer) field(parent graphql.Type, field *ast.Field, depth int, visiting map[string]bool) (int, int) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return 0, depth - 1
+	}
+
+	var definition *graphql.FieldDefinition
+	switch parent := parent.(type) {
+	case *graphql.Object:
+		definition = parent.Fields()[name]
+	case *graphql.Interface:
+		definition = parent.Fields()[name]
+	}
+	if definition == nil {
+		return 0, depth
+	}
+
+	childType, _ := graphql.GetNamed(definition.Type).(graphql.Type)
+	childCost, childDepth := a.selectionSet(childType, field.SelectionSet, depth+1, visiting)
+	if field.SelectionSet == nil {
+		childDepth = depth
+	}
+
+	hint, ok := fieldCosts[parent.Name()+"."+name]
+	if !ok {
+		if field.SelectionSet != nil {
+			hint.Cost = 1
+		}
+		if isListType(definition.Type) {
+			hint.ListSize = defaultListSize
+		}
+	}
+
+	size := 1
+	if hint.ListSize > 0 {
+		size = hint.ListSize
+	}
+	if hint.SizeArg != "" {
+		if value, ok := a.intArgument(field, hint.SizeArg); ok {
+			size = value
+		}
+	}
+
+	return hint.Cost + size*childCost, childDepth
+}
+
+func (a *costAnalyzer) intArgument(field *ast.Field, name string) (int, bool) {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != name {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			n, err := strconv.Atoi(value.Value)
+			return n, err == nil
+		case *ast.Variable:
+			switch n := a.variables[value.Name.Value].(type) {
+			case int:
+				return n, true
+			case float64:
+				return int(n), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func isListType(ttype graphql.Type) bool {
+	if nonNull, ok := ttype.(*graphql.NonNull); ok {
+		ttype = nonNull.OfType
+	}
+	_, ok := ttype.(*graphql.List)
+	return ok
+}
+
+// checkQueryLimits rejects an operation that exceeds limits, returning the
+// result to send instead of executing it. Documents that cannot be parsed
+// are left for the executor to report.
+func checkQueryLimits(params graphql.Params, limits Limits) *graphql.Result {
+	if limits.MaxDepth <= 0 && limits.MaxComplexity <= 0 {
+		return nil
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: params.RequestString})
+	if err != nil {
+		return nil
+	}
+	cost, ok := analyzeQuery(&params.Schema, document, params.OperationName, params.VariableValues)
+	if !ok {
+		return nil
+	}
+
+	if limits.MaxDepth > 0 && cost.Depth > limits.MaxDepth {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message: fmt.Sprintf("query depth %d exceeds the maximum of %d", cost.Depth, limits.MaxDepth),
+			Extensions: map[string]interface{}{
+				"code":     "QUERY_TOO_DEEP",
+				"depth":    cost.Depth,
+				"maxDepth": limits.MaxDepth,
+			},
+		}}}
+	}
+	if limits.MaxComplexity > 0 && cost.Complexity > limits.MaxComplexity {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message: fmt.Sprintf("query complexity %d exceeds the maximum of %d", cost.Complexity, limits.MaxComplexity),
+			Extensions: map[string]interface{}{
+				"code":          "QUERY_TOO_COMPLEX",
+				"complexity":    cost.Complexity,
+				"maxComplexity": limits.MaxComplexity,
+			},
+		}}}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// batchLoader collects the keys requested while a level of the query is being
+// resolved and fetches them with a single call once the first value is
+// needed. Results are cached for the rest of the request.
+type batchLoader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	results map[K]*batchResult[V]
+}
+
+type batchResult[V any] struct {
+	value V
+	err   error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{fetch: fetch, results: map[K]*batchResult[V]{}}
+}
+
+// load queues key and returns a function that fetches every queued key the
+// first time any of them is needed. Resolvers hand it to graphql-go as a
+// thunk, which is only called once the rest of the current level is resolved.
+func (l *batchLoader[K, V]) load(key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.results[key] = &batchResult[V]{}
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.dispatch()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		result := l.results[key]
+		return result.value, result.err
+	}
+}
+
+func (l *batchLoader[K, V]) dispatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.pending) == 0 {
+		return
+	}
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.fetch(keys)
+	for _, key := range keys {
+		l.results[key] = &batchResult[V]{value: values[key], err: err}
+	}
+}
+
+// roomReservationsKey identifies the reservations of one room, optionally
+// limited to those overlapping a date range.
+type roomReservationsKey struct {
+	RoomID string
+	Start  string
+	End    string
+}
+
+// loaders are the batching loaders of a single GraphQL operation.
+type loaders struct {
+	rooms            *batchLoader[string, *Room]
+	roomReservations *batchLoader[roomReservationsKey, []Reservation]
+}
+
+func newLoaders(ctx context.Context, repos store.Repositories) *loaders {
+	return &loaders{
+		rooms:            newBatchLoader(fetchRooms(ctx, repos.Rooms)),
+		roomReservations: newBatchLoader(fetchRoomReservations(ctx, repos.Reservations)),
+	}
+}
+
+func fetchRooms(ctx context.Context, repository store.RoomRepository) func(ids []string) (map[string]*Room, error) {
+	return func(ids []string) (map[string]*Room, error) {
+		rooms, err := repository.Rooms(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		byID := make(map[string]*Room, len(rooms))
+		for i := range rooms {
+			byID[rooms[i].ID] = &rooms[i]
+		}
+		return byID, nil
+	}
+}
+
+func fetchRoomReservations(ctx context.Context, repository store.ReservationRepository) func(keys []roomReservationsKey) (map[roomReservationsKey][]Reservation, error) {
+	return func(keys []roomReservationsKey) (map[roomReservationsKey][]Reservation, error) {
+		// One query per distinct date range, covering every room asking for it.
+		type dateRange struct{ Start, End string }
+		roomsByRange := map[dateRange][]string{}
+		for _, key := range keys {
+			r := dateRange{key.Start, key.End}
+			roomsByRange[r] = append(roomsByRange[r], key.RoomID)
+		}
+
+		byKey := make(map[roomReservationsKey][]Reservation, len(keys))
+		for r, roomIDs := range roomsByRange {
+			reservations, err := repository.ListReservations(ctx, store.ReservationQuery{
+				ReservationFilter: store.ReservationFilter{RoomIDs: roomIDs, OverlapStart: r.Start, OverlapEnd: r.End},
+				SortField:         store.SortByCheckinDate,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, reservation := range reservations {
+				key := roomReservationsKey{RoomID: reservation.RoomID, Start: r.Start, End: r.End}
+				byKey[key] = append(byKey[key], reservation)
+			}
+		}
+		return byKey, nil
+	}
+}
+
+type loadersKey struct{}
+
+func loadersFrom(ctx context.Context) (*loaders, error) {
+	if ctx != nil {
+		if l, ok := ctx.Value(loadersKey{}).(*loaders); ok {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("no data loaders in the request context")
+}
+
+// loaderExtension gives every execution against the schema its own set of
+// loaders, so batching and caching never leak between requests or between
+// the events of a subscription.
+type loaderExtension struct {
+	repos store.Repositories
+}
+
+func (e loaderExtension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return ctx
+}
+
+func (e loaderExtension) Name() string {
+	return "loaders"
+}
+
+func (e loaderExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (e loaderExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (e loaderExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, loadersKey{}, newLoaders(ctx, e.repos)), func(*graphql.Result) {}
+}
+
+func (e loaderExtension) ResolveFieldDidStart(ctx context.Context, _ *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	return ctx, func(interface{}, error) {}
+}
+
+func (e loaderExtension) HasResult() bool {
+	return false
+}
+
+func (e loaderExtension) GetResult(context.Context) interface{} {
+	return nil
+}
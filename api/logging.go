@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/willsams/go-hotel-reservation-service/logging"
+)
+
+// logRequests gives every request a correlation id, the X-Request-Id it
+// came with or a new one, reports it in the response and logs the request
+// once it is served.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := logging.RequestIDOr(r.Header.Get(logging.RequestIDHeader))
+		w.Header().Set(logging.RequestIDHeader, id)
+		ctx := logging.WithRequestID(r.Context(), id)
+
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		logRequest(ctx, r.Method, r.URL.Path, recorder.statusCode, time.Since(start))
+	})
+}
+
+// logRequest logs a request served over HTTP or through API Gateway.
+// Server errors are logged as errors.
+func logRequest(ctx context.Context, method string, path string, statusCode int, duration time.Duration) {
+	level := slog.LevelInfo
+	if statusCode >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "request",
+		"method", method,
+		"path", path,
+		"status", statusCode,
+		"durationMs", logging.Milliseconds(duration),
+	)
+}
+
+// logOperation logs an executed GraphQL operation with the errors of its
+// result. An error raised by a resolver without a code is internal: the
+// operation is then logged as an error.
+func logOperation(ctx context.Context, operation *ast.OperationDefinition, result *graphql.Result, duration time.Duration) {
+	attrs := []interface{}{"durationMs", logging.Milliseconds(duration)}
+	if operation != nil {
+		attrs = append(attrs, "operationType", operation.Operation)
+		if operation.Name != nil {
+			attrs = append(attrs, "operationName", operation.Name.Value)
+		}
+	}
+
+	level := slog.LevelInfo
+	if len(result.Errors) > 0 {
+		errors := make([]string, len(result.Errors))
+		for i, err := range result.Errors {
+			errors[i] = err.Message
+			if _, coded := err.Extensions["code"]; len(err.Path) > 0 && !coded {
+				level = slog.LevelError
+				errors[i] = pathString(err.Path) + ": " + err.Message
+			}
+		}
+		attrs = append(attrs, "errors", errors)
+	}
+	logging.FromContext(ctx).Log(ctx, level, "graphql operation", attrs...)
+}
+
+// pathString writes a result path as rooms.0.id.
+func pathString(path []interface{}) string {
+	parts := make([]string, len(path))
+	for i, part := range path {
+		parts[i] = fmt.Sprint(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// logCalls is the gRPC counterpart of logRequests: it takes the correlation
+// id from the x-request-id metadata, sends it back in the response header
+// and logs each call.
+func logCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	var candidate string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(logging.RequestIDHeader); len(values) > 0 {
+			candidate = values[0]
+		}
+	}
+	id := logging.RequestIDOr(candidate)
+	grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, id))
+	ctx = logging.WithRequestID(ctx, id)
+
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown {
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "grpc call",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"durationMs", logging.Milliseconds(time.Since(start)),
+	)
+	return resp, err
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Outcomes of a GraphQL operation: it ran without errors, it ran with
+// errors, or it was rejected before running.
+const (
+	outcomeSuccess  = "success"
+	outcomeError    = "error"
+	outcomeRejected = "rejected"
+)
+
+// maxOperationNames bounds the operation label, which clients choose:
+// operations named after that many distinct names are counted as "other".
+const maxOperationNames = 100
+
+var (
+	requestsServed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "http_requests_total",
+		Help: "HTTP requests served, by status code.",
+	}, []string{"code"})
+	requestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "hotel", Name: "http_requests_in_flight",
+		Help: "HTTP requests being served.",
+	})
+	operationsServed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "graphql_operations_total",
+		Help: "GraphQL operations, by name, type and outcome: success, error or rejected.",
+	}, []string{"operation", "type", "outcome"})
+	operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hotel", Name: "graphql_operation_duration_seconds",
+		Help:    "Time to validate and execute GraphQL operations, by name and type.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+	resolverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "hotel", Name: "graphql_resolver_duration_seconds",
+		Help:    "Time spent in the resolvers of the schema, by field such as RootQuery.availableRooms.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"field"})
+	reservationsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "reservations_created_total",
+		Help: "Reservations booked.",
+	})
+	reservationsCancelled = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "reservations_cancelled_total",
+		Help: "Confirmed reservations cancelled.",
+	})
+	availabilitySearches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "availability_searches_total",
+		Help: "Availability searches, by result: found or empty.",
+	}, []string{"result"})
+	bookingConflicts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "booking_conflicts_total",
+		Help: "Bookings, changes and blocks refused because the room was taken for some of the nights.",
+	})
+	rateLimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "hotel", Name: "rate_limited_requests_total",
+		Help: "Searches and bookings refused because the client used up its budget, by budget.",
+	}, []string{"budget"})
+)
+
+// newRegistry gathers the metrics of the process, and the connection pool
+// statistics of repos when they run on a database.
+func newRegistry(repos store.Repositories) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsServed, requestsInFlight,
+		operationsServed, operationDuration, resolverDuration,
+		reservationsCreated, reservationsCancelled, availabilitySearches, bookingConflicts,
+		rateLimitedRequests,
+	)
+	if pooled, ok := repos.Reservations.(store.Pooled); ok {
+		registry.MustRegister(collectors.NewDBStatsCollector(pooled.DB().DB, "hotel"))
+	}
+	return registry
+}
+
+// observeOperation counts an executed GraphQL operation and records how
+// long it took.
+func observeOperation(operation *ast.OperationDefinition, result *graphql.Result, duration time.Duration) {
+	name, operationType := operationLabels(operation)
+	outcome := outcomeSuccess
+	switch {
+	case result.Data == nil && result.HasErrors():
+		outcome = outcomeRejected
+	case result.HasErrors():
+		outcome = outcomeError
+	}
+	operationsServed.WithLabelValues(name, operationType, outcome).Inc()
+	operationDuration.WithLabelValues(name, operationType).Observe(duration.Seconds())
+}
+
+var operationNames = struct {
+	sync.Mutex
+	seen map[string]bool
+}{seen: map[string]bool{}}
+
+// operationLabels returns the name and type labels of operation, which is
+// nil when the document cannot be parsed or the operation selected.
+func operationLabels(operation *ast.OperationDefinition) (string, string) {
+	if operation == nil {
+		return "unknown", "unknown"
+	}
+	if operation.Name == nil {
+		return "anonymous", operation.Operation
+	}
+
+	name := operation.Name.Value
+	operationNames.Lock()
+	defer operationNames.Unlock()
+	if !operationNames.seen[name] {
+		if len(operationNames.seen) >= maxOperationNames {
+			return "other", operation.Operation
+		}
+		operationNames.seen[name] = true
+	}
+	return name, operation.Operation
+}
+
+// metricsExtension records the time spent in each resolver of the schema.
+// Fields without a resolver of their own are left out: they only read a
+// value of their parent.
+type metricsExtension struct{}
+
+func (metricsExtension) Init(ctx context.Context, _ *graphql.Params) context.Context {
+	return ctx
+}
+
+func (metricsExtension) Name() string {
+	return "metrics"
+}
+
+func (metricsExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (metricsExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (metricsExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (metricsExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	parent, ok := info.ParentType.(*graphql.Object)
+	if !ok {
+		return ctx, func(interface{}, error) {}
+	}
+	field, ok := parent.Fields()[info.FieldName]
+	if !ok || field.Resolve == nil {
+		return ctx, func(interface{}, error) {}
+	}
+
+	start := time.Now()
+	return ctx, func(interface{}, error) {
+		resolverDuration.WithLabelValues(parent.Name() + "." + info.FieldName).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (metricsExtension) HasResult() bool {
+	return false
+}
+
+func (metricsExtension) GetResult(context.Context) interface{} {
+	return nil
+}
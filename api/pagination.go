@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	// DefaultPageSize is the page size used when a connection is requested without first.
+	DefaultPageSize = 25
+	// MaxPageSize is the largest page a client may request.
+	MaxPageSize = 100
+)
+
+// PageInfo describes a page of a Relay connection.
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+var sortDirectionType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SortDirection",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: "asc"},
+		"DESC": &graphql.EnumValueConfig{Value: "desc"},
+	},
+})
+
+// cursor is the position of an edge within a sorted connection. It records
+// the sort field so that a cursor cannot be replayed against another order.
+type cursor struct {
+	Field string `json:"f"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, invalidInput("invalid cursor %q", value)
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, invalidInput("invalid cursor %q", value)
+	}
+	return c, nil
+}
+
+// pageSizeArg reads the first argument of a connection field.
+func pageSizeArg(args map[string]interface{}) (int, error) {
+	first, ok := args["first"].(int)
+	if !ok {
+		return DefaultPageSize, nil
+	}
+	if first < 0 {
+		return 0, invalidInput("first must not be negative")
+	}
+	if first > MaxPageSize {
+		return 0, invalidInput("first must not exceed %d", MaxPageSize)
+	}
+	return first, nil
+}
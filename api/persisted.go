@@ -0,0 +1,219 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+)
+
+// Persisted query modes.
+const (
+	// PersistedQueriesOff ignores persisted query hashes altogether.
+	PersistedQueriesOff = config.PersistedQueriesOff
+	// PersistedQueriesAutomatic serves allow-listed operations, registers
+	// automatic persisted queries and still accepts any other operation.
+	PersistedQueriesAutomatic = config.PersistedQueriesAutomatic
+	// PersistedQueriesAllowList only executes allow-listed operations.
+	PersistedQueriesAllowList = config.PersistedQueriesAllowList
+)
+
+// PersistedQueryCache stores automatic persisted queries by their SHA-256
+// hash. Implementations backed by a shared store let every instance serve
+// a hash registered with any of them.
+type PersistedQueryCache interface {
+	Get(ctx context.Context, hash string) (string, bool, error)
+	Set(ctx context.Context, hash string, query string) error
+}
+
+// MemoryQueryCache is a PersistedQueryCache that keeps the most recently
+// used queries in memory.
+type MemoryQueryCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type memoryQueryCacheEntry struct {
+	hash  string
+	query string
+}
+
+func NewMemoryQueryCache(capacity int) *MemoryQueryCache {
+	return &MemoryQueryCache{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *MemoryQueryCache) Get(_ context.Context, hash string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[hash]
+	if !ok {
+		return "", false, nil
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*memoryQueryCacheEntry).query, true, nil
+}
+
+func (c *MemoryQueryCache) Set(_ context.Context, hash string, query string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[hash] = c.order.PushFront(&memoryQueryCacheEntry{hash: hash, query: query})
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryQueryCacheEntry).hash)
+	}
+	return nil
+}
+
+// PersistedQueryStore resolves persisted query hashes to operations.
+type PersistedQueryStore struct {
+	Mode      string
+	AllowList map[string]string
+	Cache     PersistedQueryCache
+}
+
+// NewPersistedQueryStore builds the store for cfg, loading the allow-list
+// from cfg.File when it is set.
+func NewPersistedQueryStore(cfg config.PersistedQueries) (*PersistedQueryStore, error) {
+	store := &PersistedQueryStore{Mode: cfg.Mode, Cache: NewMemoryQueryCache(1000)}
+	if cfg.File != "" {
+		allowList, err := LoadPersistedQueries(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		store.AllowList = allowList
+	}
+	if cfg.Mode == PersistedQueriesAllowList && len(store.AllowList) == 0 {
+		return nil, fmt.Errorf("persisted query mode %s requires a non-empty allow-list", cfg.Mode)
+	}
+	return store, nil
+}
+
+// LoadPersistedQueries reads an allow-list of operations keyed by the hex
+// SHA-256 hash of their text. The file is either a JSON object of hash to
+// operation or an Apollo persisted query manifest.
+func LoadPersistedQueries(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Operations []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	queries := map[string]string{}
+	if err := json.Unmarshal(data, &manifest); err == nil && manifest.Operations != nil {
+		for _, operation := range manifest.Operations {
+			queries[operation.ID] = operation.Body
+		}
+	} else if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("%s: persisted queries must be a JSON object of hash to query or an Apollo manifest: %w", path, err)
+	}
+
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return nil, fmt.Errorf("%s: hash %s does not match its query", path, hash)
+		}
+	}
+	return queries, nil
+}
+
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// persistedQueryExtension reads extensions.persistedQuery from a request.
+func persistedQueryExtension(req GraphQLRequest) (version float64, hash string, ok bool) {
+	extension, ok := req.Extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return 0, "", false
+	}
+	version, _ = extension["version"].(float64)
+	hash, _ = extension["sha256Hash"].(string)
+	return version, hash, true
+}
+
+func hasPersistedQuery(req GraphQLRequest) bool {
+	_, _, ok := persistedQueryExtension(req)
+	return ok
+}
+
+func persistedQueryError(message string, code string) *gqlerrors.FormattedError {
+	return &gqlerrors.FormattedError{Message: message, Extensions: map[string]interface{}{"code": code}}
+}
+
+// resolve fills in the query of a request that only carries a persisted
+// query hash, registers automatic persisted queries and enforces the
+// allow-list.
+func (s *PersistedQueryStore) resolve(ctx context.Context, req GraphQLRequest) (GraphQLRequest, *gqlerrors.FormattedError) {
+	if s == nil || s.Mode == PersistedQueriesOff {
+		return req, nil
+	}
+
+	version, hash, ok := persistedQueryExtension(req)
+	if !ok {
+		if s.Mode == PersistedQueriesAllowList {
+			if _, listed := s.AllowList[queryHash(req.Query)]; !listed {
+				return req, persistedQueryError("only persisted operations may be executed", "PERSISTED_QUERY_REQUIRED")
+			}
+		}
+		return req, nil
+	}
+	if version != 1 {
+		return req, persistedQueryError("unsupported persisted query version", "PERSISTED_QUERY_VERSION_NOT_SUPPORTED")
+	}
+
+	if req.Query == "" {
+		if query, listed := s.AllowList[hash]; listed {
+			req.Query = query
+			return req, nil
+		}
+		if s.Mode == PersistedQueriesAutomatic && s.Cache != nil {
+			query, found, err := s.Cache.Get(ctx, hash)
+			if err != nil {
+				return req, persistedQueryError(err.Error(), "INTERNAL_SERVER_ERROR")
+			}
+			if found {
+				req.Query = query
+				return req, nil
+			}
+		}
+		return req, persistedQueryError("PersistedQueryNotFound", "PERSISTED_QUERY_NOT_FOUND")
+	}
+
+	if queryHash(req.Query) != hash {
+		return req, persistedQueryError("provided sha256Hash does not match the query", "PERSISTED_QUERY_HASH_MISMATCH")
+	}
+	if _, listed := s.AllowList[hash]; listed {
+		return req, nil
+	}
+	if s.Mode == PersistedQueriesAllowList {
+		return req, persistedQueryError("only persisted operations may be executed", "PERSISTED_QUERY_REQUIRED")
+	}
+	if s.Cache != nil {
+		if err := s.Cache.Set(ctx, hash, req.Query); err != nil {
+			return req, persistedQueryError(err.Error(), "INTERNAL_SERVER_ERROR")
+		}
+	}
+	return req, nil
+}
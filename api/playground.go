@@ -0,0 +1,25 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// playgroundPage is the GraphQL Playground, which sends its queries and
+// subscriptions to /api.
+//
+//go:embed playground.html
+var playgroundPage []byte
+
+// servePlayground serves the playground page. It only loads the page: every
+// query it sends goes through /api, with the limits, persisted queries,
+// authentication and rate limits of the other callers.
+func servePlayground(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(playgroundPage)
+}
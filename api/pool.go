@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/tracing"
+)
+
+// healthCheckInterval is how long the pool may sit unused before it is
+// pinged again.
+const healthCheckInterval = time.Minute
+
+// poolHealthCheck pings a pool that has not been used for interval before
+// it is used again. A Lambda environment thawed after a while may hold
+// connections the database has since dropped, and the ping makes
+// database/sql replace them before a resolver trips over them.
+type poolHealthCheck struct {
+	pinger   store.Pinger
+	interval time.Duration
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+// newPoolHealthCheck returns a health check of pinger. Repositories without
+// a connection to go stale pass nil and are never checked.
+func newPoolHealthCheck(pinger store.Pinger) *poolHealthCheck {
+	return &poolHealthCheck{pinger: pinger, interval: healthCheckInterval, lastUsed: time.Now()}
+}
+
+func (c *poolHealthCheck) check(ctx context.Context) error {
+	if c.pinger == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	stale := time.Since(c.lastUsed) > c.interval
+	c.lastUsed = time.Now()
+	c.mu.Unlock()
+
+	if !stale {
+		return nil
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "ping database")
+	defer span.End()
+	err := c.pinger.Ping(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/ratelimit"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Headers reporting the rate limit of a response, as drafted by the IETF
+// httpapi working group, and the seconds to wait after a refusal.
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+)
+
+// newRateLimiter returns the limiter of limits, which keeps its buckets on
+// the database of repos when configured to and able, or nil when nothing
+// is limited.
+func newRateLimiter(limits config.RateLimit, repos store.Repositories) *ratelimit.Limiter {
+	if limits.SearchesPerMinute <= 0 && limits.BookingsPerMinute <= 0 {
+		return nil
+	}
+	var buckets ratelimit.Store = ratelimit.NewMemory()
+	if limits.Store == config.RateLimitDatabase {
+		if shared, ok := repos.Reservations.(ratelimit.Store); ok {
+			buckets = shared
+		} else {
+			logging.Logger().Warn("the store cannot share rate limits, keeping them in memory")
+		}
+	}
+	return ratelimit.New(buckets, map[string]ratelimit.Limit{
+		ratelimit.Searches: ratelimit.PerMinute(limits.SearchesPerMinute, limits.SearchBurst),
+		ratelimit.Bookings: ratelimit.PerMinute(limits.BookingsPerMinute, limits.BookingBurst),
+	})
+}
+
+type rateLimitingKey struct{}
+
+// rateLimiting is the limiter of a request and the address it came from.
+// It records the decisions taken for the request so that the response can
+// report them.
+type rateLimiting struct {
+	limiter *ratelimit.Limiter
+	address string
+
+	mu       sync.Mutex
+	decision *ratelimit.Decision
+}
+
+// withRateLimiting returns ctx limiting the resolvers it reaches with
+// limiter, for a request from address. A nil limiter limits nothing.
+func withRateLimiting(ctx context.Context, limiter *ratelimit.Limiter, address string) (context.Context, *rateLimiting) {
+	if limiter == nil {
+		return ctx, nil
+	}
+	limiting := &rateLimiting{limiter: limiter, address: address}
+	return context.WithValue(ctx, rateLimitingKey{}, limiting), limiting
+}
+
+// takeToken takes a token of budget from the caller of ctx, failing with
+// ErrRateLimited once the caller has used the budget up. Calls outside a
+// rate limited request, such as those of hotelctl, are not limited, and
+// neither are calls while the buckets cannot be reached: an outage of the
+// store should not take the API down with it.
+func takeToken(ctx context.Context, budget string) error {
+	limiting, _ := ctx.Value(rateLimitingKey{}).(*rateLimiting)
+	if limiting == nil {
+		return nil
+	}
+	client := rateLimitClient(ctx, limiting.address)
+	decision, err := limiting.limiter.Take(ctx, budget, client)
+	if err != nil {
+		logging.FromContext(ctx).Error("rate limit check failed", "budget", budget, "error", err.Error())
+		return nil
+	}
+	limiting.record(decision)
+	if decision.Allowed {
+		return nil
+	}
+	rateLimitedRequests.WithLabelValues(budget).Inc()
+	logging.FromContext(ctx).Warn("rate limited", "budget", budget, "client", client)
+	return rateLimited("too many %s, retry in %ds", budget, ceilSeconds(decision.RetryAfter))
+}
+
+// rateLimitClient is who the buckets of the caller of ctx belong to: its
+// API key or JWT subject, or else the address it called from.
+func rateLimitClient(ctx context.Context, address string) string {
+	if principal := auth.FromContext(ctx); principal != nil {
+		return principal.ID()
+	}
+	return "ip:" + address
+}
+
+// record keeps the decision the response reports: a refusal, else that of
+// the budget closest to running out.
+func (l *rateLimiting) record(decision ratelimit.Decision) {
+	if decision.Limit == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.decision == nil || l.decision.Allowed && (!decision.Allowed || decision.Remaining < l.decision.Remaining) {
+		l.decision = &decision
+	}
+}
+
+// header returns the rate limit headers of the recorded decision, none
+// when no budget was limited.
+func (l *rateLimiting) header() http.Header {
+	header := http.Header{}
+	if l == nil {
+		return header
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.decision == nil {
+		return header
+	}
+	header.Set(rateLimitLimitHeader, strconv.Itoa(l.decision.Limit))
+	header.Set(rateLimitRemainingHeader, strconv.Itoa(l.decision.Remaining))
+	header.Set(rateLimitResetHeader, strconv.Itoa(ceilSeconds(l.decision.Reset)))
+	if !l.decision.Allowed {
+		header.Set(retryAfterHeader, strconv.Itoa(ceilSeconds(l.decision.RetryAfter)))
+	}
+	return header
+}
+
+// report adds the rate limit headers to response.
+func (l *rateLimiting) report(response httpResponse) httpResponse {
+	header := l.header()
+	if len(header) == 0 {
+		return response
+	}
+	if response.Header == nil {
+		response.Header = http.Header{}
+	}
+	for key, values := range header {
+		response.Header[key] = values
+	}
+	return response
+}
+
+// limitCalls returns the gRPC interceptor limiting calls with limiter by
+// the address of the peer, and reporting the rate limits in the
+// ratelimit-limit, ratelimit-remaining, ratelimit-reset and retry-after
+// header metadata.
+func limitCalls(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var address string
+		if p, ok := peer.FromContext(ctx); ok {
+			address = remoteHost(p.Addr.String())
+		}
+		ctx, limiting := withRateLimiting(ctx, limiter, address)
+		resp, err := handler(ctx, req)
+		if header := limiting.header(); len(header) > 0 {
+			md := metadata.MD{}
+			for key, values := range header {
+				md.Set(key, values...)
+			}
+			grpc.SetHeader(ctx, md)
+		}
+		return resp, err
+	}
+}
+
+// remoteHost is the host of a host:port address.
+func remoteHost(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// ceilSeconds is d in whole seconds, rounded up.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,31 +1,207 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/graphql-go/graphql"
-	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/ratelimit"
+	"github.com/willsams/go-hotel-reservation-service/store"
 )
 
-type Reservation struct {
-	ID           string  `db:"id"`
-	RoomID       string  `db:"room_id"`
-	CheckinDate  string  `db:"checkin_date"`
-	CheckoutDate string  `db:"checkout_date"`
-	TotalCharge  float64 `db:"total_charge"`
-}
+const (
+	ReservationConfirmed = store.ReservationConfirmed
+	ReservationCancelled = store.ReservationCancelled
+	ReservationBlocked   = store.ReservationBlocked
+)
+
+type Reservation = store.Reservation
+
+var reservationStatusType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ReservationStatus",
+	Values: graphql.EnumValueConfigMap{
+		"CONFIRMED": &graphql.EnumValueConfig{Value: ReservationConfirmed},
+		"CANCELLED": &graphql.EnumValueConfig{Value: ReservationCancelled},
+		"BLOCKED": &graphql.EnumValueConfig{
+			Value:       ReservationBlocked,
+			Description: "The room is out of service for the nights of the reservation",
+		},
+	},
+})
 
 var reservationType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Reservation",
 	Fields: graphql.Fields{
 		"Id":           &graphql.Field{Type: graphql.String},
 		"RoomId":       &graphql.Field{Type: graphql.String},
-		"CheckinDate":  &graphql.Field{Type: graphql.String},
-		"CheckoutDate": &graphql.Field{Type: graphql.String},
+		"CheckinDate":  &graphql.Field{Type: DateScalar},
+		"CheckoutDate": &graphql.Field{Type: DateScalar},
 		"TotalCharge":  &graphql.Field{Type: graphql.Float},
+		"Status":       &graphql.Field{Type: reservationStatusType},
+		"GuestId": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Who made the reservation, jwt:SUBJECT for a token or api-key:NAME for an API key; null when anonymous",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if reservation, ok := reservationSource(params.Source); ok && reservation.GuestID != "" {
+					return reservation.GuestID, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+func init() {
+	reservationType.AddFieldConfig("room", &graphql.Field{
+		Type:        roomType,
+		Description: "The room that was booked",
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			reservation, ok := reservationSource(params.Source)
+			if !ok {
+				return nil, nil
+			}
+			l, err := loadersFrom(params.Context)
+			if err != nil {
+				return nil, err
+			}
+
+			load := l.rooms.load(reservation.RoomID)
+			return func() (interface{}, error) {
+				room, err := load()
+				if room == nil || err != nil {
+					return nil, err
+				}
+				return room, nil
+			}, nil
+		},
+	})
+}
+
+func reservationSource(source interface{}) (Reservation, bool) {
+	switch reservation := source.(type) {
+	case Reservation:
+		return reservation, true
+	case *Reservation:
+		if reservation != nil {
+			return *reservation, true
+		}
+	}
+	return Reservation{}, false
+}
+
+// ReservationConnection is a page of reservations in the Relay connection format.
+type ReservationConnection struct {
+	Edges    []ReservationEdge
+	PageInfo PageInfo
+
+	totalCount func() (int, error)
+}
+
+type ReservationEdge struct {
+	Cursor string
+	Node   Reservation
+}
+
+var reservationEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReservationEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: reservationType},
 	},
 })
 
+var reservationConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReservationConnection",
+	Fields: graphql.Fields{
+		"edges":    &graphql.Field{Type: graphql.NewList(reservationEdgeType)},
+		"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		"totalCount": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Int),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				connection, _ := params.Source.(*ReservationConnection)
+				return connection.totalCount()
+			},
+		},
+	},
+})
+
+var dateRangeInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "DateRangeInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"start": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(DateScalar)},
+		"end":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(DateScalar)},
+	},
+})
+
+var reservationFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReservationFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"roomIds": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Only reservations for these rooms",
+		},
+		"overlaps": &graphql.InputObjectFieldConfig{
+			Type:        dateRangeInputType,
+			Description: "Only reservations with at least one night between start and end",
+		},
+		"status": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(reservationStatusType)),
+			Description: "Only reservations with one of these statuses",
+		},
+	},
+})
+
+// reservationSortFields are the fields reservations can be sorted by.
+var reservationSortFields = map[string]bool{
+	store.SortByID:           true,
+	store.SortByCheckinDate:  true,
+	store.SortByCheckoutDate: true,
+	store.SortByTotalCharge:  true,
+}
+
+var reservationSortFieldType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ReservationSortField",
+	Values: graphql.EnumValueConfigMap{
+		"ID":            &graphql.EnumValueConfig{Value: store.SortByID},
+		"CHECKIN_DATE":  &graphql.EnumValueConfig{Value: store.SortByCheckinDate},
+		"CHECKOUT_DATE": &graphql.EnumValueConfig{Value: store.SortByCheckoutDate},
+		"TOTAL_CHARGE":  &graphql.EnumValueConfig{Value: store.SortByTotalCharge},
+	},
+})
+
+var reservationOrderType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ReservationOrder",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field": &graphql.InputObjectFieldConfig{
+			Type:         reservationSortFieldType,
+			DefaultValue: "id",
+		},
+		"direction": &graphql.InputObjectFieldConfig{
+			Type:         sortDirectionType,
+			DefaultValue: "asc",
+		},
+	},
+})
+
+var reservationsArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: fmt.Sprintf("Page size, at most %d (default %d)", MaxPageSize, DefaultPageSize),
+	},
+	"after": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "Return reservations after this cursor",
+	},
+	"filter":  &graphql.ArgumentConfig{Type: reservationFilterType},
+	"orderBy": &graphql.ArgumentConfig{Type: reservationOrderType},
+}
+
 var ReservationInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 	Name: "ReservationInput",
 	Fields: graphql.InputObjectConfigFieldMap{
@@ -33,24 +209,92 @@ var ReservationInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 			Type: graphql.NewNonNull(graphql.String),
 		},
 		"CheckinDate": &graphql.InputObjectFieldConfig{
-			Type: graphql.NewNonNull(graphql.String),
+			Type: graphql.NewNonNull(DateScalar),
 		},
 		"CheckoutDate": &graphql.InputObjectFieldConfig{
-			Type: graphql.NewNonNull(graphql.String),
+			Type: graphql.NewNonNull(DateScalar),
 		},
 		"TotalCharge": &graphql.InputObjectFieldConfig{
-			Type: graphql.NewNonNull(graphql.Float),
+			Type:        graphql.Float,
+			Description: "Set by the staff only, as a whole amount; guests pay the price of the stay",
 		},
 	},
 })
 
-func GetReservation(db *sqlx.DB) func(params graphql.ResolveParams) (interface{}, error) {
+var ReservationUpdateInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "ReservationUpdateInput",
+	Description: "Changes to a reservation; omitted fields keep their current value, except that a moved stay is repriced",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"RoomID":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"CheckinDate":  &graphql.InputObjectFieldConfig{Type: DateScalar},
+		"CheckoutDate": &graphql.InputObjectFieldConfig{Type: DateScalar},
+		"TotalCharge":  &graphql.InputObjectFieldConfig{Type: graphql.Float},
+	},
+})
+
+// resolveContext is the context of the operation being resolved. Direct
+// callers of the resolvers may leave it out.
+func resolveContext(params graphql.ResolveParams) context.Context {
+	if params.Context == nil {
+		return context.Background()
+	}
+	return params.Context
+}
+
+// isRoomAvailable asks reservations whether the room is free for the stay,
+// counting the conflicts it finds.
+func isRoomAvailable(ctx context.Context, reservations store.ReservationRepository, roomID string, checkinDate string, checkoutDate string, exceptID string) (bool, error) {
+	available, err := reservations.IsRoomAvailable(ctx, roomID, checkinDate, checkoutDate, exceptID)
+	if err == nil && !available {
+		bookingConflicts.Inc()
+	}
+	return available, err
+}
+
+// findReservation returns the reservation with id, or an ErrNotFound error.
+func findReservation(ctx context.Context, reservations store.ReservationRepository, id string) (Reservation, error) {
+	reservation, err := reservations.Reservation(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return reservation, notFound("reservation %s does not exist", id)
+	}
+	return reservation, err
+}
+
+// findOwnReservation is findReservation for a caller allowed permission all
+// on every reservation, or own on its own. The reservations of other guests
+// are reported as not found, so that callers cannot learn of them.
+func findOwnReservation(ctx context.Context, reservations store.ReservationRepository, id string, all auth.Permission, own auth.Permission) (Reservation, error) {
+	owner, err := reservationOwner(ctx, all, own)
+	if err != nil {
+		return Reservation{}, err
+	}
+	reservation, err := findReservation(ctx, reservations, id)
+	if err == nil && owner != "" && reservation.GuestID != owner {
+		return Reservation{}, notFound("reservation %s does not exist", id)
+	}
+	return reservation, err
+}
+
+// saveError classifies the errors of writing reservation.
+func saveError(err error, reservation Reservation) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return notFound("room %s does not exist", reservation.RoomID)
+	case errors.Is(err, store.ErrConflict):
+		return conflict("reservation dates overlap with an existing reservation")
+	}
+	return err
+}
+
+// GetReservation resolves the reservation with the id argument, or null
+// when there is none or it is another guest's.
+func GetReservation(reservations store.ReservationRepository) func(params graphql.ResolveParams) (interface{}, error) {
 	return func(params graphql.ResolveParams) (interface{}, error) {
-		roomId := params.Args["roomId"].(string)
-		checkinDate := params.Args["roomId"].(string)
-		checkoutDate := params.Args["roomId"].(string)
-		var reservation Reservation
-		err := db.Get(&reservation, "select id, room_id, checkin_date, checkout_date, total_charge from reservations room_id = $1 and checkin_date = $2 and checkout_date = $3 order by 1", roomId, checkinDate, checkoutDate)
+		id, _ := params.Args["id"].(string)
+		reservation, err := findOwnReservation(resolveContext(params), reservations, id, auth.ReadReservations, auth.ReadOwnReservations)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -58,66 +302,441 @@ func GetReservation(db *sqlx.DB) func(params graphql.ResolveParams) (interface{}
 	}
 }
 
-func GetAllReservations(db *sqlx.DB) func(params graphql.ResolveParams) (interface{}, error) {
+// reservationQuery is a filtered, sorted page of reservations.
+type reservationQuery struct {
+	RoomIDs      []string
+	OverlapStart *time.Time
+	OverlapEnd   *time.Time
+	Statuses     []string
+	GuestID      string
+	SortField    string
+	Descending   bool
+	First        int
+	After        *cursor
+}
+
+func reservationQueryArgs(args map[string]interface{}) (reservationQuery, error) {
+	query := reservationQuery{SortField: store.SortByID}
+
+	first, err := pageSizeArg(args)
+	if err != nil {
+		return query, err
+	}
+	query.First = first
+
+	if filter, ok := args["filter"].(map[string]interface{}); ok {
+		query.RoomIDs = stringList(filter["roomIds"])
+		query.Statuses = stringList(filter["status"])
+
+		if overlaps, ok := filter["overlaps"].(map[string]interface{}); ok {
+			start, err := dateArg(overlaps, "start")
+			if err != nil {
+				return query, err
+			}
+			end, err := dateArg(overlaps, "end")
+			if err != nil {
+				return query, err
+			}
+			if !end.After(start) {
+				return query, invalidInput("overlaps end %s must be after start %s", end.Format(dateLayout), start.Format(dateLayout))
+			}
+			query.OverlapStart, query.OverlapEnd = &start, &end
+		}
+	}
+
+	if orderBy, ok := args["orderBy"].(map[string]interface{}); ok {
+		if field, ok := orderBy["field"].(string); ok {
+			if !reservationSortFields[field] {
+				return query, invalidInput("cannot sort reservations by %q", field)
+			}
+			query.SortField = field
+		}
+		query.Descending = orderBy["direction"] == "desc"
+	}
+
+	if after, ok := args["after"].(string); ok && after != "" {
+		c, err := decodeCursor(after)
+		if err != nil {
+			return query, err
+		}
+		if c.Field != query.SortField {
+			return query, invalidInput("cursor %q was issued for a different orderBy", after)
+		}
+		query.After = &c
+	}
+
+	return query, nil
+}
+
+func stringList(value interface{}) []string {
+	values, _ := value.([]interface{})
+	list := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// filter is the part of the query selecting reservations.
+func (q reservationQuery) filter() store.ReservationFilter {
+	filter := store.ReservationFilter{RoomIDs: q.RoomIDs, Statuses: q.Statuses, GuestID: q.GuestID}
+	if q.OverlapStart != nil && q.OverlapEnd != nil {
+		filter.OverlapStart = q.OverlapStart.Format(dateLayout)
+		filter.OverlapEnd = q.OverlapEnd.Format(dateLayout)
+	}
+	return filter
+}
+
+func reservationSortValue(reservation Reservation, field string) string {
+	switch field {
+	case store.SortByCheckinDate:
+		return reservation.CheckinDate
+	case store.SortByCheckoutDate:
+		return reservation.CheckoutDate
+	case store.SortByTotalCharge:
+		return strconv.FormatFloat(reservation.TotalCharge, 'f', -1, 64)
+	default:
+		return reservation.ID
+	}
+}
+
+func queryReservations(ctx context.Context, repository store.ReservationRepository, query reservationQuery) (*ReservationConnection, error) {
+	filter := query.filter()
+	page := store.ReservationQuery{
+		ReservationFilter: filter,
+		SortField:         query.SortField,
+		Descending:        query.Descending,
+		// Fetch one extra reservation to learn whether another page follows.
+		Limit: query.First + 1,
+	}
+	if query.After != nil {
+		page.After = &store.Position{Value: query.After.Value, ID: query.After.ID}
+	}
+
+	reservations, err := repository.ListReservations(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &ReservationConnection{
+		Edges: []ReservationEdge{},
+		totalCount: func() (int, error) {
+			return repository.CountReservations(ctx, filter)
+		},
+	}
+	connection.PageInfo.HasPreviousPage = query.After != nil
+	if len(reservations) > query.First {
+		reservations = reservations[:query.First]
+		connection.PageInfo.HasNextPage = true
+	}
+
+	for _, reservation := range reservations {
+		connection.Edges = append(connection.Edges, ReservationEdge{
+			Cursor: encodeCursor(cursor{
+				Field: query.SortField,
+				Value: reservationSortValue(reservation, query.SortField),
+				ID:    reservation.ID,
+			}),
+			Node: reservation,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+// GetAllReservations resolves a page of reservations matching the filter,
+// sort and cursor arguments of the reservations query. Guests only get
+// their own.
+func GetAllReservations(reservations store.ReservationRepository) func(params graphql.ResolveParams) (interface{}, error) {
 	return func(params graphql.ResolveParams) (interface{}, error) {
-		var reservations []Reservation
-		err := db.Select(&reservations, "select id, room_id, checkin_date, checkout_date, total_charge from reservations order by 1")
+		ctx := resolveContext(params)
+		owner, err := reservationOwner(ctx, auth.ReadReservations, auth.ReadOwnReservations)
 		if err != nil {
 			return nil, err
 		}
-		return reservations, nil
+		query, err := reservationQueryArgs(params.Args)
+		if err != nil {
+			return nil, err
+		}
+		query.GuestID = owner
+		return queryReservations(ctx, reservations, query)
 	}
 }
 
-func isRoomAvailable(db *sqlx.DB, roomID string, checkinDate string, checkoutDate string) (bool, error) {
-	var count int
+// reservationArgs flattens the createReservation and updateReservation
+// arguments. The schema delivers them inside an input object, while direct
+// callers pass roomId, checkinDate, checkoutDate and totalCharge at the top
+// level. Fields missing from the input are left out.
+func reservationArgs(args map[string]interface{}) map[string]interface{} {
+	input, ok := args["input"].(map[string]interface{})
+	if !ok {
+		return args
+	}
+	flattened := map[string]interface{}{}
+	for field, arg := range map[string]string{
+		"RoomID":       "roomId",
+		"CheckinDate":  "checkinDate",
+		"CheckoutDate": "checkoutDate",
+		"TotalCharge":  "totalCharge",
+	} {
+		if value, ok := input[field]; ok && value != nil {
+			flattened[arg] = value
+		}
+	}
+	return flattened
+}
 
-	err := db.Get(&count, `
-		SELECT COUNT(*) 
-		FROM reservations 
-		WHERE room_id = $1 
-		AND (
-			(checkin_date >= $2 AND checkin_date < $3) OR 
-			(checkout_date > $2 AND checkout_date <= $3) OR 
-			(checkin_date <= $2 AND checkout_date >= $3)
-		)
-    `, roomID, checkinDate, checkoutDate)
+// chargeArg returns the totalCharge argument of args, if any. Only the
+// staff may set a charge: the front desk, who manage every reservation,
+// and admins, who set the rates. Guests are refused it with ErrForbidden,
+// and charged the price of the stay instead.
+func chargeArg(ctx context.Context, args map[string]interface{}) (float64, bool, error) {
+	charge, ok := args["totalCharge"].(float64)
+	if !ok {
+		return 0, false, nil
+	}
+	if authorize(ctx, auth.ManageReservations) != nil && authorize(ctx, auth.SetRoomRates) != nil {
+		return 0, false, forbidden("only the staff may set totalCharge, leave it out to pay the price of the stay")
+	}
+	if err := checkWholeAmount("totalCharge", charge); err != nil {
+		return 0, false, err
+	}
+	return charge, true, nil
+}
 
-	if err != nil {
-		return false, nil
+// checkWholeAmount refuses an amount with a fraction, which the integer
+// columns of the Postgres store cannot keep, so that every store keeps the
+// same amounts.
+func checkWholeAmount(name string, amount float64) error {
+	if amount != math.Trunc(amount) {
+		return invalidInput("%s must be a whole amount, not %v", name, amount)
 	}
+	return nil
+}
 
-	return count == 0, nil
+// stayCharge prices a stay in the room at its current rates, as
+// availableRooms does.
+func stayCharge(ctx context.Context, rooms store.RoomRepository, roomID string, checkin time.Time, checkout time.Time) (float64, error) {
+	found, err := rooms.Rooms(ctx, []string{roomID})
+	if err != nil {
+		return 0, err
+	}
+	if len(found) == 0 {
+		return 0, notFound("room %s does not exist", roomID)
+	}
+	return found[0].DailyRate*float64(nightsBetween(checkin, checkout)) + found[0].CleaningFee, nil
 }
 
-func CreateReservation(db *sqlx.DB) func(graphql.ResolveParams) (interface{}, error) {
+// CreateReservation books a room for the caller, who owns the reservation,
+// at the price of the stay unless the staff set another charge.
+func CreateReservation(rooms store.RoomRepository, reservations store.ReservationRepository) func(graphql.ResolveParams) (interface{}, error) {
 	return func(p graphql.ResolveParams) (interface{}, error) {
-		roomID := p.Args["roomId"].(string)
+		ctx := resolveContext(p)
+		if err := authorize(ctx, auth.ManageOwnReservations); err != nil {
+			return nil, err
+		}
+		if err := takeToken(ctx, ratelimit.Bookings); err != nil {
+			return nil, err
+		}
+		args := reservationArgs(p.Args)
+		roomID, _ := args["roomId"].(string)
+		if roomID == "" {
+			return nil, invalidInput("roomId is required")
+		}
+		totalCharge, charged, err := chargeArg(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+
+		checkin, checkout, err := stayArgs(args, "checkinDate", "checkoutDate")
+		if err != nil {
+			return nil, err
+		}
+		if !charged {
+			if totalCharge, err = stayCharge(ctx, rooms, roomID, checkin, checkout); err != nil {
+				return nil, err
+			}
+		}
+		checkinDate := checkin.Format(dateLayout)
+		checkoutDate := checkout.Format(dateLayout)
 
 		// If there are any overlapping reservations, return an error
-		available, err := isRoomAvailable(db, roomID, p.Args["checkinDate"].(string), p.Args["checkoutDate"].(string))
+		available, err := isRoomAvailable(ctx, reservations, roomID, checkinDate, checkoutDate, "")
+		if err != nil {
+			return nil, err
+		}
 		if !available {
-			return nil, fmt.Errorf("reservation dates overlap with an existing reservation")
+			return nil, conflict("reservation dates overlap with an existing reservation")
 		}
-		checkinDate := p.Args["checkinDate"].(string)
-		checkoutDate := p.Args["checkoutDate"].(string)
-		totalCharge := p.Args["totalCharge"].(float64)
 
 		reservation := Reservation{
 			RoomID:       roomID,
 			CheckinDate:  checkinDate,
 			CheckoutDate: checkoutDate,
 			TotalCharge:  totalCharge,
+			Status:       ReservationConfirmed,
+			GuestID:      guestOf(ctx),
+		}
+
+		reservation, err = reservations.CreateReservation(ctx, reservation)
+		if err != nil {
+			return nil, saveError(err, reservation)
+		}
+
+		reservationsCreated.Inc()
+		publishReservationEvent(ReservationCreatedAction, reservation)
+		return reservation, nil
+	}
+}
+
+// UpdateReservation moves a confirmed reservation to other dates or another
+// room, or changes its charge, provided the room is free for the new stay.
+// A new stay is repriced unless the staff set its charge.
+func UpdateReservation(rooms store.RoomRepository, reservations store.ReservationRepository) func(graphql.ResolveParams) (interface{}, error) {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx := resolveContext(p)
+		id, _ := p.Args["id"].(string)
+		reservation, err := findOwnReservation(ctx, reservations, id, auth.ManageReservations, auth.ManageOwnReservations)
+		if err != nil {
+			return nil, err
+		}
+		if err := takeToken(ctx, ratelimit.Bookings); err != nil {
+			return nil, err
+		}
+		if reservation.Status == ReservationCancelled {
+			return nil, conflict("reservation %s is cancelled", id)
+		}
+		previous := reservation
+
+		// Only a new stay is validated, so a stay under way can still be repriced.
+		changes := reservationArgs(p.Args)
+		totalCharge, charged, err := chargeArg(ctx, changes)
+		if err != nil {
+			return nil, err
+		}
+		_, newCheckin := changes["checkinDate"]
+		_, newCheckout := changes["checkoutDate"]
+		_, newRoom := changes["roomId"]
+		var checkin, checkout time.Time
+		if newCheckin || newCheckout {
+			stay := map[string]interface{}{
+				"checkinDate":  reservation.CheckinDate,
+				"checkoutDate": reservation.CheckoutDate,
+			}
+			for name, value := range changes {
+				stay[name] = value
+			}
+			if checkin, checkout, err = stayArgs(stay, "checkinDate", "checkoutDate"); err != nil {
+				return nil, err
+			}
+			reservation.CheckinDate = checkin.Format(dateLayout)
+			reservation.CheckoutDate = checkout.Format(dateLayout)
+		} else if newRoom {
+			checkin, _ = time.Parse(dateLayout, reservation.CheckinDate)
+			checkout, _ = time.Parse(dateLayout, reservation.CheckoutDate)
+		}
+		if roomID, ok := changes["roomId"].(string); ok {
+			reservation.RoomID = roomID
+		}
+		switch {
+		case charged:
+			reservation.TotalCharge = totalCharge
+		case newCheckin || newCheckout || newRoom:
+			if reservation.TotalCharge, err = stayCharge(ctx, rooms, reservation.RoomID, checkin, checkout); err != nil {
+				return nil, err
+			}
 		}
 
-		_, err = db.NamedExec(`
-            INSERT INTO reservations (room_id, checkin_date, checkout_date, total_charge)
-            VALUES (:room_id, :checkin_date, :checkout_date, :total_charge)
-        `, reservation)
+		available, err := isRoomAvailable(ctx, reservations, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate, reservation.ID)
 		if err != nil {
 			return nil, err
 		}
+		if !available {
+			return nil, conflict("reservation dates overlap with an existing reservation")
+		}
 
+		if err := reservations.UpdateReservation(ctx, reservation); err != nil {
+			return nil, saveError(err, reservation)
+		}
+
+		publishReservationMoved(previous, reservation)
 		return reservation, nil
 	}
 }
+
+// CancelReservation releases the room of a reservation. Cancelling a
+// cancelled reservation changes nothing.
+func CancelReservation(reservations store.ReservationRepository) func(graphql.ResolveParams) (interface{}, error) {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx := resolveContext(p)
+		id, _ := p.Args["id"].(string)
+		reservation, err := findOwnReservation(ctx, reservations, id, auth.ManageReservations, auth.ManageOwnReservations)
+		if err != nil {
+			return nil, err
+		}
+		if reservation.Status == ReservationCancelled {
+			return reservation, nil
+		}
+
+		cancelled := reservation.Status == ReservationConfirmed
+		reservation.Status = ReservationCancelled
+		if err := reservations.UpdateReservation(ctx, reservation); err != nil {
+			return nil, err
+		}
+		if cancelled {
+			reservationsCancelled.Inc()
+		}
+
+		publishReservationEvent(ReservationCancelledAction, reservation)
+		return reservation, nil
+	}
+}
+
+// BlockRoom takes a room out of service for a stay, as an unpriced
+// reservation with the blocked status; cancelling it releases the room.
+// The room must be free for the stay.
+func BlockRoom(reservations store.ReservationRepository) func(graphql.ResolveParams) (interface{}, error) {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx := resolveContext(p)
+		if err := authorize(ctx, auth.BlockRooms); err != nil {
+			return nil, err
+		}
+		roomID, _ := p.Args["roomId"].(string)
+		if roomID == "" {
+			return nil, invalidInput("roomId is required")
+		}
+		start, end, err := stayArgs(p.Args, "startDate", "endDate")
+		if err != nil {
+			return nil, err
+		}
+
+		block := Reservation{
+			RoomID:       roomID,
+			CheckinDate:  start.Format(dateLayout),
+			CheckoutDate: end.Format(dateLayout),
+			Status:       ReservationBlocked,
+			GuestID:      guestOf(ctx),
+		}
+		available, err := isRoomAvailable(ctx, reservations, block.RoomID, block.CheckinDate, block.CheckoutDate, "")
+		if err != nil {
+			return nil, err
+		}
+		if !available {
+			return nil, conflict("room %s is reserved for some of the nights", roomID)
+		}
+
+		block, err = reservations.CreateReservation(ctx, block)
+		if err != nil {
+			return nil, saveError(err, block)
+		}
+
+		publishReservationEvent(ReservationCreatedAction, block)
+		return block, nil
+	}
+}
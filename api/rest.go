@@ -0,0 +1,329 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// OpenAPIDocument describes the REST API in OpenAPI 3 format.
+//
+//go:embed openapi.yaml
+var OpenAPIDocument []byte
+
+// restError is the body of every REST error response.
+type restError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// reservationPage is a page of the GET /reservations listing.
+type reservationPage struct {
+	Reservations []Reservation `json:"reservations"`
+	PageInfo     PageInfo      `json:"pageInfo"`
+	TotalCount   int           `json:"totalCount"`
+}
+
+// reservationBody is the body of POST and PATCH /reservations requests.
+type reservationBody struct {
+	RoomID       *string  `json:"roomId"`
+	CheckinDate  *string  `json:"checkinDate"`
+	CheckoutDate *string  `json:"checkoutDate"`
+	TotalCharge  *float64 `json:"totalCharge"`
+}
+
+// args returns the fields present in the body as resolver arguments.
+func (b reservationBody) args() map[string]interface{} {
+	args := map[string]interface{}{}
+	if b.RoomID != nil {
+		args["roomId"] = *b.RoomID
+	}
+	if b.CheckinDate != nil {
+		args["checkinDate"] = *b.CheckinDate
+	}
+	if b.CheckoutDate != nil {
+		args["checkoutDate"] = *b.CheckoutDate
+	}
+	if b.TotalCharge != nil {
+		args["totalCharge"] = *b.TotalCharge
+	}
+	return args
+}
+
+// isRESTPath reports whether path belongs to the REST API rather than GraphQL.
+func isRESTPath(path string) bool {
+	return path == "/openapi.yaml" || path == "/rooms/available" ||
+		path == "/reservations" || strings.HasPrefix(path, "/reservations/")
+}
+
+// serveREST handles a REST request with the same resolvers as the GraphQL
+// schema, so both APIs validate and store reservations identically.
+func serveREST(ctx context.Context, repos store.Repositories, r httpRequest) httpResponse {
+	resolve := func(resolver graphql.FieldResolveFn, args map[string]interface{}) (interface{}, error) {
+		return resolver(graphql.ResolveParams{Context: ctx, Args: args})
+	}
+
+	switch {
+	case r.Path == "/openapi.yaml":
+		if r.Method != http.MethodGet {
+			return methodNotAllowed(http.MethodGet)
+		}
+		return httpResponse{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/yaml"}},
+			Body:       OpenAPIDocument,
+		}
+
+	case r.Path == "/rooms/available":
+		if r.Method != http.MethodGet {
+			return methodNotAllowed(http.MethodGet)
+		}
+		args, err := availableRoomsArgs(r)
+		if err != nil {
+			return restErrorResponse(ctx, err)
+		}
+		rooms, err := resolve(GetAvailableRooms(repos.Rooms), args)
+		if err != nil {
+			return restErrorResponse(ctx, err)
+		}
+		if rooms, _ := rooms.([]Room); rooms == nil {
+			return restResponse(ctx, http.StatusOK, []Room{})
+		}
+		return restResponse(ctx, http.StatusOK, rooms)
+
+	case r.Path == "/reservations":
+		switch r.Method {
+		case http.MethodGet:
+			args, err := listReservationsArgs(r)
+			if err != nil {
+				return restErrorResponse(ctx, err)
+			}
+			result, err := resolve(GetAllReservations(repos.Reservations), args)
+			if err != nil {
+				return restErrorResponse(ctx, err)
+			}
+			connection := result.(*ReservationConnection)
+			totalCount, err := connection.totalCount()
+			if err != nil {
+				return restErrorResponse(ctx, err)
+			}
+			page := reservationPage{Reservations: []Reservation{}, PageInfo: connection.PageInfo, TotalCount: totalCount}
+			for _, edge := range connection.Edges {
+				page.Reservations = append(page.Reservations, edge.Node)
+			}
+			return restResponse(ctx, http.StatusOK, page)
+
+		case http.MethodPost:
+			body, err := decodeReservationBody(r)
+			if err != nil {
+				return restErrorResponse(ctx, err)
+			}
+			result, err := resolve(CreateReservation(repos.Rooms, repos.Reservations), body.args())
+			if err != nil {
+				return restErrorResponse(ctx, err)
+			}
+			response := restResponse(ctx, http.StatusCreated, result)
+			response.Header.Set("Location", "/reservations/"+result.(Reservation).ID)
+			return response
+
+		default:
+			return methodNotAllowed(http.MethodGet, http.MethodPost)
+		}
+
+	case strings.HasPrefix(r.Path, "/reservations/"):
+		id := strings.TrimPrefix(r.Path, "/reservations/")
+		if id == "" || strings.Contains(id, "/") {
+			return restErrorResponse(ctx, notFound("no route for %s", r.Path))
+		}
+
+		var result interface{}
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			result, err = findOwnReservation(ctx, repos.Reservations, id, auth.ReadReservations, auth.ReadOwnReservations)
+		case http.MethodPatch:
+			var body reservationBody
+			if body, err = decodeReservationBody(r); err == nil {
+				args := body.args()
+				args["id"] = id
+				result, err = resolve(UpdateReservation(repos.Rooms, repos.Reservations), args)
+			}
+		case http.MethodDelete:
+			result, err = resolve(CancelReservation(repos.Reservations), map[string]interface{}{"id": id})
+		default:
+			return methodNotAllowed(http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+		if err != nil {
+			return restErrorResponse(ctx, err)
+		}
+		return restResponse(ctx, http.StatusOK, result)
+	}
+
+	return restErrorResponse(ctx, notFound("no route for %s", r.Path))
+}
+
+func availableRoomsArgs(r httpRequest) (map[string]interface{}, error) {
+	args := map[string]interface{}{"numBeds": 1, "allowSmoking": false}
+	for _, name := range []string{"startDate", "endDate"} {
+		if value := r.Query.Get(name); value != "" {
+			args[name] = value
+		}
+	}
+	if value := r.Query.Get("numBeds"); value != "" {
+		numBeds, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidInput("numBeds must be an integer, not %q", value)
+		}
+		args["numBeds"] = numBeds
+	}
+	if value := r.Query.Get("allowSmoking"); value != "" {
+		allowSmoking, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, invalidInput("allowSmoking must be true or false, not %q", value)
+		}
+		args["allowSmoking"] = allowSmoking
+	}
+	return args, nil
+}
+
+// listReservationsArgs maps the query string of GET /reservations onto the
+// arguments of the reservations query. sort names a field, prefixed with a
+// minus sign for descending order.
+func listReservationsArgs(r httpRequest) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if value := r.Query.Get("first"); value != "" {
+		first, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, invalidInput("first must be an integer, not %q", value)
+		}
+		args["first"] = first
+	}
+	if after := r.Query.Get("after"); after != "" {
+		args["after"] = after
+	}
+
+	filter := map[string]interface{}{}
+	if roomIDs := r.Query["roomId"]; len(roomIDs) > 0 {
+		filter["roomIds"] = interfaceList(roomIDs)
+	}
+	if statuses := r.Query["status"]; len(statuses) > 0 {
+		for _, status := range statuses {
+			if status != ReservationConfirmed && status != ReservationCancelled && status != ReservationBlocked {
+				return nil, invalidInput("status must be %s, %s or %s, not %q", ReservationConfirmed, ReservationCancelled, ReservationBlocked, status)
+			}
+		}
+		filter["status"] = interfaceList(statuses)
+	}
+	from, to := r.Query.Get("from"), r.Query.Get("to")
+	if from != "" || to != "" {
+		if from == "" || to == "" {
+			return nil, invalidInput("from and to must be given together")
+		}
+		filter["overlaps"] = map[string]interface{}{"start": from, "end": to}
+	}
+	args["filter"] = filter
+
+	if sort := r.Query.Get("sort"); sort != "" {
+		orderBy := map[string]interface{}{"field": strings.TrimPrefix(sort, "-"), "direction": "asc"}
+		if strings.HasPrefix(sort, "-") {
+			orderBy["direction"] = "desc"
+		}
+		args["orderBy"] = orderBy
+	}
+	return args, nil
+}
+
+func interfaceList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return list
+}
+
+func decodeReservationBody(r httpRequest) (reservationBody, error) {
+	var body reservationBody
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != contentTypeJSON {
+			return body, errUnsupportedMediaType
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(r.Body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		return body, invalidInput("request body must be a reservation JSON object: %v", err)
+	}
+	return body, nil
+}
+
+var errUnsupportedMediaType = errors.New("request body must be " + contentTypeJSON)
+
+func restResponse(ctx context.Context, statusCode int, value interface{}) httpResponse {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return restErrorResponse(ctx, err)
+	}
+	return httpResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {contentTypeJSON + "; charset=utf-8"}},
+		Body:       body,
+	}
+}
+
+// restErrorResponse maps a resolver error onto an HTTP status. Internal
+// errors are logged rather than shown to the client.
+func restErrorResponse(ctx context.Context, err error) httpResponse {
+	statusCode, code, message := http.StatusInternalServerError, "INTERNAL_SERVER_ERROR", "internal server error"
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		statusCode, code, message = http.StatusBadRequest, errorCodes[ErrInvalidInput], err.Error()
+	case errors.Is(err, ErrNotFound):
+		statusCode, code, message = http.StatusNotFound, errorCodes[ErrNotFound], err.Error()
+	case errors.Is(err, ErrConflict):
+		statusCode, code, message = http.StatusConflict, errorCodes[ErrConflict], err.Error()
+	case errors.Is(err, ErrUnauthenticated):
+		statusCode, code, message = http.StatusUnauthorized, errorCodes[ErrUnauthenticated], err.Error()
+	case errors.Is(err, ErrForbidden):
+		statusCode, code, message = http.StatusForbidden, errorCodes[ErrForbidden], err.Error()
+	case errors.Is(err, ErrRateLimited):
+		statusCode, code, message = http.StatusTooManyRequests, errorCodes[ErrRateLimited], err.Error()
+	case errors.Is(err, errUnsupportedMediaType):
+		statusCode, code, message = http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", err.Error()
+	default:
+		logging.FromContext(ctx).Error("rest request failed", "error", err.Error())
+	}
+
+	return restErrorBody(statusCode, code, message)
+}
+
+func methodNotAllowed(methods ...string) httpResponse {
+	allowed := strings.Join(methods, ", ")
+	response := restErrorBody(http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "use "+allowed)
+	response.Header.Set("Allow", allowed)
+	return response
+}
+
+func restErrorBody(statusCode int, code string, message string) httpResponse {
+	var body restError
+	body.Error.Code, body.Error.Message = code, message
+	encoded, _ := json.Marshal(body)
+	return httpResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {contentTypeJSON + "; charset=utf-8"}},
+		Body:       encoded,
+	}
+}
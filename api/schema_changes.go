@@ -0,0 +1,234 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// sdlType is the part of a type definition that clients depend on.
+type sdlType struct {
+	kind       string
+	fields     map[string]*ast.FieldDefinition
+	inputs     map[string]*ast.InputValueDefinition
+	values     map[string]bool
+	members    map[string]bool
+	interfaces map[string]bool
+}
+
+func parseSDL(sdl string) (map[string]*sdlType, error) {
+	document, err := parser.Parse(parser.ParseParams{Source: sdl})
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[string]*sdlType{}
+	for _, definition := range document.Definitions {
+		t := &sdlType{
+			kind:       definition.GetKind(),
+			fields:     map[string]*ast.FieldDefinition{},
+			inputs:     map[string]*ast.InputValueDefinition{},
+			values:     map[string]bool{},
+			members:    map[string]bool{},
+			interfaces: map[string]bool{},
+		}
+		var name string
+		switch definition := definition.(type) {
+		case *ast.ScalarDefinition:
+			name = definition.Name.Value
+		case *ast.ObjectDefinition:
+			name = definition.Name.Value
+			for _, field := range definition.Fields {
+				t.fields[field.Name.Value] = field
+			}
+			for _, iface := range definition.Interfaces {
+				t.interfaces[iface.Name.Value] = true
+			}
+		case *ast.InterfaceDefinition:
+			name = definition.Name.Value
+			for _, field := range definition.Fields {
+				t.fields[field.Name.Value] = field
+			}
+		case *ast.UnionDefinition:
+			name = definition.Name.Value
+			for _, member := range definition.Types {
+				t.members[member.Name.Value] = true
+			}
+		case *ast.EnumDefinition:
+			name = definition.Name.Value
+			for _, value := range definition.Values {
+				t.values[value.Name.Value] = true
+			}
+		case *ast.InputObjectDefinition:
+			name = definition.Name.Value
+			for _, field := range definition.Fields {
+				t.inputs[field.Name.Value] = field
+			}
+		default:
+			continue
+		}
+		types[name] = t
+	}
+	return types, nil
+}
+
+// BreakingChanges compares two SDL documents and describes every change in
+// current that can break a client written against previous: removed types,
+// fields, arguments, enum values, union members and interfaces, new
+// required inputs, and type changes such as a field becoming nullable or an
+// argument becoming required.
+func BreakingChanges(previous string, current string) ([]string, error) {
+	before, err := parseSDL(previous)
+	if err != nil {
+		return nil, fmt.Errorf("previous schema: %w", err)
+	}
+	after, err := parseSDL(current)
+	if err != nil {
+		return nil, fmt.Errorf("current schema: %w", err)
+	}
+
+	var changes []string
+	for _, name := range sortedKeys(before) {
+		t, next := before[name], after[name]
+		if next == nil {
+			changes = append(changes, fmt.Sprintf("%s was removed", name))
+			continue
+		}
+		if t.kind != next.kind {
+			changes = append(changes, fmt.Sprintf("%s changed from %s to %s", name, t.kind, next.kind))
+			continue
+		}
+
+		for _, fieldName := range sortedKeys(t.fields) {
+			field, nextField := t.fields[fieldName], next.fields[fieldName]
+			path := name + "." + fieldName
+			if nextField == nil {
+				changes = append(changes, fmt.Sprintf("%s was removed", path))
+				continue
+			}
+			if !safeOutputChange(field.Type, nextField.Type) {
+				changes = append(changes, fmt.Sprintf("%s changed type from %s to %s", path, typeString(field.Type), typeString(nextField.Type)))
+			}
+			changes = append(changes, inputChanges(path, arguments(field), arguments(nextField), "argument")...)
+		}
+		changes = append(changes, inputChanges(name, t.inputs, next.inputs, "field")...)
+
+		for _, value := range sortedKeys(t.values) {
+			if !next.values[value] {
+				changes = append(changes, fmt.Sprintf("%s.%s was removed", name, value))
+			}
+		}
+		for _, member := range sortedKeys(t.members) {
+			if !next.members[member] {
+				changes = append(changes, fmt.Sprintf("%s is no longer a member of %s", member, name))
+			}
+		}
+		for _, iface := range sortedKeys(t.interfaces) {
+			if !next.interfaces[iface] {
+				changes = append(changes, fmt.Sprintf("%s no longer implements %s", name, iface))
+			}
+		}
+	}
+	return changes, nil
+}
+
+func arguments(field *ast.FieldDefinition) map[string]*ast.InputValueDefinition {
+	args := map[string]*ast.InputValueDefinition{}
+	for _, arg := range field.Arguments {
+		args[arg.Name.Value] = arg
+	}
+	return args
+}
+
+// inputChanges compares the arguments of a field or the fields of an input
+// object.
+func inputChanges(path string, before, after map[string]*ast.InputValueDefinition, noun string) []string {
+	var changes []string
+	for _, name := range sortedKeys(before) {
+		next, ok := after[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("%s %s %s was removed", path, noun, name))
+			continue
+		}
+		if !safeInputChange(before[name].Type, next.Type) {
+			changes = append(changes, fmt.Sprintf("%s %s %s changed type from %s to %s",
+				path, noun, name, typeString(before[name].Type), typeString(next.Type)))
+		}
+	}
+	for _, name := range sortedKeys(after) {
+		input := after[name]
+		_, required := input.Type.(*ast.NonNull)
+		if _, ok := before[name]; !ok && required && input.DefaultValue == nil {
+			changes = append(changes, fmt.Sprintf("%s has a new required %s %s", path, noun, name))
+		}
+	}
+	return changes
+}
+
+// safeOutputChange reports whether clients reading a value of type before
+// can read a value of type after: the same type, possibly made non-null.
+func safeOutputChange(before, after ast.Type) bool {
+	switch before := before.(type) {
+	case *ast.Named:
+		if next, ok := after.(*ast.NonNull); ok {
+			return safeOutputChange(before, next.Type)
+		}
+		next, ok := after.(*ast.Named)
+		return ok && next.Name.Value == before.Name.Value
+	case *ast.List:
+		switch next := after.(type) {
+		case *ast.List:
+			return safeOutputChange(before.Type, next.Type)
+		case *ast.NonNull:
+			return safeOutputChange(before, next.Type)
+		}
+	case *ast.NonNull:
+		if next, ok := after.(*ast.NonNull); ok {
+			return safeOutputChange(before.Type, next.Type)
+		}
+	}
+	return false
+}
+
+// safeInputChange reports whether values clients send as type before are
+// still accepted as type after: the same type, possibly made nullable.
+func safeInputChange(before, after ast.Type) bool {
+	switch before := before.(type) {
+	case *ast.Named:
+		next, ok := after.(*ast.Named)
+		return ok && next.Name.Value == before.Name.Value
+	case *ast.List:
+		if next, ok := after.(*ast.List); ok {
+			return safeInputChange(before.Type, next.Type)
+		}
+	case *ast.NonNull:
+		if next, ok := after.(*ast.NonNull); ok {
+			return safeInputChange(before.Type, next.Type)
+		}
+		return safeInputChange(before.Type, after)
+	}
+	return false
+}
+
+func typeString(t ast.Type) string {
+	switch t := t.(type) {
+	case *ast.Named:
+		return t.Name.Value
+	case *ast.List:
+		return "[" + typeString(t.Type) + "]"
+	case *ast.NonNull:
+		return typeString(t.Type) + "!"
+	}
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
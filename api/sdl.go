@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// builtinTypes are defined by the GraphQL specification and left out of the SDL.
+var builtinTypes = map[string]bool{
+	"String": true, "Int": true, "Float": true, "Boolean": true, "ID": true,
+}
+
+// PrintSchema returns the schema definition language (SDL) of schema, with
+// types in alphabetical order so the output is stable between builds.
+func PrintSchema(schema graphql.Schema) string {
+	var blocks []string
+
+	var operations []string
+	if query := schema.QueryType(); query != nil {
+		operations = append(operations, "  query: "+query.Name())
+	}
+	if mutation := schema.MutationType(); mutation != nil {
+		operations = append(operations, "  mutation: "+mutation.Name())
+	}
+	if subscription := schema.SubscriptionType(); subscription != nil {
+		operations = append(operations, "  subscription: "+subscription.Name())
+	}
+	blocks = append(blocks, "schema {\n"+strings.Join(operations, "\n")+"\n}")
+
+	typeMap := schema.TypeMap()
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		if !strings.HasPrefix(name, "__") && !builtinTypes[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		blocks = append(blocks, printType(typeMap[name]))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+func printType(ttype graphql.Type) string {
+	var b strings.Builder
+	b.WriteString(printDescription(ttype.Description(), ""))
+
+	switch ttype := ttype.(type) {
+	case *graphql.Scalar:
+		b.WriteString("scalar " + ttype.Name())
+
+	case *graphql.Object:
+		b.WriteString("type " + ttype.Name())
+		if interfaces := ttype.Interfaces(); len(interfaces) > 0 {
+			names := make([]string, len(interfaces))
+			for i, iface := range interfaces {
+				names[i] = iface.Name()
+			}
+			b.WriteString(" implements " + strings.Join(names, " & "))
+		}
+		b.WriteString(printFields(ttype.Fields()))
+
+	case *graphql.Interface:
+		b.WriteString("interface " + ttype.Name())
+		b.WriteString(printFields(ttype.Fields()))
+
+	case *graphql.Union:
+		names := make([]string, len(ttype.Types()))
+		for i, member := range ttype.Types() {
+			names[i] = member.Name()
+		}
+		b.WriteString("union " + ttype.Name() + " = " + strings.Join(names, " | "))
+
+	case *graphql.Enum:
+		// graphql-go keeps enum values in a map, so their order is not stable.
+		values := append([]*graphql.EnumValueDefinition(nil), ttype.Values()...)
+		sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+		b.WriteString("enum " + ttype.Name() + " {\n")
+		for _, value := range values {
+			b.WriteString(printDescription(value.Description, "  "))
+			b.WriteString("  " + value.Name + printDeprecation(value.DeprecationReason) + "\n")
+		}
+		b.WriteString("}")
+
+	case *graphql.InputObject:
+		fields := ttype.Fields()
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.WriteString("input " + ttype.Name() + " {\n")
+		for _, name := range names {
+			field := fields[name]
+			b.WriteString(printDescription(field.Description(), "  "))
+			b.WriteString("  " + name + ": " + field.Type.String() + printDefault(field.DefaultValue, field.Type) + "\n")
+		}
+		b.WriteString("}")
+	}
+	return b.String()
+}
+
+func printFields(fields graphql.FieldDefinitionMap) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(" {\n")
+	for _, name := range names {
+		field := fields[name]
+		b.WriteString(printDescription(field.Description, "  "))
+		b.WriteString("  " + name)
+		if len(field.Args) > 0 {
+			// Arguments are kept in a map too.
+			args := make([]string, len(field.Args))
+			for i, arg := range field.Args {
+				args[i] = arg.Name() + ": " + arg.Type.String() + printDefault(arg.DefaultValue, arg.Type)
+			}
+			sort.Strings(args)
+			b.WriteString("(" + strings.Join(args, ", ") + ")")
+		}
+		b.WriteString(": " + field.Type.String() + printDeprecation(field.DeprecationReason) + "\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func printDescription(description string, indent string) string {
+	if description == "" {
+		return ""
+	}
+	if strings.Contains(description, "\n") {
+		lines := strings.Split(description, "\n")
+		return indent + `"""` + "\n" + indent + strings.Join(lines, "\n"+indent) + "\n" + indent + `"""` + "\n"
+	}
+	quoted, _ := json.Marshal(description)
+	return indent + string(quoted) + "\n"
+}
+
+func printDeprecation(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	quoted, _ := json.Marshal(reason)
+	return " @deprecated(reason: " + string(quoted) + ")"
+}
+
+func printDefault(value interface{}, ttype graphql.Input) string {
+	if value == nil {
+		return ""
+	}
+	return " = " + printValue(value, ttype)
+}
+
+// printValue prints an internal input value as a GraphQL literal of ttype.
+func printValue(value interface{}, ttype graphql.Input) string {
+	switch ttype := ttype.(type) {
+	case *graphql.NonNull:
+		return printValue(value, ttype.OfType)
+
+	case *graphql.List:
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			return printValue(value, ttype.OfType)
+		}
+		printed := make([]string, items.Len())
+		for i := range printed {
+			printed[i] = printValue(items.Index(i).Interface(), ttype.OfType)
+		}
+		return "[" + strings.Join(printed, ", ") + "]"
+
+	case *graphql.Enum:
+		for _, enumValue := range ttype.Values() {
+			if reflect.DeepEqual(enumValue.Value, value) {
+				return enumValue.Name
+			}
+		}
+
+	case *graphql.InputObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		printed := make([]string, len(names))
+		for i, name := range names {
+			printed[i] = name + ": " + printValue(fields[name], ttype.Fields()[name].Type)
+		}
+		return "{" + strings.Join(printed, ", ") + "}"
+	}
+
+	switch value := value.(type) {
+	case string:
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	default:
+		return fmt.Sprint(value)
+	}
+}
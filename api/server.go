@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Timeouts of the standalone server. WebSockets are not affected: the
+// deadlines are cleared when a connection is upgraded.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 60 * time.Second
+)
+
+// NewServer returns the standalone HTTP server. It serves GraphQL, WebSocket
+// subscriptions included, at /api, the playground page at /playground
+// outside production, the REST API, liveness and readiness probes at /livez
+// and /readyz (/healthz is kept for the probes that use it), diagnostics at
+// /diagnostics, Prometheus metrics at /metrics, and logs and traces every
+// request. GraphQL and the REST API authenticate and rate limit their
+// callers. Shutting it down closes open WebSockets with a going-away status.
+// Every request shares repos, and is served with settings.
+func NewServer(settings Settings, repos store.Repositories) (*http.Server, error) {
+	schema, err := AppSchema(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	closing := make(chan struct{})
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(settings.Config.API.Port),
+		Handler:           newHandler(settings, repos, schema, closing),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	server.RegisterOnShutdown(func() { close(closing) })
+	return server, nil
+}
+
+// Serve runs the standalone server until ctx is done, then stops accepting
+// connections and waits up to the API shutdown timeout of settings for
+// in-flight requests to finish.
+func Serve(ctx context.Context, settings Settings, repos store.Repositories) error {
+	server, err := NewServer(settings, repos)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	logging.Logger().Info("listening", "url", "http://localhost"+server.Addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), settings.Config.API.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func newHandler(settings Settings, repos store.Repositories, schema graphql.Schema, closing <-chan struct{}) http.Handler {
+	mux := http.NewServeMux()
+	limiter := newRateLimiter(settings.RateLimits, repos)
+
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			serveSubscriptions(w, r, schema, settings, limiter, closing)
+			return
+		}
+
+		request, err := fromHTTPRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, limiting := withRateLimiting(r.Context(), limiter, request.RemoteAddr)
+		writeResponse(w, limiting.report(serveAuthenticated(ctx, settings.Authenticator, request, func(ctx context.Context) httpResponse {
+			return serveGraphQL(ctx, schema, settings, request)
+		})))
+	})
+
+	if settings.Config.Env != config.Production {
+		mux.HandleFunc("/playground", servePlayground)
+	}
+
+	rest := func(w http.ResponseWriter, r *http.Request) {
+		request, err := fromHTTPRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, limiting := withRateLimiting(r.Context(), limiter, request.RemoteAddr)
+		writeResponse(w, limiting.report(serveAuthenticated(ctx, settings.Authenticator, request, func(ctx context.Context) httpResponse {
+			return serveREST(ctx, repos, request)
+		})))
+	}
+	mux.HandleFunc("/openapi.yaml", rest)
+	mux.HandleFunc("/rooms/", rest)
+	mux.HandleFunc("/reservations", rest)
+	mux.HandleFunc("/reservations/", rest)
+
+	mux.HandleFunc("/livez", serveLiveness)
+	mux.HandleFunc("/healthz", serveLiveness)
+	mux.HandleFunc("/readyz", serveReadiness(repos))
+	mux.HandleFunc("/diagnostics", serveDiagnostics(settings.Config, repos))
+	mux.Handle("/metrics", promhttp.HandlerFor(newRegistry(repos), promhttp.HandlerOpts{}))
+
+	return traceRequests(logRequests(countRequests(mux)))
+}
+
+// countRequests records the requests served, by status code, and those in
+// flight in the metrics published at /metrics.
+func countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsInFlight.Inc()
+		defer requestsInFlight.Dec()
+
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		requestsServed.WithLabelValues(strconv.Itoa(recorder.statusCode)).Inc()
+	})
+}
+
+// statusRecorder remembers the status code written through it. It passes
+// Hijack through so that WebSocket upgrades keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	r.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func fromHTTPRequest(r *http.Request) (httpRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return httpRequest{}, err
+	}
+	return httpRequest{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Header:     r.Header,
+		Query:      r.URL.Query(),
+		Body:       body,
+		RemoteAddr: remoteHost(r.RemoteAddr),
+	}, nil
+}
+
+func writeResponse(w http.ResponseWriter, response httpResponse) {
+	for key, values := range response.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/postgres"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+// OpenStore opens the store selected by cfg.Driver. Open it once per
+// process and share it.
+func OpenStore(cfg config.Database) (store.Backend, error) {
+	if cfg.Driver == config.DriverSQLite {
+		s, err := sqlite.Open(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	s, err := postgres.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ConnectStore opens the store selected by cfg.Driver without migrating
+// it, for the commands that manage its schema.
+func ConnectStore(cfg config.Database) (store.Backend, error) {
+	if cfg.Driver == config.DriverSQLite {
+		s, err := sqlite.Connect(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	s, err := postgres.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
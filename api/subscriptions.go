@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/ratelimit"
+)
+
+// graphqlTransportWS is the sub-protocol spoken by the graphql-ws client library.
+const graphqlTransportWS = "graphql-transport-ws"
+
+// connectionInitTimeout is how long a client has to send connection_init.
+const connectionInitTimeout = 10 * time.Second
+
+// Close codes defined by the graphql-transport-ws protocol.
+const (
+	closeBadRequest         = 4400
+	closeUnauthorized       = 4401
+	closeForbidden          = 4403
+	closeInitTimeout        = 4408
+	closeSubscriberExists   = 4409
+	closeTooManyInitRequest = 4429
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// serveSubscriptions upgrades r to a WebSocket and serves GraphQL operations,
+// subscriptions included, over the graphql-transport-ws protocol, with the
+// persisted queries, limits and authentication of settings, rate limiting
+// every operation with limiter as a request of its own. Only pages from
+// the allowed origins of settings may connect, so that no other site can
+// subscribe with the cookies or credentials of its visitors. The connection
+// is closed with a going-away status once closing is closed.
+func serveSubscriptions(w http.ResponseWriter, r *http.Request, schema graphql.Schema, settings Settings, limiter *ratelimit.Limiter, closing <-chan struct{}) {
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{graphqlTransportWS},
+		CheckOrigin: func(r *http.Request) bool {
+			return allowedOrigin(settings.Config.API.AllowedOrigins, r.Header.Get("Origin"))
+		},
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != graphqlTransportWS {
+		closeWebSocket(conn, websocket.CloseProtocolError, "unsupported sub-protocol, use "+graphqlTransportWS)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-closing:
+			closeWebSocket(conn, websocket.CloseGoingAway, "Server is shutting down")
+		case <-ctx.Done():
+		}
+	}()
+
+	session := &subscriptionSession{
+		conn:       conn,
+		schema:     schema,
+		settings:   settings,
+		limiter:    limiter,
+		address:    remoteHost(r.RemoteAddr),
+		header:     r.Header,
+		operations: map[string]context.CancelFunc{},
+		initTimer: time.AfterFunc(connectionInitTimeout, func() {
+			closeWebSocket(conn, closeInitTimeout, "Connection initialisation timeout")
+		}),
+	}
+	defer session.running.Wait()
+	defer session.cancelAll()
+	defer session.initTimer.Stop()
+
+	session.run(ctx)
+}
+
+type subscriptionSession struct {
+	conn     *websocket.Conn
+	schema   graphql.Schema
+	settings Settings
+	// limiter rate limits the operations of the connection, made from
+	// address.
+	limiter *ratelimit.Limiter
+	address string
+	// header is the header of the upgrade request, whose credentials the
+	// connection_init payload may add to.
+	header http.Header
+
+	writeMu sync.Mutex
+
+	mu           sync.Mutex
+	acknowledged bool
+	operations   map[string]context.CancelFunc
+	initTimer    *time.Timer
+	running      sync.WaitGroup
+}
+
+func (s *subscriptionSession) run(ctx context.Context) {
+	for {
+		var message wsMessage
+		if err := s.conn.ReadJSON(&message); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				closeWebSocket(s.conn, closeBadRequest, "Invalid message received")
+			}
+			return
+		}
+
+		switch message.Type {
+		case "connection_init":
+			s.mu.Lock()
+			alreadyAcknowledged := s.acknowledged
+			s.acknowledged = true
+			s.mu.Unlock()
+
+			if alreadyAcknowledged {
+				closeWebSocket(s.conn, closeTooManyInitRequest, "Too many initialisation requests")
+				return
+			}
+			// Close reasons are limited to 123 bytes, so the reason of the
+			// refusal is only logged.
+			authenticated, err := authenticate(ctx, s.settings.Authenticator, s.credentials(message.Payload))
+			if err != nil {
+				closeWebSocket(s.conn, closeForbidden, "Forbidden")
+				return
+			}
+			ctx = authenticated
+			s.initTimer.Stop()
+			s.write(wsMessage{Type: "connection_ack"})
+
+		case "ping":
+			s.write(wsMessage{Type: "pong"})
+
+		case "pong":
+
+		case "subscribe":
+			s.mu.Lock()
+			acknowledged := s.acknowledged
+			_, exists := s.operations[message.ID]
+			s.mu.Unlock()
+
+			if !acknowledged {
+				closeWebSocket(s.conn, closeUnauthorized, "Unauthorized")
+				return
+			}
+			if message.ID == "" {
+				closeWebSocket(s.conn, closeBadRequest, "Subscribe message requires an id")
+				return
+			}
+			if exists {
+				closeWebSocket(s.conn, closeSubscriberExists, "Subscriber for "+message.ID+" already exists")
+				return
+			}
+
+			var req GraphQLRequest
+			if err := json.Unmarshal(message.Payload, &req); err != nil || (req.Query == "" && !hasPersistedQuery(req)) {
+				closeWebSocket(s.conn, closeBadRequest, "Invalid subscribe payload")
+				return
+			}
+			req, pqErr := s.settings.PersistedQueries.resolve(ctx, req)
+			if pqErr != nil {
+				payload, _ := json.Marshal(gqlerrors.FormattedErrors{*pqErr})
+				s.write(wsMessage{ID: message.ID, Type: "error", Payload: payload})
+				continue
+			}
+			s.start(ctx, message.ID, req)
+
+		case "complete":
+			s.stop(message.ID, false)
+
+		default:
+			closeWebSocket(s.conn, closeBadRequest, "Unknown message type "+message.Type)
+			return
+		}
+	}
+}
+
+// credentials returns the header of the upgrade request with the
+// Authorization and X-Api-Key values of the connection_init payload, which
+// browsers, unable to set headers on a WebSocket, send instead.
+func (s *subscriptionSession) credentials(payload json.RawMessage) http.Header {
+	header := s.header.Clone()
+	var values map[string]interface{}
+	json.Unmarshal(payload, &values)
+	for key, value := range values {
+		switch key = http.CanonicalHeaderKey(key); key {
+		case "Authorization", auth.APIKeyHeader:
+			if value, ok := value.(string); ok {
+				header.Set(key, value)
+			}
+		}
+	}
+	return header
+}
+
+// start executes req under id. Queries and mutations produce a single
+// result; subscriptions produce one result per event until the client or
+// the server completes them.
+func (s *subscriptionSession) start(ctx context.Context, id string, req GraphQLRequest) {
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, _ = withRateLimiting(ctx, s.limiter, s.address)
+
+	s.mu.Lock()
+	s.operations[id] = cancel
+	s.mu.Unlock()
+
+	params := graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+
+		// An error message terminates the operation without a complete.
+		completed := true
+		defer func() { s.stop(id, completed) }()
+
+		if result := checkQueryLimits(params, s.settings.Limits); result != nil {
+			completed = s.sendResult(id, result, true)
+			return
+		}
+
+		if operationType(req.Query, req.OperationName) != ast.OperationTypeSubscription {
+			start := time.Now()
+			operation := selectOperation(req.Query, req.OperationName)
+			operationCtx, span := startOperation(ctx, operation)
+			params.Context = operationCtx
+			result := graphql.Do(params)
+			endOperation(span, result)
+			logOperation(ctx, operation, result, time.Since(start))
+			observeOperation(operation, result, time.Since(start))
+			completed = s.sendResult(id, result, true)
+			return
+		}
+
+		first := true
+		for result := range graphql.Subscribe(params) {
+			// Keep draining after cancellation so graphql-go's producer can finish.
+			if ctx.Err() != nil || !completed {
+				continue
+			}
+			completed = s.sendResult(id, result, first)
+			first = false
+			if !completed {
+				cancel()
+			}
+		}
+	}()
+}
+
+// sendResult sends result as a next message, or as an error message when
+// the operation was rejected before it could run or was rate limited. It
+// reports whether the operation may continue.
+func (s *subscriptionSession) sendResult(id string, result *graphql.Result, first bool) bool {
+	if (first && result.Data == nil && result.HasErrors()) || rateLimitedResult(result) {
+		payload, _ := json.Marshal(result.Errors)
+		s.write(wsMessage{ID: id, Type: "error", Payload: payload})
+		return false
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		payload, _ = json.Marshal(gqlerrors.FormattedErrors{{Message: err.Error()}})
+		s.write(wsMessage{ID: id, Type: "error", Payload: payload})
+		return false
+	}
+	s.write(wsMessage{ID: id, Type: "next", Payload: payload})
+	return true
+}
+
+// rateLimitedResult reports whether result was refused a rate limit token.
+func rateLimitedResult(result *graphql.Result) bool {
+	for _, err := range result.Errors {
+		if err.Extensions["code"] == errorCodes[ErrRateLimited] {
+			return true
+		}
+	}
+	return false
+}
+
+// stop cancels the operation with id, telling the client it is complete
+// unless the client asked for it to stop.
+func (s *subscriptionSession) stop(id string, notify bool) {
+	s.mu.Lock()
+	cancel, ok := s.operations[id]
+	delete(s.operations, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	cancel()
+	if notify {
+		s.write(wsMessage{ID: id, Type: "complete"})
+	}
+}
+
+func (s *subscriptionSession) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, cancel := range s.operations {
+		cancel()
+		delete(s.operations, id)
+	}
+}
+
+func (s *subscriptionSession) write(message wsMessage) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.WriteJSON(message)
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// allowedOrigin reports whether origin, the Origin header of a WebSocket
+// handshake, is one of origins. A handshake without one is not allowed.
+func allowedOrigin(origins []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range origins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
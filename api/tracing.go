@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/willsams/go-hotel-reservation-service/tracing"
+)
+
+// traceRequests records a server span for each request, continuing the
+// trace of its traceparent header. Probes and metric scrapes are left out.
+func traceRequests(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return spanName(r.Method, r.URL.Path)
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/livez", "/readyz", "/healthz", "/metrics":
+				return false
+			}
+			return true
+		}),
+	)
+}
+
+// spanName names the span of a request after its route, so that requests
+// for different reservations share a name.
+func spanName(method string, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/reservations/"):
+		return method + " /reservations/{id}"
+	case path == "/api" || path == "/playground" || isRESTPath(path):
+		return method + " " + path
+	}
+	return method
+}
+
+// startOperation starts the span of a GraphQL operation, which is nil when
+// the document cannot be parsed or the operation selected.
+func startOperation(ctx context.Context, operation *ast.OperationDefinition) (context.Context, trace.Span) {
+	name := "graphql"
+	var attributes []attribute.KeyValue
+	if operation != nil {
+		name = operation.Operation
+		attributes = append(attributes, semconv.GraphqlOperationTypeKey.String(operation.Operation))
+		if operation.Name != nil {
+			name += " " + operation.Name.Value
+			attributes = append(attributes, semconv.GraphqlOperationName(operation.Name.Value))
+		}
+	}
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endOperation ends the span of an operation, marking it failed when the
+// result has errors.
+func endOperation(span trace.Span, result *graphql.Result) {
+	if result.HasErrors() {
+		span.SetStatus(codes.Error, result.Errors[0].Message)
+		for _, err := range result.Errors {
+			span.AddEvent("error", trace.WithAttributes(attribute.String("message", err.Message)))
+		}
+	}
+	span.End()
+}
+
+// tracedFields are the fields whose resolver is traced already: the object
+// types below the root ones are shared by every schema built.
+var tracedFields sync.Map
+
+// traceResolvers gives every resolver of schema a span named after its
+// field, such as RootQuery.availableRooms, under which the statements it
+// runs are traced. Fields without a resolver of their own only read a
+// value of their parent and get none.
+func traceResolvers(schema graphql.Schema) {
+	for _, namedType := range schema.TypeMap() {
+		object, ok := namedType.(*graphql.Object)
+		if !ok || strings.HasPrefix(object.Name(), "__") {
+			continue
+		}
+		for name, field := range object.Fields() {
+			if field.Resolve == nil {
+				continue
+			}
+			if _, traced := tracedFields.LoadOrStore(field, true); !traced {
+				field.Resolve = traceResolver(object.Name()+"."+name, field.Resolve)
+			}
+		}
+	}
+}
+
+func traceResolver(field string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx, span := tracing.Tracer().Start(resolveContext(p), field,
+			trace.WithAttributes(attribute.String("graphql.field", field)))
+		defer span.End()
+
+		p.Context = ctx
+		value, err := resolve(p)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return value, err
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/willsams/go-hotel-reservation-service/tracing"
+)
+
+const (
+	contentTypeJSON            = "application/json"
+	contentTypeGraphQL         = "application/graphql"
+	contentTypeGraphQLResponse = "application/graphql-response+json"
+)
+
+// GraphQLRequest is the body of a GraphQL-over-HTTP request.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// httpRequest is the transport-neutral view of an incoming request shared by
+// the Lambda handler and the local HTTP server. RemoteAddr is the IP
+// address of the client.
+type httpRequest struct {
+	Method     string
+	Path       string
+	Header     http.Header
+	Query      url.Values
+	Body       []byte
+	RemoteAddr string
+}
+
+// httpResponse is what the shared GraphQL-over-HTTP handling produces.
+type httpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// requestError is a GraphQL-over-HTTP failure that happens before the
+// operation reaches the executor.
+type requestError struct {
+	StatusCode int
+	Message    string
+	Header     http.Header
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+func newRequestError(statusCode int, format string, args ...interface{}) *requestError {
+	return &requestError{StatusCode: statusCode, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseGraphQLRequest decodes a GET or POST request following the
+// GraphQL-over-HTTP conventions.
+func parseGraphQLRequest(r httpRequest) (GraphQLRequest, *requestError) {
+	var req GraphQLRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.Query.Get("query")
+		req.OperationName = r.Query.Get("operationName")
+		if variables := r.Query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, newRequestError(http.StatusBadRequest, "variables must be a JSON object: %v", err)
+			}
+		}
+		if extensions := r.Query.Get("extensions"); extensions != "" {
+			if err := json.Unmarshal([]byte(extensions), &req.Extensions); err != nil {
+				return req, newRequestError(http.StatusBadRequest, "extensions must be a JSON object: %v", err)
+			}
+		}
+
+	case http.MethodPost:
+		mediaType := contentTypeJSON
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			parsed, _, err := mime.ParseMediaType(contentType)
+			if err != nil {
+				return req, newRequestError(http.StatusUnsupportedMediaType, "invalid Content-Type %q", contentType)
+			}
+			mediaType = parsed
+		}
+
+		switch mediaType {
+		case contentTypeJSON:
+			if err := json.Unmarshal(r.Body, &req); err != nil {
+				return req, newRequestError(http.StatusBadRequest, "request body must be a JSON object: %v", err)
+			}
+		case contentTypeGraphQL:
+			req.Query = string(r.Body)
+			req.OperationName = r.Query.Get("operationName")
+		default:
+			return req, newRequestError(http.StatusUnsupportedMediaType,
+				"unsupported Content-Type %q, use %s", mediaType, contentTypeJSON)
+		}
+
+	default:
+		err := newRequestError(http.StatusMethodNotAllowed, "method %s is not allowed, use GET or POST", r.Method)
+		err.Header = http.Header{"Allow": {"GET, POST"}}
+		return req, err
+	}
+
+	if strings.TrimSpace(req.Query) == "" && !hasPersistedQuery(req) {
+		return req, newRequestError(http.StatusBadRequest, "a query is required")
+	}
+	return req, nil
+}
+
+// operationType returns the type (query, mutation or subscription) of the
+// operation that would be executed, or an empty string when the document
+// cannot be parsed or the operation cannot be selected; the executor reports
+// those problems itself.
+func operationType(query string, operationName string) string {
+	selected := selectOperation(query, operationName)
+	if selected == nil {
+		return ""
+	}
+	return selected.Operation
+}
+
+// selectOperation returns the operation of the document that would be
+// executed, or nil.
+func selectOperation(query string, operationName string) *ast.OperationDefinition {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	var selected *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" {
+			if selected != nil {
+				return nil
+			}
+			selected = operation
+		} else if operation.Name != nil && operation.Name.Value == operationName {
+			selected = operation
+		}
+	}
+	return selected
+}
+
+// responseMediaType picks the response media type from the Accept header,
+// preferring application/graphql-response+json when the client accepts it.
+func responseMediaType(accept string) string {
+	if accept == "" {
+		return contentTypeJSON
+	}
+	acceptsJSON := false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeGraphQLResponse:
+			return contentTypeGraphQLResponse
+		case contentTypeJSON, "application/*", "*/*":
+			acceptsJSON = true
+		}
+	}
+	if acceptsJSON {
+		return contentTypeJSON
+	}
+	return ""
+}
+
+func errorResult(message string) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{{Message: message}}}
+}
+
+func jsonResponse(statusCode int, mediaType string, result *graphql.Result) httpResponse {
+	body, err := json.Marshal(result)
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		body, _ = json.Marshal(errorResult(err.Error()))
+	}
+	return httpResponse{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": {mediaType + "; charset=utf-8"}},
+		Body:       body,
+	}
+}
+
+// serveGraphQL executes a GraphQL-over-HTTP request against schema, with
+// the persisted queries and limits of settings.
+func serveGraphQL(ctx context.Context, schema graphql.Schema, settings Settings, r httpRequest) httpResponse {
+	mediaType := responseMediaType(r.Header.Get("Accept"))
+	if mediaType == "" {
+		return jsonResponse(http.StatusNotAcceptable, contentTypeJSON,
+			errorResult("responses are only available as "+contentTypeGraphQLResponse+" or "+contentTypeJSON))
+	}
+
+	req, reqErr := parseGraphQLRequest(r)
+	if reqErr != nil {
+		response := jsonResponse(reqErr.StatusCode, mediaType, errorResult(reqErr.Message))
+		for key, values := range reqErr.Header {
+			response.Header[key] = values
+		}
+		return response
+	}
+
+	req, pqErr := settings.PersistedQueries.resolve(ctx, req)
+	if pqErr != nil {
+		statusCode := http.StatusOK
+		if mediaType == contentTypeGraphQLResponse {
+			statusCode = http.StatusBadRequest
+		}
+		return jsonResponse(statusCode, mediaType, &graphql.Result{Errors: []gqlerrors.FormattedError{*pqErr}})
+	}
+
+	if r.Method == http.MethodGet {
+		if operation := operationType(req.Query, req.OperationName); operation != "" && operation != ast.OperationTypeQuery {
+			response := jsonResponse(http.StatusMethodNotAllowed, mediaType,
+				errorResult("only query operations may be sent with GET, use POST"))
+			response.Header.Set("Allow", "POST")
+			return response
+		}
+	}
+
+	start := time.Now()
+	operation := selectOperation(req.Query, req.OperationName)
+	operationCtx, span := startOperation(ctx, operation)
+	params := graphql.Params{
+		Schema:         schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        operationCtx,
+	}
+	result := checkQueryLimits(params, settings.Limits)
+	if result == nil {
+		result = graphql.Do(params)
+	}
+	endOperation(span, result)
+	logOperation(ctx, operation, result, time.Since(start))
+	observeOperation(operation, result, time.Since(start))
+
+	// With application/graphql-response+json, a request that never reached
+	// execution (parse, validation, limit or variable errors) is a client error.
+	statusCode := http.StatusOK
+	if mediaType == contentTypeGraphQLResponse && result.Data == nil && result.HasErrors() {
+		statusCode = http.StatusBadRequest
+	}
+	_, span = tracing.Tracer().Start(ctx, "marshal response")
+	defer span.End()
+	return jsonResponse(statusCode, mediaType, result)
+}
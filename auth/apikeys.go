@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// apiKeyPrefix starts every API key, so that one is recognised when it
+// leaks into a log or a repository.
+const apiKeyPrefix = "hk_"
+
+// APIKey is an entry of the API keys file: the partner a key belongs to,
+// the hex SHA-256 hash of the key and the roles of the partner, by default
+// guest. API keys are long random strings, so a plain hash is enough to
+// keep them from being read off the file.
+type APIKey struct {
+	Name   string   `json:"name"`
+	SHA256 string   `json:"sha256"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// apiKeys is the entries of the API keys, by hash.
+type apiKeys map[string]APIKey
+
+// loadAPIKeys reads the JSON array of APIKey entries in the file at path.
+func loadAPIKeys(path string) (apiKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []APIKey
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := apiKeys{}
+	for i, entry := range entries {
+		hash, err := hex.DecodeString(entry.SHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("%s: key %d: sha256 must be the hex SHA-256 hash of the key", path, i)
+		}
+		if entry.Name == "" {
+			return nil, fmt.Errorf("%s: key %d: a name is required", path, i)
+		}
+		if err := checkRoles(entry.Roles); err != nil {
+			return nil, fmt.Errorf("%s: key %d: %w", path, i, err)
+		}
+		keys[hex.EncodeToString(hash)] = entry
+	}
+	return keys, nil
+}
+
+func (k apiKeys) lookup(key string) (APIKey, bool) {
+	entry, ok := k[HashAPIKey(key)]
+	return entry, ok
+}
+
+// NewAPIKey returns a new random API key and its entry for the API keys
+// file, granting roles. Only the partner gets the key; the service keeps
+// the entry.
+func NewAPIKey(name string, roles ...string) (string, APIKey, error) {
+	if err := checkRoles(roles); err != nil {
+		return "", APIKey{}, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, APIKey{Name: name, SHA256: HashAPIKey(key), Roles: roles}, nil
+}
+
+// HashAPIKey returns the hex SHA-256 hash of key.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
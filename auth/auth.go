@@ -0,0 +1,193 @@
+// Package auth authenticates the callers of the service: users with a JWT
+// bearer token, verified with a JWKS or a shared secret, and partners with
+// an API key, of which the service only keeps a hash.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+)
+
+// APIKeyHeader is the header partners send their API key in.
+const APIKeyHeader = "X-Api-Key"
+
+// Ways a principal authenticated.
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api-key"
+)
+
+// leeway is the clock skew allowed when checking the times of a JWT.
+const leeway = time.Minute
+
+// ErrInvalidCredentials is returned for credentials that do not
+// authenticate anyone.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	// Subject is the sub claim of a JWT, or the name of an API key.
+	Subject string
+	// Method is MethodJWT or MethodAPIKey.
+	Method string
+	// Roles are those this service defines of the roles claim of a JWT or
+	// of the entry of an API key, and at least RoleGuest.
+	Roles []string
+}
+
+// ID identifies the principal across the ways of authenticating, as
+// Method:Subject, so that a JWT subject cannot pass for an API key of the
+// same name.
+func (p *Principal) ID() string {
+	return p.Method + ":" + p.Subject
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of ctx, or nil for an anonymous caller.
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// Authenticator checks the credentials of requests. A nil Authenticator
+// accepts every request as anonymous.
+type Authenticator struct {
+	required bool
+	parser   *jwt.Parser
+	secret   []byte
+	keys     keySet
+	apiKeys  apiKeys
+}
+
+// New returns the authenticator configured by cfg, loading its JWKS and API
+// key files. Without any of them or a secret, it returns nil: no one can
+// be authenticated, so every request is anonymous.
+func New(cfg config.Auth) (*Authenticator, error) {
+	if cfg.JWKSFile == "" && cfg.JWTSecret == "" && cfg.APIKeysFile == "" {
+		return nil, nil
+	}
+	a := &Authenticator{required: cfg.Required}
+
+	var methods []string
+	if cfg.JWTSecret != "" {
+		a.secret = []byte(cfg.JWTSecret)
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired(), jwt.WithLeeway(leeway)}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(options...)
+
+	if cfg.APIKeysFile != "" {
+		keys, err := loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.apiKeys = keys
+	}
+	return a, nil
+}
+
+// Required reports whether requests without credentials are refused.
+func (a *Authenticator) Required() bool {
+	return a != nil && a.required
+}
+
+// Authenticate returns the principal of the bearer token in the
+// Authorization header or of the API key in the X-Api-Key header, or nil
+// when the request has neither. Credentials that do not check out are
+// reported as ErrInvalidCredentials.
+func (a *Authenticator) Authenticate(header http.Header) (*Principal, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if authorization := header.Get("Authorization"); authorization != "" {
+		scheme, token, _ := strings.Cut(authorization, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return nil, fmt.Errorf("%w: the Authorization scheme must be Bearer", ErrInvalidCredentials)
+		}
+		return a.verifyToken(strings.TrimSpace(token))
+	}
+	if key := header.Get(APIKeyHeader); key != "" {
+		return a.verifyAPIKey(key)
+	}
+	return nil, nil
+}
+
+func (a *Authenticator) verifyToken(token string) (*Principal, error) {
+	if a.secret == nil && a.keys == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not accepted", ErrInvalidCredentials)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: the token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: subject, Method: MethodJWT, Roles: knownRoles(rolesClaim(claims))}, nil
+}
+
+// rolesClaim returns the roles claim of claims, a list of strings or a
+// single one.
+func rolesClaim(claims jwt.MapClaims) []string {
+	switch roles := claims["roles"].(type) {
+	case string:
+		return []string{roles}
+	case []interface{}:
+		var list []string
+		for _, role := range roles {
+			if role, ok := role.(string); ok {
+				list = append(list, role)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// key returns the key that verifies token: the secret for the HMAC
+// algorithms, and otherwise the key of the JWKS with the kid of the token.
+// The parser only accepts the algorithms of the configured keys.
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	return a.keys.lookup(kid)
+}
+
+func (a *Authenticator) verifyAPIKey(key string) (*Principal, error) {
+	entry, ok := a.apiKeys.lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: entry.Name, Method: MethodAPIKey, Roles: knownRoles(entry.Roles)}, nil
+}
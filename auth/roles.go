@@ -0,0 +1,92 @@
+package auth
+
+import "fmt"
+
+// Roles a principal may have. A JWT lists them in its roles claim and an
+// API key in its entry; without any, a principal is a guest.
+const (
+	// RoleGuest books rooms and manages its own reservations.
+	RoleGuest = "guest"
+	// RoleFrontDesk manages every reservation and takes rooms out of
+	// service.
+	RoleFrontDesk = "front-desk"
+	// RoleAdmin may do anything, including changing room rates.
+	RoleAdmin = "admin"
+)
+
+// Permission is something a caller may be allowed to do.
+type Permission string
+
+// Permissions of the API. The Own variants are limited to the reservations
+// of the principal.
+const (
+	SearchRooms           Permission = "rooms:read"
+	ReadOwnReservations   Permission = "reservations:read:own"
+	ReadReservations      Permission = "reservations:read"
+	ManageOwnReservations Permission = "reservations:write:own"
+	ManageReservations    Permission = "reservations:write"
+	BlockRooms            Permission = "rooms:block"
+	SetRoomRates          Permission = "rooms:rates"
+)
+
+// rolePermissions is what each role is allowed.
+var rolePermissions = map[string][]Permission{
+	RoleGuest: {SearchRooms, ReadOwnReservations, ManageOwnReservations},
+	RoleFrontDesk: {SearchRooms, ReadOwnReservations, ReadReservations,
+		ManageOwnReservations, ManageReservations, BlockRooms},
+	RoleAdmin: {SearchRooms, ReadOwnReservations, ReadReservations,
+		ManageOwnReservations, ManageReservations, BlockRooms, SetRoomRates},
+}
+
+// anonymousPermissions is what callers without credentials are allowed,
+// where authentication is optional.
+var anonymousPermissions = []Permission{SearchRooms}
+
+// Can reports whether principal is allowed permission. A nil principal is
+// an anonymous caller.
+func (p *Principal) Can(permission Permission) bool {
+	if p == nil {
+		return containsPermission(anonymousPermissions, permission)
+	}
+	for _, role := range p.Roles {
+		if containsPermission(rolePermissions[role], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPermission(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// knownRoles returns roles without those this service does not define, or
+// the guest role when none is left. Tokens may carry the roles of other
+// services too.
+func knownRoles(roles []string) []string {
+	var known []string
+	for _, role := range roles {
+		if _, ok := rolePermissions[role]; ok {
+			known = append(known, role)
+		}
+	}
+	if len(known) == 0 {
+		return []string{RoleGuest}
+	}
+	return known
+}
+
+// checkRoles fails for a role this service does not define.
+func checkRoles(roles []string) error {
+	for _, role := range roles {
+		if _, ok := rolePermissions[role]; !ok {
+			return fmt.Errorf("unknown role %q, expected %s, %s or %s", role, RoleGuest, RoleFrontDesk, RoleAdmin)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+// Package client runs the operations of the reservation service through its
+// GraphQL schema, either in process against a store or over HTTP against a
+// running server, so that tools get the checks of the api package whichever
+// way they connect.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Executor runs a GraphQL operation and decodes its data into data.
+type Executor interface {
+	Execute(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error
+}
+
+// Error is an error returned by the service. It matches the api errors of
+// its code, so errors.Is(err, api.ErrNotFound) works over both executors.
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case api.ErrInvalidInput:
+		return e.Code == "BAD_USER_INPUT"
+	case api.ErrNotFound:
+		return e.Code == "NOT_FOUND"
+	case api.ErrConflict:
+		return e.Code == "CONFLICT"
+	}
+	return false
+}
+
+// graphQLError is an error as the service reports it.
+type graphQLError struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
+func firstError(errs []graphQLError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &Error{Message: errs[0].Message, Code: errs[0].Extensions.Code}
+}
+
+type local struct {
+	schema graphql.Schema
+}
+
+// Local executes operations in process, against repos.
+func Local(repos store.Repositories) (Executor, error) {
+	schema, err := api.AppSchema(repos)
+	if err != nil {
+		return nil, err
+	}
+	return local{schema: schema}, nil
+}
+
+func (l local) Execute(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	// Go through JSON both ways so that both executors see the same documents.
+	encoded, err := json.Marshal(variables)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return err
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         l.schema,
+		RequestString:  query,
+		VariableValues: values,
+		Context:        ctx,
+	})
+	encoded, err = json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return decode(encoded, data)
+}
+
+type remote struct {
+	endpoint string
+	client   *http.Client
+}
+
+// Remote executes operations on the GraphQL endpoint of a running server,
+// such as http://localhost:8080/api.
+func Remote(endpoint string, client *http.Client) Executor {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return remote{endpoint: endpoint, client: client}
+}
+
+func (r remote) Execute(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	encoded, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "application/") {
+		return fmt.Errorf("%s: %s", r.endpoint, response.Status)
+	}
+	return decode(encoded, data)
+}
+
+// decode reads a GraphQL response, returning its first error if any.
+func decode(encoded []byte, data interface{}) error {
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return err
+	}
+	if err := firstError(result.Errors); err != nil {
+		return err
+	}
+	if len(result.Data) == 0 || string(result.Data) == "null" {
+		return errors.New("the service returned no data")
+	}
+	return json.Unmarshal(result.Data, data)
+}
+
+// Client runs the operations of the service.
+type Client struct {
+	exec Executor
+}
+
+func New(exec Executor) *Client {
+	return &Client{exec: exec}
+}
+
+// roomFields leave out TotalCharge, which only availableRooms prices.
+const roomFields = `ID NumBeds AllowSmoking DailyRate CleaningFee`
+
+const reservationFields = `Id RoomId CheckinDate CheckoutDate TotalCharge Status`
+
+type room struct {
+	ID           string  `json:"ID"`
+	NumBeds      int     `json:"NumBeds"`
+	AllowSmoking bool    `json:"AllowSmoking"`
+	DailyRate    float64 `json:"DailyRate"`
+	CleaningFee  float64 `json:"CleaningFee"`
+	TotalCharge  float64 `json:"TotalCharge"`
+}
+
+func (r room) room() api.Room {
+	return api.Room(r)
+}
+
+type reservation struct {
+	ID           string  `json:"Id"`
+	RoomID       string  `json:"RoomId"`
+	CheckinDate  string  `json:"CheckinDate"`
+	CheckoutDate string  `json:"CheckoutDate"`
+	TotalCharge  float64 `json:"TotalCharge"`
+	Status       string  `json:"Status"`
+}
+
+func (r reservation) reservation() api.Reservation {
+	return api.Reservation{
+		ID:           r.ID,
+		RoomID:       r.RoomID,
+		CheckinDate:  r.CheckinDate,
+		CheckoutDate: r.CheckoutDate,
+		TotalCharge:  r.TotalCharge,
+		Status:       strings.ToLower(r.Status),
+	}
+}
+
+func rooms(list []room) []api.Room {
+	result := make([]api.Room, len(list))
+	for i, r := range list {
+		result[i] = r.room()
+	}
+	return result
+}
+
+// Rooms lists every room, by id.
+func (c *Client) Rooms(ctx context.Context) ([]api.Room, error) {
+	var data struct {
+		Rooms []room `json:"rooms"`
+	}
+	err := c.exec.Execute(ctx, `{ rooms { `+roomFields+` } }`, nil, &data)
+	return rooms(data.Rooms), err
+}
+
+// AvailableRooms lists the rooms free from start to end, priced for the
+// stay and cheapest first.
+func (c *Client) AvailableRooms(ctx context.Context, start string, end string, numBeds int, allowSmoking bool) ([]api.Room, error) {
+	var data struct {
+		AvailableRooms []room `json:"availableRooms"`
+	}
+	err := c.exec.Execute(ctx, `query ($start: Date!, $end: Date!, $numBeds: Int!, $allowSmoking: Boolean!) {
+		availableRooms(startDate: $start, endDate: $end, numBeds: $numBeds, allowSmoking: $allowSmoking) { `+roomFields+` TotalCharge }
+	}`, map[string]interface{}{"start": start, "end": end, "numBeds": numBeds, "allowSmoking": allowSmoking}, &data)
+	return rooms(data.AvailableRooms), err
+}
+
+// Reservation returns the reservation with id.
+func (c *Client) Reservation(ctx context.Context, id string) (api.Reservation, error) {
+	var data struct {
+		Reservation *reservation `json:"reservation"`
+	}
+	err := c.exec.Execute(ctx, `query ($id: String!) { reservation(id: $id) { `+reservationFields+` } }`,
+		map[string]interface{}{"id": id}, &data)
+	if err != nil {
+		return api.Reservation{}, err
+	}
+	if data.Reservation == nil {
+		return api.Reservation{}, &Error{Message: fmt.Sprintf("reservation %s does not exist", id), Code: "NOT_FOUND"}
+	}
+	return data.Reservation.reservation(), nil
+}
+
+// ReservationFilter narrows Reservations; empty fields match every
+// reservation. Start and End, given together, keep the reservations with a
+// night between them.
+type ReservationFilter struct {
+	RoomIDs  []string
+	Statuses []string
+	Start    string
+	End      string
+}
+
+// Reservations lists the reservations matching filter, by id, fetching
+// every page.
+func (c *Client) Reservations(ctx context.Context, filter ReservationFilter) ([]api.Reservation, error) {
+	graphQLFilter := map[string]interface{}{}
+	if len(filter.RoomIDs) > 0 {
+		graphQLFilter["roomIds"] = filter.RoomIDs
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = strings.ToUpper(status)
+		}
+		graphQLFilter["status"] = statuses
+	}
+	if filter.Start != "" || filter.End != "" {
+		graphQLFilter["overlaps"] = map[string]interface{}{"start": filter.Start, "end": filter.End}
+	}
+
+	var result []api.Reservation
+	var after interface{}
+	for {
+		var data struct {
+			Reservations struct {
+				Edges []struct {
+					Node reservation `json:"node"`
+				} `json:"edges"`
+				PageInfo struct {
+					EndCursor   string `json:"endCursor"`
+					HasNextPage bool   `json:"hasNextPage"`
+				} `json:"pageInfo"`
+			} `json:"reservations"`
+		}
+		err := c.exec.Execute(ctx, `query ($filter: ReservationFilter, $first: Int, $after: String) {
+			reservations(filter: $filter, first: $first, after: $after) {
+				edges { node { `+reservationFields+` } }
+				pageInfo { endCursor hasNextPage }
+			}
+		}`, map[string]interface{}{"filter": graphQLFilter, "first": api.MaxPageSize, "after": after}, &data)
+		if err != nil {
+			return result, err
+		}
+		for _, edge := range data.Reservations.Edges {
+			result = append(result, edge.Node.reservation())
+		}
+		if !data.Reservations.PageInfo.HasNextPage {
+			return result, nil
+		}
+		after = data.Reservations.PageInfo.EndCursor
+	}
+}
+
+// CreateReservation books a room from checkin to checkout.
+func (c *Client) CreateReservation(ctx context.Context, roomID string, checkin string, checkout string, totalCharge float64) (api.Reservation, error) {
+	var data struct {
+		CreateReservation reservation `json:"createReservation"`
+	}
+	err := c.exec.Execute(ctx, `mutation ($input: ReservationInput!) {
+		createReservation(input: $input) { `+reservationFields+` }
+	}`, map[string]interface{}{"input": map[string]interface{}{
+		"RoomID": roomID, "CheckinDate": checkin, "CheckoutDate": checkout, "TotalCharge": totalCharge,
+	}}, &data)
+	return data.CreateReservation.reservation(), err
+}
+
+// ReservationChanges are the changes to a reservation; empty fields and a
+// nil TotalCharge keep the current values.
+type ReservationChanges struct {
+	RoomID       string
+	CheckinDate  string
+	CheckoutDate string
+	TotalCharge  *float64
+}
+
+// UpdateReservation changes the room, dates or charge of a reservation.
+func (c *Client) UpdateReservation(ctx context.Context, id string, changes ReservationChanges) (api.Reservation, error) {
+	input := map[string]interface{}{}
+	for field, value := range map[string]string{
+		"RoomID":       changes.RoomID,
+		"CheckinDate":  changes.CheckinDate,
+		"CheckoutDate": changes.CheckoutDate,
+	} {
+		if value != "" {
+			input[field] = value
+		}
+	}
+	if changes.TotalCharge != nil {
+		input["TotalCharge"] = *changes.TotalCharge
+	}
+
+	var data struct {
+		UpdateReservation reservation `json:"updateReservation"`
+	}
+	err := c.exec.Execute(ctx, `mutation ($id: String!, $input: ReservationUpdateInput!) {
+		updateReservation(id: $id, input: $input) { `+reservationFields+` }
+	}`, map[string]interface{}{"id": id, "input": input}, &data)
+	return data.UpdateReservation.reservation(), err
+}
+
+// CancelReservation cancels a reservation or a block, releasing its room.
+func (c *Client) CancelReservation(ctx context.Context, id string) (api.Reservation, error) {
+	var data struct {
+		CancelReservation reservation `json:"cancelReservation"`
+	}
+	err := c.exec.Execute(ctx, `mutation ($id: String!) { cancelReservation(id: $id) { `+reservationFields+` } }`,
+		map[string]interface{}{"id": id}, &data)
+	return data.CancelReservation.reservation(), err
+}
+
+// BlockRoom takes a room out of service from start to end.
+func (c *Client) BlockRoom(ctx context.Context, roomID string, start string, end string) (api.Reservation, error) {
+	var data struct {
+		BlockRoom reservation `json:"blockRoom"`
+	}
+	err := c.exec.Execute(ctx, `mutation ($roomId: String!, $start: Date!, $end: Date!) {
+		blockRoom(roomId: $roomId, startDate: $start, endDate: $end) { `+reservationFields+` }
+	}`, map[string]interface{}{"roomId": roomID, "start": start, "end": end}, &data)
+	return data.BlockRoom.reservation(), err
+}
+
+// SetRoomRates changes the daily rate and cleaning fee of a room.
+func (c *Client) SetRoomRates(ctx context.Context, roomID string, dailyRate float64, cleaningFee float64) (api.Room, error) {
+	var data struct {
+		SetRoomRates room `json:"setRoomRates"`
+	}
+	err := c.exec.Execute(ctx, `mutation ($roomId: String!, $dailyRate: Float!, $cleaningFee: Float!) {
+		setRoomRates(roomId: $roomId, dailyRate: $dailyRate, cleaningFee: $cleaningFee) { `+roomFields+` }
+	}`, map[string]interface{}{"roomId": roomID, "dailyRate": dailyRate, "cleaningFee": cleaningFee}, &data)
+	return data.SetRoomRates.room(), err
+}
@@ -0,0 +1,515 @@
+// Package config loads the configuration shared by the service's binaries.
+//
+// Settings are read, from lowest to highest precedence, from the defaults
+// for the environment, an optional JSON file named by -config or
+// CONFIG_FILE, environment variables and command-line flags. Any
+// environment variable may instead be given as NAME_FILE, naming a file
+// that holds the value, so secrets can be mounted rather than exported.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environments the service knows defaults for.
+const (
+	Development = "development"
+	Test        = "test"
+	Production  = "production"
+)
+
+// Database drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Persisted query modes, see api.PersistedQueryStore.
+const (
+	PersistedQueriesOff       = "off"
+	PersistedQueriesAutomatic = "automatic"
+	PersistedQueriesAllowList = "allowlist"
+)
+
+// Trace exporters, see Tracing.
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// Rate limit stores, see RateLimit.
+const (
+	RateLimitMemory   = "memory"
+	RateLimitDatabase = "database"
+)
+
+// Config is the configuration of the service.
+type Config struct {
+	Env              string           `json:"env"`
+	API              API              `json:"api"`
+	GRPC             GRPC             `json:"grpc"`
+	Database         Database         `json:"database"`
+	GraphQL          GraphQL          `json:"graphql"`
+	PersistedQueries PersistedQueries `json:"persistedQueries"`
+	Log              Log              `json:"log"`
+	Tracing          Tracing          `json:"tracing"`
+	Auth             Auth             `json:"auth"`
+	RateLimit        RateLimit        `json:"rateLimit"`
+}
+
+// API configures the standalone HTTP server. DiagnosticsToken is the
+// bearer token /diagnostics requires; it is not served without one.
+// AllowedOrigins are the origins, such as https://hotel.example.com, whose
+// pages may open GraphQL WebSockets; connections from other origins, or
+// without an Origin header, are refused.
+type API struct {
+	Port             int      `json:"port"`
+	ShutdownTimeout  Duration `json:"shutdownTimeout"`
+	DiagnosticsToken string   `json:"diagnosticsToken"`
+	AllowedOrigins   []string `json:"allowedOrigins"`
+}
+
+// GRPC configures the gRPC server.
+type GRPC struct {
+	Port int `json:"port"`
+}
+
+// Database configures the store: a Postgres connection pool, or a SQLite
+// file at Path, which needs no other setting.
+type Database struct {
+	Driver          string   `json:"driver"`
+	Path            string   `json:"path"`
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	User            string   `json:"user"`
+	Password        string   `json:"password"`
+	Name            string   `json:"name"`
+	SSLMode         string   `json:"sslMode"`
+	MaxOpenConns    int      `json:"maxOpenConns"`
+	MaxIdleConns    int      `json:"maxIdleConns"`
+	ConnMaxLifetime Duration `json:"connMaxLifetime"`
+	ConnMaxIdleTime Duration `json:"connMaxIdleTime"`
+}
+
+// GraphQL bounds the operations clients may send. Zero disables a check.
+type GraphQL struct {
+	MaxDepth      int `json:"maxDepth"`
+	MaxComplexity int `json:"maxComplexity"`
+}
+
+// PersistedQueries configures automatic persisted queries and the allow-list.
+type PersistedQueries struct {
+	Mode string `json:"mode"`
+	File string `json:"file"`
+}
+
+// Log configures the structured logs. Level is debug, which adds the SQL
+// statements, info, warn or error.
+type Log struct {
+	Level string `json:"level"`
+}
+
+// Tracing configures the OpenTelemetry spans. Exporter is none, stdout,
+// which writes them to standard output, or otlp, which sends them to the
+// OTLP/HTTP collector at Endpoint.
+type Tracing struct {
+	Exporter string `json:"exporter"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Auth configures who may call the HTTP server, Lambda and gRPC APIs.
+// Bearer JWTs are verified with the keys of the JWKS in JWKSFile or with
+// JWTSecret (HMAC), and must come from Issuer for Audience when those are
+// set. Partners send an API key, checked against the SHA-256 hashes listed
+// in APIKeysFile. When Required, requests without credentials are refused.
+type Auth struct {
+	Required    bool   `json:"required"`
+	JWKSFile    string `json:"jwksFile"`
+	JWTSecret   string `json:"jwtSecret"`
+	Issuer      string `json:"issuer"`
+	Audience    string `json:"audience"`
+	APIKeysFile string `json:"apiKeysFile"`
+}
+
+// RateLimit bounds how often each client, an API key or JWT subject, or
+// the IP address of an anonymous caller, may search availability and
+// book. Each budget allows its PerMinute rate, with bursts of up to its
+// Burst requests; a zero rate disables it. Store is memory, which limits
+// the clients of each instance on its own, or database, which shares the
+// limits of every instance on the store's database.
+type RateLimit struct {
+	Store             string `json:"store"`
+	SearchesPerMinute int    `json:"searchesPerMinute"`
+	SearchBurst       int    `json:"searchBurst"`
+	BookingsPerMinute int    `json:"bookingsPerMinute"`
+	BookingBurst      int    `json:"bookingBurst"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in the
+// configuration file.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("must be a duration string such as \"30s\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("must be a duration such as \"30s\", not %q", value)
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// DataSourceName is the lib/pq connection string for the database.
+func (d Database) DataSourceName() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(d.Host), d.Port, quote(d.User), quote(d.Password), quote(d.Name), quote(d.SSLMode))
+}
+
+// redacted stands in for the secrets of a Redacted configuration.
+const redacted = "[redacted]"
+
+// Redacted returns the configuration with its secrets, when set, replaced,
+// so that it can be logged or reported.
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	if c.API.DiagnosticsToken != "" {
+		c.API.DiagnosticsToken = redacted
+	}
+	if c.Auth.JWTSecret != "" {
+		c.Auth.JWTSecret = redacted
+	}
+	return c
+}
+
+// quote quotes a connection string value so that it may contain spaces.
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Defaults returns the configuration used for env when nothing overrides it.
+// Lambda execution environments serve one invocation at a time, so they get
+// a much smaller connection pool than the standalone servers, and share
+// their rate limits through the database, as no instance lives long.
+func Defaults(env string) Config {
+	config := Config{
+		Env:  env,
+		API:  API{Port: 8080, ShutdownTimeout: Duration{30 * time.Second}},
+		GRPC: GRPC{Port: 8082},
+		Database: Database{
+			Driver:          DriverPostgres,
+			Path:            "hotel_" + env + ".db",
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Name:            "hotel_" + env,
+			SSLMode:         "require",
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration{30 * time.Minute},
+			ConnMaxIdleTime: Duration{5 * time.Minute},
+		},
+		GraphQL:          GraphQL{MaxDepth: 8, MaxComplexity: 1000},
+		PersistedQueries: PersistedQueries{Mode: PersistedQueriesAutomatic},
+		Log:              Log{Level: "info"},
+		Tracing:          Tracing{Exporter: TracingNone, Endpoint: "http://localhost:4318"},
+		RateLimit: RateLimit{
+			Store:             RateLimitMemory,
+			SearchesPerMinute: 60,
+			SearchBurst:       20,
+			BookingsPerMinute: 10,
+			BookingBurst:      5,
+		},
+	}
+	if env == Development || env == Test {
+		config.Database.SSLMode = "disable"
+		config.GraphQL = GraphQL{MaxDepth: 15, MaxComplexity: 5000}
+	}
+	if env == Development {
+		config.Log.Level = "debug"
+		config.API.AllowedOrigins = []string{"http://localhost:8080"}
+	}
+	if env == Production {
+		config.Auth.Required = true
+	}
+	if onLambda() {
+		config.Database.MaxOpenConns = 2
+		config.Database.MaxIdleConns = 2
+		config.Database.ConnMaxLifetime = Duration{15 * time.Minute}
+		config.Database.ConnMaxIdleTime = Duration{time.Minute}
+		config.RateLimit.Store = RateLimitDatabase
+	}
+	return config
+}
+
+// onLambda reports whether the process runs in a Lambda execution
+// environment.
+func onLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
+
+// defaultEnv is the environment when none is set: production inside
+// Lambda, so that a function deployed without ENV still requires
+// authentication and encrypts its database connections, else development.
+func defaultEnv() string {
+	if onLambda() {
+		return Production
+	}
+	return Development
+}
+
+// Load reads the configuration from the defaults, the configuration file,
+// the environment and args, the command-line flags without the program
+// name, and validates it. Every problem found is reported in the error.
+func Load(args []string) (Config, error) {
+	config, _, err := LoadArgs(args)
+	return config, err
+}
+
+// LoadArgs is Load for commands, and also returns the arguments that
+// follow the flags.
+func LoadArgs(args []string) (Config, []string, error) {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	configFile := flags.String("config", "", "JSON configuration file (default from $CONFIG_FILE)")
+	values := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			values[s.flag] = flags.String(s.flag, "", s.usage+" (default from $"+s.env+")")
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	var problems []string
+	env := func(name string) (string, bool) {
+		value, err := lookupEnv(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		return value, value != ""
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = env("CONFIG_FILE")
+	}
+	var file []byte
+	if path != "" {
+		var err error
+		if file, err = os.ReadFile(path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	// The environment picks the defaults, so find it before anything else.
+	var fileEnv struct {
+		Env string `json:"env"`
+	}
+	if file != nil {
+		json.Unmarshal(file, &fileEnv)
+	}
+	name := defaultEnv()
+	if fileEnv.Env != "" {
+		name = fileEnv.Env
+	}
+	if value, ok := env("ENV"); ok {
+		name = value
+	}
+	if value := *values["env"]; value != "" {
+		name = value
+	}
+	config := Defaults(name)
+
+	if file != nil {
+		decoder := json.NewDecoder(bytes.NewReader(file))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+		config.Env = name
+	}
+
+	for _, s := range settings {
+		if s.env == "ENV" {
+			continue
+		}
+		if value, ok := env(s.env); ok {
+			if err := s.set(&config, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+			}
+		}
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if s, ok := settingForFlag(f.Name); ok {
+			if err := s.set(&config, f.Value.String()); err != nil {
+				problems = append(problems, fmt.Sprintf("-%s: %v", f.Name, err))
+			}
+		}
+	})
+
+	if err := config.Validate(); err != nil {
+		problems = append(problems, err.(*Error).Problems...)
+	}
+	if len(problems) > 0 {
+		return config, flags.Args(), &Error{Problems: problems}
+	}
+	return config, flags.Args(), nil
+}
+
+// lookupEnv returns the value of the environment variable name, or the
+// contents of the file named by name_FILE.
+func lookupEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s and %s_FILE are both set", name, name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %v", name, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Error lists every problem found in the configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Env == Development || c.Env == Test || c.Env == Production,
+		"env must be %s, %s or %s, not %q", Development, Test, Production, c.Env)
+	check(validPort(c.API.Port), "api.port must be between 1 and 65535, not %d", c.API.Port)
+	check(c.API.ShutdownTimeout.Duration > 0, "api.shutdownTimeout must be positive, not %s", c.API.ShutdownTimeout)
+	check(c.API.DiagnosticsToken == "" || len(c.API.DiagnosticsToken) >= minTokenLength,
+		"api.diagnosticsToken must be at least %d characters", minTokenLength)
+	for _, origin := range c.API.AllowedOrigins {
+		check(validOrigin(origin), "api.allowedOrigins must be origins such as https://hotel.example.com, not %q", origin)
+	}
+	check(validPort(c.GRPC.Port), "grpc.port must be between 1 and 65535, not %d", c.GRPC.Port)
+
+	db := c.Database
+	switch db.Driver {
+	case DriverPostgres:
+		check(db.Host != "", "database.host is required")
+		check(validPort(db.Port), "database.port must be between 1 and 65535, not %d", db.Port)
+		check(db.User != "", "database.user is required")
+		check(db.Name != "", "database.name is required")
+		switch db.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "database.sslMode must be disable, require, verify-ca or verify-full, not %q", db.SSLMode)
+		}
+	case DriverSQLite:
+		check(db.Path != "", "database.path is required")
+	default:
+		check(false, "database.driver must be %s or %s, not %q", DriverPostgres, DriverSQLite, db.Driver)
+	}
+	check(db.MaxOpenConns >= 0, "database.maxOpenConns must not be negative")
+	check(db.MaxIdleConns >= 0, "database.maxIdleConns must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns,
+		"database.maxIdleConns (%d) must not exceed database.maxOpenConns (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	check(db.ConnMaxLifetime.Duration >= 0, "database.connMaxLifetime must not be negative")
+	check(db.ConnMaxIdleTime.Duration >= 0, "database.connMaxIdleTime must not be negative")
+
+	check(c.GraphQL.MaxDepth >= 0, "graphql.maxDepth must not be negative")
+	check(c.GraphQL.MaxComplexity >= 0, "graphql.maxComplexity must not be negative")
+
+	switch c.PersistedQueries.Mode {
+	case PersistedQueriesOff, PersistedQueriesAutomatic:
+	case PersistedQueriesAllowList:
+		check(c.PersistedQueries.File != "", "persistedQueries.mode %s requires persistedQueries.file", PersistedQueriesAllowList)
+	default:
+		check(false, "persistedQueries.mode must be %s, %s or %s, not %q",
+			PersistedQueriesOff, PersistedQueriesAutomatic, PersistedQueriesAllowList, c.PersistedQueries.Mode)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level must be debug, info, warn or error, not %q", c.Log.Level)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		endpoint, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != "",
+			"tracing.endpoint must be an http or https URL, not %q", c.Tracing.Endpoint)
+	default:
+		check(false, "tracing.exporter must be %s, %s or %s, not %q", TracingNone, TracingStdout, TracingOTLP, c.Tracing.Exporter)
+	}
+
+	auth := c.Auth
+	check(!auth.Required || auth.JWKSFile != "" || auth.JWTSecret != "" || auth.APIKeysFile != "",
+		"auth.required needs auth.jwksFile, auth.jwtSecret or auth.apiKeysFile")
+	check(auth.JWTSecret == "" || len(auth.JWTSecret) >= minSecretLength,
+		"auth.jwtSecret must be at least %d characters", minSecretLength)
+
+	limits := c.RateLimit
+	check(limits.Store == RateLimitMemory || limits.Store == RateLimitDatabase,
+		"rateLimit.store must be %s or %s, not %q", RateLimitMemory, RateLimitDatabase, limits.Store)
+	check(limits.SearchesPerMinute >= 0, "rateLimit.searchesPerMinute must not be negative")
+	check(limits.SearchesPerMinute == 0 || limits.SearchBurst >= 1, "rateLimit.searchBurst must be at least 1")
+	check(limits.BookingsPerMinute >= 0, "rateLimit.bookingsPerMinute must not be negative")
+	check(limits.BookingsPerMinute == 0 || limits.BookingBurst >= 1, "rateLimit.bookingBurst must be at least 1")
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// minTokenLength is the shortest bearer token accepted.
+const minTokenLength = 16
+
+// minSecretLength is the shortest JWT secret accepted: 256 bits for HS256.
+const minSecretLength = 32
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// validOrigin reports whether origin is a web origin: an http or https
+// scheme and a host, with no path.
+func validOrigin(origin string) bool {
+	parsed, err := url.Parse(origin)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" &&
+		parsed.Path == "" && parsed.RawQuery == "" && parsed.Fragment == "" && parsed.User == nil
+}
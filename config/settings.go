@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting is a configuration value that can be given as an environment
+// variable and, unless it is a secret, as a flag.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+var settings = []setting{
+	{"ENV", "env", "environment: development, test or production", stringValue(func(c *Config) *string { return &c.Env })},
+	{"API_PORT", "port", "HTTP port", intValue(func(c *Config) *int { return &c.API.Port })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time allowed for in-flight requests on shutdown", durationValue(func(c *Config) *Duration { return &c.API.ShutdownTimeout })},
+	{"DIAGNOSTICS_TOKEN", "", "", stringValue(func(c *Config) *string { return &c.API.DiagnosticsToken })},
+	{"API_ALLOWED_ORIGINS", "allowed-origins", "comma-separated origins whose pages may open WebSockets", listValue(func(c *Config) *[]string { return &c.API.AllowedOrigins })},
+	{"GRPC_PORT", "grpc-port", "gRPC port", intValue(func(c *Config) *int { return &c.GRPC.Port })},
+	{"DB_DRIVER", "db-driver", "database driver: postgres or sqlite", stringValue(func(c *Config) *string { return &c.Database.Driver })},
+	{"DB_PATH", "db-path", "SQLite database file", stringValue(func(c *Config) *string { return &c.Database.Path })},
+	{"DB_HOST", "db-host", "database host", stringValue(func(c *Config) *string { return &c.Database.Host })},
+	{"DB_PORT", "db-port", "database port", intValue(func(c *Config) *int { return &c.Database.Port })},
+	{"DB_USER", "db-user", "database user", stringValue(func(c *Config) *string { return &c.Database.User })},
+	{"DB_PASSWD", "", "", stringValue(func(c *Config) *string { return &c.Database.Password })},
+	{"DB_NAME", "db-name", "database name", stringValue(func(c *Config) *string { return &c.Database.Name })},
+	{"DB_SSLMODE", "db-sslmode", "database sslmode: disable, require, verify-ca or verify-full", stringValue(func(c *Config) *string { return &c.Database.SSLMode })},
+	{"DB_MAX_OPEN_CONNS", "", "", intValue(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+	{"DB_MAX_IDLE_CONNS", "", "", intValue(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+	{"DB_CONN_MAX_LIFETIME", "", "", durationValue(func(c *Config) *Duration { return &c.Database.ConnMaxLifetime })},
+	{"DB_CONN_MAX_IDLE_TIME", "", "", durationValue(func(c *Config) *Duration { return &c.Database.ConnMaxIdleTime })},
+	{"GRAPHQL_MAX_DEPTH", "", "", intValue(func(c *Config) *int { return &c.GraphQL.MaxDepth })},
+	{"GRAPHQL_MAX_COMPLEXITY", "", "", intValue(func(c *Config) *int { return &c.GraphQL.MaxComplexity })},
+	{"PERSISTED_QUERIES_MODE", "", "", stringValue(func(c *Config) *string { return &c.PersistedQueries.Mode })},
+	{"PERSISTED_QUERIES_FILE", "", "", stringValue(func(c *Config) *string { return &c.PersistedQueries.File })},
+	{"TRACING_EXPORTER", "tracing-exporter", "where spans go: none, stdout or otlp", stringValue(func(c *Config) *string { return &c.Tracing.Exporter })},
+	{"TRACING_ENDPOINT", "tracing-endpoint", "OTLP/HTTP collector URL", stringValue(func(c *Config) *string { return &c.Tracing.Endpoint })},
+	{"AUTH_REQUIRED", "auth-required", "refuse requests without credentials: true or false", boolValue(func(c *Config) *bool { return &c.Auth.Required })},
+	{"AUTH_JWKS_FILE", "auth-jwks-file", "JWKS file of the keys that sign JWTs", stringValue(func(c *Config) *string { return &c.Auth.JWKSFile })},
+	{"AUTH_JWT_SECRET", "", "", stringValue(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"AUTH_JWT_ISSUER", "auth-jwt-issuer", "required iss claim of JWTs", stringValue(func(c *Config) *string { return &c.Auth.Issuer })},
+	{"AUTH_JWT_AUDIENCE", "auth-jwt-audience", "required aud claim of JWTs", stringValue(func(c *Config) *string { return &c.Auth.Audience })},
+	{"AUTH_API_KEYS_FILE", "auth-api-keys-file", "JSON file of the hashed API keys of partners", stringValue(func(c *Config) *string { return &c.Auth.APIKeysFile })},
+	{"RATE_LIMIT_STORE", "rate-limit-store", "where rate limits are kept: memory or database", stringValue(func(c *Config) *string { return &c.RateLimit.Store })},
+	{"RATE_LIMIT_SEARCHES_PER_MINUTE", "", "", intValue(func(c *Config) *int { return &c.RateLimit.SearchesPerMinute })},
+	{"RATE_LIMIT_SEARCH_BURST", "", "", intValue(func(c *Config) *int { return &c.RateLimit.SearchBurst })},
+	{"RATE_LIMIT_BOOKINGS_PER_MINUTE", "", "", intValue(func(c *Config) *int { return &c.RateLimit.BookingsPerMinute })},
+	{"RATE_LIMIT_BOOKING_BURST", "", "", intValue(func(c *Config) *int { return &c.RateLimit.BookingBurst })},
+	{"LOG_LEVEL", "log-level", "least severe level logged: debug, info, warn or error", stringValue(func(c *Config) *string { return &c.Log.Level })},
+}
+
+func settingForFlag(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.flag == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func stringValue(field func(*Config) *string) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		*field(config) = value
+		return nil
+	}
+}
+
+func intValue(field func(*Config) *int) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, not %q", value)
+		}
+		*field(config) = parsed
+		return nil
+	}
+}
+
+func boolValue(field func(*Config) *bool) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, not %q", value)
+		}
+		*field(config) = parsed
+		return nil
+	}
+}
+
+func listValue(field func(*Config) *[]string) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field(config) = values
+		return nil
+	}
+}
+
+func durationValue(field func(*Config) *Duration) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s, not %q", value)
+		}
+		*field(config) = Duration{parsed}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/tracing"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run serves gRPC until SIGINT or SIGTERM, returning once the calls in
+// flight are done, the database is closed and the spans are flushed.
+func run() error {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	if err := logging.SetLevel(cfg.Log.Level); err != nil {
+		return err
+	}
+	settings, err := api.NewSettings(cfg)
+	if err != nil {
+		return err
+	}
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown(ctx)
+	}()
+
+	db, err := api.OpenStore(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return api.GrpcApiHandler(ctx, settings, db.Repositories())
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/client"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/seed"
+)
+
+const usage = `usage: hotelctl [flags] command [command flags] [arguments]
+
+Commands:
+  rooms                                       list every room
+  available -start DATE -end DATE [-beds N] [-smoking]
+                                              search the rooms free for a stay
+  reservations [-room ID]... [-status S]... [-start DATE -end DATE]
+                                              list reservations
+  show ID                                     show a reservation
+  create -room ID -checkin DATE -checkout DATE [-charge AMOUNT]
+                                              book a room, at its price by default
+  modify ID [-room ID] [-checkin DATE] [-checkout DATE] [-charge AMOUNT]
+                                              change a reservation
+  cancel ID                                   cancel a reservation or a block
+  block -room ID -start DATE -end DATE        take a room out of service
+  rates -room ID -daily AMOUNT -cleaning AMOUNT
+                                              change the rates of a room
+  export [-format json|csv] DIRECTORY         write every room and reservation as a seed set
+  apikey NAME [-role ROLE]...                 make an API key for a partner, and its entry
+                                              for the API keys file; roles are guest,
+                                              front-desk or admin, by default guest
+
+Every command takes -o table|json, the output format, and -endpoint URL,
+the GraphQL endpoint of a running server, by default $HOTELCTL_ENDPOINT;
+without one, hotelctl works on the configured database directly. A server
+requiring credentials gets -token, a JWT, by default $HOTELCTL_TOKEN, or
+-api-key, by default $HOTELCTL_API_KEY.
+The flags before the command are those of the server, see -h.`
+
+// requestTimeout bounds each command.
+const requestTimeout = 30 * time.Second
+
+// Administers rooms and reservations through the same GraphQL operations
+// as the API, on the database or on a running server.
+func main() {
+	log.SetFlags(0)
+	cfg, args, err := config.LoadArgs(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	format := flags.String("o", "table", "output format, table or json")
+	endpoint := flags.String("endpoint", os.Getenv("HOTELCTL_ENDPOINT"), "GraphQL endpoint of a running server")
+	token := flags.String("token", os.Getenv("HOTELCTL_TOKEN"), "JWT to send to the server")
+	apiKey := flags.String("api-key", os.Getenv("HOTELCTL_API_KEY"), "API key to send to the server")
+	cmd := &command{flags: flags, out: os.Stdout}
+	action := run(cmd)
+	positional, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatalf("-o must be table or json, not %q", *format)
+	}
+	cmd.json = *format == "json"
+
+	var exec client.Executor
+	switch {
+	case offline[args[0]]:
+	case *endpoint != "":
+		exec = client.Remote(*endpoint, &http.Client{Transport: credentials{token: *token, apiKey: *apiKey}})
+	default:
+		db, err := api.OpenStore(cfg.Database)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		if exec, err = client.Local(db.Repositories()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	cmd.client = client.New(exec)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if err := action(ctx, positional); err != nil {
+		if errors.Is(err, errUsage) {
+			log.Fatal(usage)
+		}
+		log.Fatal(err)
+	}
+}
+
+var errUsage = errors.New("usage")
+
+// offline are the commands that need neither a server nor a database.
+var offline = map[string]bool{"apikey": true}
+
+// credentials adds the token or API key to the requests sent to a server.
+type credentials struct {
+	token  string
+	apiKey string
+}
+
+func (c credentials) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.apiKey != "" {
+		request.Header.Set(auth.APIKeyHeader, c.apiKey)
+	}
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// command is the state shared by the commands.
+type command struct {
+	flags  *flag.FlagSet
+	client *client.Client
+	out    io.Writer
+	json   bool
+}
+
+// commands declare their flags on the command and return the action to run
+// once the flags are parsed.
+var commands = map[string]func(c *command) func(ctx context.Context, args []string) error{
+	"rooms": func(c *command) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 {
+				return errUsage
+			}
+			rooms, err := c.client.Rooms(ctx)
+			if err != nil {
+				return err
+			}
+			return c.printRooms(rooms, false)
+		}
+	},
+
+	"available": func(c *command) func(context.Context, []string) error {
+		start := c.flags.String("start", "", "first night, YYYY-MM-DD")
+		end := c.flags.String("end", "", "checkout date, YYYY-MM-DD")
+		beds := c.flags.Int("beds", 1, "least number of beds")
+		smoking := c.flags.Bool("smoking", false, "a smoking room")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 || *start == "" || *end == "" {
+				return errUsage
+			}
+			rooms, err := c.client.AvailableRooms(ctx, *start, *end, *beds, *smoking)
+			if err != nil {
+				return err
+			}
+			return c.printRooms(rooms, true)
+		}
+	},
+
+	"reservations": func(c *command) func(context.Context, []string) error {
+		var rooms, statuses listFlag
+		c.flags.Var(&rooms, "room", "only the reservations of this room; repeatable")
+		c.flags.Var(&statuses, "status", "only the reservations with this status, confirmed, cancelled or blocked; repeatable")
+		start := c.flags.String("start", "", "only the reservations with a night from this date, YYYY-MM-DD")
+		end := c.flags.String("end", "", "only the reservations with a night before this date, YYYY-MM-DD")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 || (*start == "") != (*end == "") {
+				return errUsage
+			}
+			reservations, err := c.client.Reservations(ctx, client.ReservationFilter{
+				RoomIDs: rooms, Statuses: statuses, Start: *start, End: *end,
+			})
+			if err != nil {
+				return err
+			}
+			return c.printReservations(reservations)
+		}
+	},
+
+	"show": func(c *command) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			reservation, err := c.client.Reservation(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return c.printReservation(reservation)
+		}
+	},
+
+	"create": func(c *command) func(context.Context, []string) error {
+		room := c.flags.String("room", "", "room to book")
+		checkin := c.flags.String("checkin", "", "checkin date, YYYY-MM-DD")
+		checkout := c.flags.String("checkout", "", "checkout date, YYYY-MM-DD")
+		var charge optionalAmount
+		c.flags.Var(&charge, "charge", "total charge, by default the price of the room for the stay")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 || *room == "" || *checkin == "" || *checkout == "" {
+				return errUsage
+			}
+			if charge.amount == nil {
+				price, err := c.price(ctx, *room, *checkin, *checkout)
+				if err != nil {
+					return err
+				}
+				charge.amount = &price
+			}
+			reservation, err := c.client.CreateReservation(ctx, *room, *checkin, *checkout, *charge.amount)
+			if err != nil {
+				return err
+			}
+			return c.printReservation(reservation)
+		}
+	},
+
+	"modify": func(c *command) func(context.Context, []string) error {
+		room := c.flags.String("room", "", "new room")
+		checkin := c.flags.String("checkin", "", "new checkin date, YYYY-MM-DD")
+		checkout := c.flags.String("checkout", "", "new checkout date, YYYY-MM-DD")
+		var charge optionalAmount
+		c.flags.Var(&charge, "charge", "new total charge")
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			reservation, err := c.client.UpdateReservation(ctx, args[0], client.ReservationChanges{
+				RoomID: *room, CheckinDate: *checkin, CheckoutDate: *checkout, TotalCharge: charge.amount,
+			})
+			if err != nil {
+				return err
+			}
+			return c.printReservation(reservation)
+		}
+	},
+
+	"cancel": func(c *command) func(context.Context, []string) error {
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			reservation, err := c.client.CancelReservation(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return c.printReservation(reservation)
+		}
+	},
+
+	"block": func(c *command) func(context.Context, []string) error {
+		room := c.flags.String("room", "", "room to take out of service")
+		start := c.flags.String("start", "", "first night, YYYY-MM-DD")
+		end := c.flags.String("end", "", "date the room is back in service, YYYY-MM-DD")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 || *room == "" || *start == "" || *end == "" {
+				return errUsage
+			}
+			block, err := c.client.BlockRoom(ctx, *room, *start, *end)
+			if err != nil {
+				return err
+			}
+			return c.printReservation(block)
+		}
+	},
+
+	"rates": func(c *command) func(context.Context, []string) error {
+		room := c.flags.String("room", "", "room to reprice")
+		var daily, cleaning optionalAmount
+		c.flags.Var(&daily, "daily", "new daily rate")
+		c.flags.Var(&cleaning, "cleaning", "new cleaning fee")
+		return func(ctx context.Context, args []string) error {
+			if len(args) > 0 || *room == "" || daily.amount == nil || cleaning.amount == nil {
+				return errUsage
+			}
+			repriced, err := c.client.SetRoomRates(ctx, *room, *daily.amount, *cleaning.amount)
+			if err != nil {
+				return err
+			}
+			return c.printRooms([]api.Room{repriced}, false)
+		}
+	},
+
+	"export": func(c *command) func(context.Context, []string) error {
+		format := c.flags.String("format", "json", "file format, json or csv")
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			rooms, err := c.client.Rooms(ctx)
+			if err != nil {
+				return err
+			}
+			reservations, err := c.client.Reservations(ctx, client.ReservationFilter{})
+			if err != nil {
+				return err
+			}
+			if err := seed.Write(args[0], seed.Set{Rooms: rooms, Reservations: reservations}, *format); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s: exported %d rooms and %d reservations\n", args[0], len(rooms), len(reservations))
+			return nil
+		}
+	},
+
+	"apikey": func(c *command) func(context.Context, []string) error {
+		var roles listFlag
+		c.flags.Var(&roles, "role", "role of the partner, guest, front-desk or admin; repeatable")
+		return func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return errUsage
+			}
+			key, entry, err := auth.NewAPIKey(args[0], roles...)
+			if err != nil {
+				return err
+			}
+			if c.json {
+				return c.printJSON(map[string]interface{}{"key": key, "entry": entry})
+			}
+			encoded, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(c.out, "key:   %s\nentry: %s\n", key, encoded)
+			fmt.Fprintln(os.Stderr, "Give the key to the partner and add the entry to the API keys file; the key is not shown again.")
+			return nil
+		}
+	},
+}
+
+// price is what the room charges for the stay, as availableRooms prices it.
+func (c *command) price(ctx context.Context, roomID string, checkin string, checkout string) (float64, error) {
+	rooms, err := c.client.Rooms(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, room := range rooms {
+		if room.ID == roomID {
+			in, errIn := time.Parse("2006-01-02", checkin)
+			out, errOut := time.Parse("2006-01-02", checkout)
+			if errIn != nil || errOut != nil {
+				// Let the service report the dates.
+				return 0, nil
+			}
+			nights := out.Sub(in).Hours() / 24
+			return room.DailyRate*nights + room.CleaningFee, nil
+		}
+	}
+	return 0, fmt.Errorf("room %s does not exist", roomID)
+}
+
+// parseInterspersed parses flags that may follow the arguments, as in
+// modify 12 -charge 300, and returns the arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// listFlag collects the values of a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalAmount is an amount flag that tells whether it was given.
+type optionalAmount struct {
+	amount *float64
+}
+
+func (a *optionalAmount) String() string {
+	if a.amount == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*a.amount, 'f', -1, 64)
+}
+
+func (a *optionalAmount) Set(value string) error {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	a.amount = &amount
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+)
+
+func (c *command) printJSON(value interface{}) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// printRooms prints rooms, with the price of the stay when priced.
+func (c *command) printRooms(rooms []api.Room, priced bool) error {
+	if c.json {
+		if rooms == nil {
+			rooms = []api.Room{}
+		}
+		return c.printJSON(rooms)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	header := "ID\tBEDS\tSMOKING\tDAILY RATE\tCLEANING FEE"
+	if priced {
+		header += "\tTOTAL CHARGE"
+	}
+	fmt.Fprintln(w, header)
+	for _, room := range rooms {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s", room.ID, room.NumBeds, yesNo(room.AllowSmoking),
+			amount(room.DailyRate), amount(room.CleaningFee))
+		if priced {
+			fmt.Fprintf(w, "\t%s", amount(room.TotalCharge))
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// printReservation prints one reservation, as an object in JSON.
+func (c *command) printReservation(reservation api.Reservation) error {
+	if c.json {
+		return c.printJSON(reservation)
+	}
+	return c.printReservations([]api.Reservation{reservation})
+}
+
+func (c *command) printReservations(reservations []api.Reservation) error {
+	if c.json {
+		if reservations == nil {
+			reservations = []api.Reservation{}
+		}
+		return c.printJSON(reservations)
+	}
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tROOM\tCHECKIN\tCHECKOUT\tTOTAL CHARGE\tSTATUS")
+	for _, r := range reservations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.RoomID, r.CheckinDate, r.CheckoutDate, amount(r.TotalCharge), r.Status)
+	}
+	return w.Flush()
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func amount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
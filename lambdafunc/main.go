@@ -1,11 +1,44 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/tracing"
 )
 
 func main() {
-	lambda.Start(api.GraphQlApiHandler)
-	//api.DebubGraphQlApiHandler()   // for local testing
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := logging.SetLevel(cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+	settings, err := api.NewSettings(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Each invocation flushes its own spans, as the environment may be
+	// frozen between invocations.
+	if _, err := tracing.Setup(context.Background(), cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	// The pool outlives the invocation, so warm invocations reuse its connections.
+	db, err := api.OpenStore(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handler, err := api.NewGraphQlApiHandler(settings, db.Repositories())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lambda.Start(handler)
 }
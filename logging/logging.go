@@ -0,0 +1,113 @@
+// Package logging writes the service's structured logs, one JSON object per
+// line on standard error, and carries the correlation id of a request in
+// its context so that every line logged for the request, SQL statements
+// included, can be found by it, as can its trace.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// RequestIDHeader carries the correlation id of a request, both ways: a
+// client or proxy may set it, and every response reports it.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the ids accepted from clients, which end up in
+// every log line of the request.
+const maxRequestIDLength = 128
+
+var (
+	level  = new(slog.LevelVar)
+	logger atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	SetOutput(os.Stderr)
+}
+
+// SetOutput sends the logs to w.
+func SetOutput(w io.Writer) {
+	logger.Store(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+}
+
+// SetLevel sets the least severe level logged: debug, info, warn or error.
+// SQL statements are logged at debug.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Logger is the logger of the process, for what happens outside requests.
+func Logger() *slog.Logger {
+	return logger.Load()
+}
+
+// FromContext is the logger for the request of ctx: its lines carry the
+// request id and the trace id, when there are.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := Logger()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("requestId", id)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("traceId", span.TraceID().String(), "spanId", span.SpanID().String())
+	}
+	return logger
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the correlation id of its request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID is the correlation id carried by ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random correlation id.
+func NewRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// RequestIDOr returns candidate, an id given by a client or the platform,
+// when it is a usable id, and a new id otherwise.
+func RequestIDOr(candidate string) string {
+	candidate = strings.TrimSpace(candidate)
+	if candidate == "" || len(candidate) > maxRequestIDLength {
+		return NewRequestID()
+	}
+	for _, c := range candidate {
+		if c < '!' || c > '~' {
+			return NewRequestID()
+		}
+	}
+	return candidate
+}
+
+// Milliseconds reports d in the unit of every duration in the logs.
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/migrate"
+)
+
+const usage = `usage: migrate [flags] command
+
+Commands:
+  up          apply every pending migration
+  down        roll back the last applied migration
+  status      list the migrations and when they were applied
+  to VERSION  apply or roll back migrations until VERSION, 0 for an empty schema
+
+The flags are those of the server, see -h.`
+
+// Migrates the configured database to the schema embedded in this binary.
+// It refuses to touch a database migrated by a newer release.
+func main() {
+	cfg, args, err := config.LoadArgs(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	db, err := api.ConnectStore(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	migrator := db.Migrator()
+	ctx := context.Background()
+	before, err := migrator.Version(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var ran []migrate.Migration
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		ran, err = migrator.Up(ctx)
+	case command == "down" && len(args) == 1:
+		ran, err = migrator.Down(ctx)
+	case command == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			log.Fatalf("%q is not a migration version", args[1])
+		}
+		ran, err = migrator.To(ctx, version)
+	case command == "status" && len(args) == 1:
+		err = printStatus(ctx, migrator)
+	default:
+		log.Fatal(usage)
+	}
+
+	for _, migration := range ran {
+		if migration.Version > before {
+			fmt.Println("applied", migration.Name)
+		} else {
+			fmt.Println("rolled back", migration.Name)
+		}
+	}
+	var ahead *migrate.AheadError
+	if errors.As(err, &ahead) && args[0] != "status" {
+		log.Fatalf("refusing to migrate: %v", err)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if args[0] != "status" {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("the database is at version", version)
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	w.Flush()
+	return err
+}
@@ -1,9 +0,0 @@
-package main
-
-import (
-	"github.com/willsams/go-hotel-reservation-service/api"
-)
-
-func main() {
-	api.PlaygroundHandler()
-}
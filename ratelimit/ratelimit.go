@@ -0,0 +1,201 @@
+// Package ratelimit limits how often each client may call the API with
+// token buckets. A client has a bucket per budget, holding up to Burst
+// tokens and refilled at Rate tokens a second; every request takes a token
+// and is refused when none is left. The buckets live in a Store: in memory
+// for a single process, or in a database that every instance shares.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/willsams/go-hotel-reservation-service/logging"
+)
+
+// Budgets limited separately, so that scraping availability does not stop
+// a client from booking.
+const (
+	Searches = "searches"
+	Bookings = "bookings"
+)
+
+// sweepInterval is how often the buckets of clients gone idle are
+// forgotten, and sweepTimeout how long a sweep may take.
+const (
+	sweepInterval = time.Minute
+	sweepTimeout  = 10 * time.Second
+)
+
+// Limit is the token bucket of a budget: Rate tokens added a second, up to
+// Burst.
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// PerMinute is the limit of requests a minute, burst of them at once.
+func PerMinute(requests int, burst int) Limit {
+	return Limit{Rate: float64(requests) / 60, Burst: float64(burst)}
+}
+
+// wait is the time it takes to refill tokens.
+func (l Limit) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / l.Rate * float64(time.Second)))
+}
+
+// Store keeps the buckets. TakeToken refills the bucket of key up to now,
+// a new bucket being full, takes a token from it if it holds one, and
+// returns the tokens left and whether one was taken. Concurrent requests of
+// a client take from the same bucket, so it must be atomic.
+type Store interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst float64, now time.Time) (tokens float64, taken bool, err error)
+}
+
+// Sweeper is implemented by stores that can forget the buckets left alone
+// since idleSince, which are full again and so no different from new ones.
+type Sweeper interface {
+	SweepBuckets(ctx context.Context, idleSince time.Time) error
+}
+
+// Decision is the outcome of a request against a budget. Limit is zero when
+// the budget is not limited. RetryAfter is set when the request is refused.
+type Decision struct {
+	Budget     string
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter takes the tokens of requests from the buckets of a Store.
+type Limiter struct {
+	store  Store
+	limits map[string]Limit
+	idle   time.Duration
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// New returns the limiter of the budgets in limits, keeping the buckets in
+// store. Budgets without a positive rate and burst are not limited.
+func New(store Store, limits map[string]Limit) *Limiter {
+	l := &Limiter{store: store, limits: map[string]Limit{}}
+	for budget, limit := range limits {
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			continue
+		}
+		l.limits[budget] = limit
+		if refill := limit.wait(limit.Burst); refill > l.idle {
+			l.idle = refill
+		}
+	}
+	return l
+}
+
+// Take takes a token from the bucket of client for budget, and reports
+// whether the request is allowed.
+func (l *Limiter) Take(ctx context.Context, budget string, client string) (Decision, error) {
+	limit, ok := l.limits[budget]
+	if !ok {
+		return Decision{Budget: budget, Allowed: true}, nil
+	}
+	now := time.Now()
+	l.sweep(now)
+
+	tokens, taken, err := l.store.TakeToken(ctx, budget+":"+client, limit.Rate, limit.Burst, now)
+	if err != nil {
+		return Decision{}, err
+	}
+	decision := Decision{
+		Budget:    budget,
+		Allowed:   taken,
+		Limit:     int(limit.Burst),
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     limit.wait(limit.Burst - tokens),
+	}
+	if !taken {
+		decision.RetryAfter = limit.wait(1 - tokens)
+	}
+	return decision, nil
+}
+
+// sweep forgets the idle buckets in the background, at most once every
+// sweepInterval, when the store can.
+func (l *Limiter) sweep(now time.Time) {
+	sweeper, ok := l.store.(Sweeper)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	due := now.Sub(l.swept) >= sweepInterval
+	if due {
+		l.swept = now
+	}
+	l.mu.Unlock()
+	if !due {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sweepTimeout)
+		defer cancel()
+		if err := sweeper.SweepBuckets(ctx, now.Add(-l.idle)); err != nil {
+			logging.Logger().Warn("sweeping rate limit buckets failed", "error", err.Error())
+		}
+	}()
+}
+
+// Memory keeps the buckets in the process. Every instance of a service
+// using it limits its clients on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}}
+}
+
+func (m *Memory) TakeToken(ctx context.Context, key string, rate float64, burst float64, now time.Time) (float64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		m.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+		b.updated = now
+	}
+	taken := b.tokens >= 1
+	if taken {
+		b.tokens--
+	}
+	return b.tokens, taken, nil
+}
+
+func (m *Memory) SweepBuckets(ctx context.Context, idleSince time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, b := range m.buckets {
+		if b.updated.Before(idleSince) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
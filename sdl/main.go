@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Prints the SDL of the GraphQL schema, or writes it to the file named by -o.
+// Overwriting a snapshot with breaking changes requires -allow-breaking.
+func main() {
+	output := flag.String("o", "", "write the SDL to this file instead of stdout")
+	allowBreaking := flag.Bool("allow-breaking", false, "accept breaking changes against the existing file")
+	flag.Parse()
+
+	schema, err := api.AppSchema(store.Repositories{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	sdl := api.PrintSchema(schema)
+
+	if *output == "" {
+		fmt.Print(sdl)
+		return
+	}
+
+	previous, err := os.ReadFile(*output)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	if err == nil && !*allowBreaking {
+		changes, err := api.BreakingChanges(string(previous), sdl)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(changes) > 0 {
+			for _, change := range changes {
+				fmt.Fprintln(os.Stderr, change)
+			}
+			log.Fatalf("%s: %d breaking changes, rerun with -allow-breaking to accept them", *output, len(changes))
+		}
+	}
+
+	if err := os.WriteFile(*output, []byte(sdl), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/seed"
+)
+
+const usage = `usage: seed [flags] [check] [directory]
+
+Loads the rooms and reservations of directory, db/seeds/$ENV by default,
+into the configured database, or with check only validates them.
+The flags are those of the server, see -h.`
+
+// Loads the seed set of the environment, validating all of it first.
+func main() {
+	cfg, args, err := config.LoadArgs(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	check := len(args) > 0 && args[0] == "check"
+	if check {
+		args = args[1:]
+	}
+	dir := filepath.Join("db", "seeds", cfg.Env)
+	switch len(args) {
+	case 0:
+	case 1:
+		dir = args[0]
+	default:
+		log.Fatal(usage)
+	}
+
+	set, err := seed.Read(os.DirFS(dir), ".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := set.Validate(); err != nil {
+		log.Fatalf("%s: %v", dir, err)
+	}
+	if check {
+		fmt.Printf("%s: %d rooms and %d reservations are valid\n", dir, len(set.Rooms), len(set.Reservations))
+		return
+	}
+
+	db, err := api.OpenStore(cfg.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	if err := seed.Load(context.Background(), db.Repositories(), set); err != nil {
+		log.Fatalf("%s: %v", dir, err)
+	}
+	fmt.Printf("%s: %d rooms and %d reservations are loaded\n", dir, len(set.Rooms), len(set.Reservations))
+}
@@ -1,13 +1,143 @@
 package specs
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+	"github.com/willsams/go-hotel-reservation-service/store/postgres"
+	"github.com/willsams/go-hotel-reservation-service/store/seed"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
 )
 
+// testConfig is loaded like the binaries load theirs, but always points at
+// the hotel_test database.
+var testConfig config.Config
+
 func TestSpecs(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Specs Suite")
 }
+
+var _ = BeforeSuite(func() {
+	// Keep the request logs with the output of the spec that made them.
+	logging.SetOutput(GinkgoWriter)
+
+	// The specs book stays in early 2023, so pin the clock before those arrivals.
+	api.Now = func() time.Time {
+		return time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	var err error
+	testConfig, err = config.Load(nil)
+	Expect(err).To(BeNil())
+	testConfig.Database.Name = "hotel_test"
+})
+
+// withEachStore adds the specs of body once for each store: in memory, in
+// a SQLite database in memory and in the hotel_test database, migrated
+// first, so that they all behave the same. body gets a function returning the empty
+// repositories of the running spec; the postgres ones are emptied before
+// and after each spec.
+func withEachStore(body func(repositories func() store.Repositories)) {
+	var repos store.Repositories
+	repositories := func() store.Repositories { return repos }
+
+	Context("with the memory store", func() {
+		BeforeEach(func() {
+			repos = memory.New().Repositories()
+		})
+
+		body(repositories)
+	})
+
+	Context("with the sqlite store", func() {
+		BeforeEach(func() {
+			db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+			Expect(err).To(BeNil())
+			DeferCleanup(db.Close)
+			repos = db.Repositories()
+		})
+
+		body(repositories)
+	})
+
+	Context("with the postgres store", func() {
+		BeforeEach(func() {
+			db, err := postgres.Open(testConfig.Database)
+			Expect(err).To(BeNil())
+			_, err = db.Migrator().Up(context.Background())
+			Expect(err).To(BeNil())
+			empty := func() {
+				_, err := db.DB().Exec("DELETE FROM Reservations")
+				Expect(err).To(BeNil())
+				_, err = db.DB().Exec("DELETE FROM Rooms")
+				Expect(err).To(BeNil())
+				_, err = db.DB().Exec("DELETE FROM rate_limit_buckets")
+				Expect(err).To(BeNil())
+			}
+			empty()
+			DeferCleanup(func() {
+				empty()
+				Expect(db.Close()).To(Succeed())
+			})
+			repos = db.Repositories()
+		})
+
+		body(repositories)
+	})
+}
+
+func saveRooms(repos store.Repositories, rooms ...api.Room) {
+	for _, room := range rooms {
+		Expect(repos.Rooms.SaveRoom(context.Background(), room)).To(Succeed())
+	}
+}
+
+// saveReservations stores reservations as they are, bypassing the checks
+// of the resolvers.
+func saveReservations(repos store.Repositories, reservations ...api.Reservation) {
+	for _, reservation := range reservations {
+		_, err := repos.Reservations.CreateReservation(context.Background(), reservation)
+		Expect(err).To(BeNil())
+	}
+}
+
+// loadFixtures loads the seed set in db/seeds/name, validated as the seed
+// command does.
+func loadFixtures(repos store.Repositories, name string) seed.Set {
+	set, err := seed.Read(os.DirFS(filepath.Join("..", "db", "seeds", name)), ".")
+	Expect(err).To(BeNil())
+	Expect(seed.Load(context.Background(), repos, set)).To(Succeed())
+	return set
+}
+
+// serveWebSockets runs handler on a test server, closed once the spec is
+// done. The server does not wait for the WebSockets it upgraded, so the
+// cleanup does, keeping their spans and logs out of the specs that follow.
+func serveWebSockets(handler http.Handler) *httptest.Server {
+	var serving sync.WaitGroup
+	running := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serving.Add(1)
+		defer serving.Done()
+		handler.ServeHTTP(w, r)
+	}))
+	DeferCleanup(func() {
+		running.Close()
+		serving.Wait()
+	})
+	return running
+}
@@ -0,0 +1,65 @@
+package specs
+
+import (
+	"context"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/client"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+var _ = ginkgo.Describe("When a cancelled stay is rebooked", func() {
+	withEachStore(func(repositories func() store.Repositories) {
+		var (
+			ctx context.Context
+			c   *client.Client
+		)
+
+		ginkgo.BeforeEach(func() {
+			ctx = context.Background()
+			repos := repositories()
+			saveRooms(repos, api.Room{ID: "101", NumBeds: 1, DailyRate: 100, CleaningFee: 10})
+			exec, err := client.Local(repos)
+			gomega.Expect(err).To(gomega.BeNil())
+			c = client.New(exec)
+		})
+
+		ginkgo.It("books the room again for the same dates", func() {
+			first, err := c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = c.CancelReservation(ctx, first.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+
+			second, err := c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(second.ID).NotTo(gomega.Equal(first.ID))
+			gomega.Expect(second.Status).To(gomega.Equal(api.ReservationConfirmed))
+
+			_, err = c.CancelReservation(ctx, second.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("books the room for the dates of a cancelled block", func() {
+			block, err := c.BlockRoom(ctx, "101", "2030-01-10", "2030-01-12")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = c.CancelReservation(ctx, block.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+
+			reservation, err := c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservation.Status).To(gomega.Equal(api.ReservationConfirmed))
+		})
+
+		ginkgo.It("still refuses a second booking of dates that are not cancelled", func() {
+			_, err := c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = c.CreateReservation(ctx, "101", "2030-01-10", "2030-01-12", 210)
+			gomega.Expect(err).To(gomega.MatchError(api.ErrConflict))
+		})
+	})
+})
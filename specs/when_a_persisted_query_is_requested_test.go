@@ -0,0 +1,102 @@
+package specs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When a persisted query is requested", func() {
+	const query = "query Kind { __typename }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	var handler api.LambdaHandler
+
+	serve := func(persisted *api.PersistedQueryStore) {
+		var err error
+		handler, err = api.NewGraphQlApiHandler(api.Settings{PersistedQueries: persisted}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+	}
+
+	ginkgo.BeforeEach(func() {
+		serve(&api.PersistedQueryStore{
+			Mode:  api.PersistedQueriesAutomatic,
+			Cache: api.NewMemoryQueryCache(10),
+		})
+	})
+
+	send := func(body string) map[string]interface{} {
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       body,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+
+		var result map[string]interface{}
+		gomega.Expect(json.Unmarshal([]byte(response.Body), &result)).To(gomega.Succeed())
+		return result
+	}
+	hashOnly := fmt.Sprintf(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, hash)
+	withQuery := fmt.Sprintf(`{"query": %q, "extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, query, hash)
+	errorCode := func(result map[string]interface{}) interface{} {
+		errors := result["errors"].([]interface{})
+		return errors[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"]
+	}
+
+	ginkgo.It("asks for the query of an unknown hash, then serves the registered hash", func() {
+		gomega.Expect(errorCode(send(hashOnly))).To(gomega.Equal("PERSISTED_QUERY_NOT_FOUND"))
+
+		result := send(withQuery)
+		gomega.Expect(result["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+
+		result = send(hashOnly)
+		gomega.Expect(result["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+	})
+
+	ginkgo.It("refuses to register a query under a hash that does not match it", func() {
+		body := fmt.Sprintf(`{"query": "{ __typename }", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": %q}}}`, hash)
+		gomega.Expect(errorCode(send(body))).To(gomega.Equal("PERSISTED_QUERY_HASH_MISMATCH"))
+	})
+
+	ginkgo.It("only executes allow-listed operations in allowlist mode", func() {
+		path := filepath.Join(ginkgo.GinkgoT().TempDir(), "persisted-queries.json")
+		manifest := fmt.Sprintf(`{"%s": %q}`, hash, query)
+		gomega.Expect(os.WriteFile(path, []byte(manifest), 0o600)).To(gomega.Succeed())
+
+		allowList, err := api.LoadPersistedQueries(path)
+		gomega.Expect(err).To(gomega.BeNil())
+		serve(&api.PersistedQueryStore{Mode: api.PersistedQueriesAllowList, AllowList: allowList})
+
+		result := send(hashOnly)
+		gomega.Expect(result["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+
+		result = send(fmt.Sprintf(`{"query": %q}`, query))
+		gomega.Expect(result["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+
+		gomega.Expect(errorCode(send(`{"query": "{ __typename }"}`))).To(gomega.Equal("PERSISTED_QUERY_REQUIRED"))
+	})
+
+	ginkgo.It("rejects an allow-list whose hashes do not match their queries", func() {
+		path := filepath.Join(ginkgo.GinkgoT().TempDir(), "persisted-queries.json")
+		manifest := `{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": "abc", "body": "{ __typename }"}]}`
+		gomega.Expect(os.WriteFile(path, []byte(manifest), 0o600)).To(gomega.Succeed())
+
+		_, err := api.LoadPersistedQueries(path)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("does not match")))
+	})
+})
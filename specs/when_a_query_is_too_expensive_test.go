@@ -0,0 +1,96 @@
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When a query is too expensive", func() {
+	var handler api.LambdaHandler
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		settings := api.Settings{Limits: api.Limits{MaxDepth: 5, MaxComplexity: 200}}
+		handler, err = api.NewGraphQlApiHandler(settings, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	firstError := func(query string, variables map[string]interface{}) map[string]interface{} {
+		body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       string(body),
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var result struct {
+			Data   interface{}              `json:"data"`
+			Errors []map[string]interface{} `json:"errors"`
+		}
+		gomega.Expect(json.Unmarshal([]byte(response.Body), &result)).To(gomega.Succeed())
+		gomega.Expect(result.Data).To(gomega.BeNil())
+		gomega.Expect(result.Errors).To(gomega.HaveLen(1))
+		return result.Errors[0]
+	}
+
+	ginkgo.It("rejects queries nested deeper than the limit", func() {
+		err := firstError(`{
+			reservations { edges { node { room { reservations { room { ID } } } } } }
+		}`, nil)
+		gomega.Expect(err["extensions"]).To(gomega.Equal(map[string]interface{}{
+			"code":     "QUERY_TOO_DEEP",
+			"depth":    float64(7),
+			"maxDepth": float64(5),
+		}))
+	})
+
+	ginkgo.It("rejects queries whose cost exceeds the limit, sizing pages by first", func() {
+		err := firstError(`query Board($first: Int) {
+			reservations(first: $first) { edges { node { room { ID } } } }
+		}`, map[string]interface{}{"first": 100})
+		extensions := err["extensions"].(map[string]interface{})
+		gomega.Expect(extensions["code"]).To(gomega.Equal("QUERY_TOO_COMPLEX"))
+		gomega.Expect(extensions["complexity"]).To(gomega.Equal(float64(10 + 100*(1+1))))
+		gomega.Expect(extensions["maxComplexity"]).To(gomega.Equal(float64(200)))
+	})
+
+	ginkgo.It("charges the list of every room by its size", func() {
+		err := firstError(`{ rooms { reservations { Id } } }`, nil)
+		extensions := err["extensions"].(map[string]interface{})
+		gomega.Expect(extensions["code"]).To(gomega.Equal("QUERY_TOO_COMPLEX"))
+		gomega.Expect(extensions["complexity"]).To(gomega.Equal(float64(10 + 50*5)))
+	})
+
+	ginkgo.It("has a cost hint for every root field and list field of the schema", func() {
+		schema, err := api.AppSchema(memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(api.FieldsWithoutCost(schema)).To(gomega.BeEmpty(),
+			"add the fields to fieldCosts in api/limits.go")
+	})
+
+	ginkgo.It("does not count introspection against the limits", func() {
+		schema, err := api.AppSchema(memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(schema.QueryType()).NotTo(gomega.BeNil())
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"query": `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`,
+		})
+		response, handlerErr := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       string(body),
+		})
+		gomega.Expect(handlerErr).To(gomega.BeNil())
+		gomega.Expect(response.Body).NotTo(gomega.ContainSubstring("QUERY_TOO"))
+	})
+})
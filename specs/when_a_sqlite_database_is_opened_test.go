@@ -0,0 +1,48 @@
+package specs
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+var _ = ginkgo.Describe("When a SQLite database is opened", func() {
+	var cfg config.Database
+
+	ginkgo.BeforeEach(func() {
+		cfg = config.Database{Driver: config.DriverSQLite, Path: filepath.Join(ginkgo.GinkgoT().TempDir(), "hotel.db")}
+	})
+
+	ginkgo.It("creates the schema in a new file and keeps the data when reopened", func() {
+		backend, err := api.OpenStore(cfg)
+		gomega.Expect(err).To(gomega.BeNil())
+		saveRooms(backend.Repositories(), api.Room{ID: "101", NumBeds: 1, DailyRate: 100, CleaningFee: 10})
+		saveReservations(backend.Repositories(), api.Reservation{RoomID: "101", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05", TotalCharge: 310})
+		gomega.Expect(backend.Close()).To(gomega.Succeed())
+
+		backend, err = api.OpenStore(cfg)
+		gomega.Expect(err).To(gomega.BeNil())
+		defer backend.Close()
+
+		reservations, err := backend.Repositories().Reservations.ListReservations(context.Background(), store.ReservationQuery{})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(reservations).To(gomega.HaveLen(1))
+		gomega.Expect(reservations[0].Status).To(gomega.Equal(api.ReservationConfirmed))
+	})
+
+	ginkgo.It("enforces the room of a reservation", func() {
+		backend, err := api.OpenStore(cfg)
+		gomega.Expect(err).To(gomega.BeNil())
+		defer backend.Close()
+
+		_, err = backend.Repositories().Reservations.CreateReservation(context.Background(),
+			api.Reservation{RoomID: "999", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05"})
+		gomega.Expect(err).To(gomega.MatchError(store.ErrNotFound))
+	})
+})
@@ -0,0 +1,258 @@
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/ratelimit"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+var _ = ginkgo.Describe("When clients are rate limited", func() {
+	const search = `{"query": "{ availableRooms(startDate: \"2030-01-10\", endDate: \"2030-01-12\", numBeds: 1, allowSmoking: false) { ID } }"}`
+
+	var settings api.Settings
+
+	const origin = "https://hotel.example.com"
+
+	// configure limits every client to two searches and one booking at
+	// once, refilled at one a minute, with the buckets in store, and lets
+	// pages from origin open WebSockets.
+	configure := func(store string) {
+		settings = api.Settings{
+			Config:     config.Config{API: config.API{AllowedOrigins: []string{origin}}},
+			RateLimits: config.RateLimit{Store: store, SearchesPerMinute: 1, SearchBurst: 2, BookingsPerMinute: 1, BookingBurst: 1},
+		}
+	}
+
+	newHandler := func(repos store.Repositories) http.Handler {
+		server, err := api.NewServer(settings, repos)
+		gomega.Expect(err).To(gomega.BeNil())
+		return server.Handler
+	}
+
+	send := func(handler http.Handler, address string, header http.Header, method string, path string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.RemoteAddr = address + ":41000"
+		request.Header.Set("Content-Type", "application/json")
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// errorCode is the code of the first GraphQL error of response.
+	errorCode := func(response *httptest.ResponseRecorder) interface{} {
+		var result struct {
+			Errors []struct {
+				Extensions map[string]interface{} `json:"extensions"`
+			} `json:"errors"`
+		}
+		gomega.Expect(json.Unmarshal(response.Body.Bytes(), &result)).To(gomega.Succeed())
+		if len(result.Errors) == 0 {
+			return nil
+		}
+		return result.Errors[0].Extensions["code"]
+	}
+
+	withEachStore(func(repositories func() store.Repositories) {
+		var handler http.Handler
+
+		ginkgo.BeforeEach(func() {
+			configure(config.RateLimitDatabase)
+			repos := repositories()
+			saveRooms(repos, api.Room{ID: "101", NumBeds: 2, DailyRate: 100, CleaningFee: 10})
+			handler = newHandler(repos)
+		})
+
+		ginkgo.It("refuses searches beyond the burst with RATE_LIMITED and reports the limits", func() {
+			response := send(handler, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			gomega.Expect(errorCode(response)).To(gomega.BeNil())
+			gomega.Expect(response.Header().Get("RateLimit-Limit")).To(gomega.Equal("2"))
+			gomega.Expect(response.Header().Get("RateLimit-Remaining")).To(gomega.Equal("1"))
+			gomega.Expect(response.Header().Get("RateLimit-Reset")).To(gomega.Equal("60"))
+
+			response = send(handler, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			gomega.Expect(errorCode(response)).To(gomega.BeNil())
+			gomega.Expect(response.Header().Get("RateLimit-Remaining")).To(gomega.Equal("0"))
+			gomega.Expect(response.Header().Get("Retry-After")).To(gomega.BeEmpty())
+
+			response = send(handler, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(errorCode(response)).To(gomega.Equal("RATE_LIMITED"))
+			gomega.Expect(response.Header().Get("RateLimit-Remaining")).To(gomega.Equal("0"))
+			gomega.Expect(response.Header().Get("Retry-After")).To(gomega.Equal("60"))
+
+			response = send(handler, "192.0.2.1", nil, http.MethodGet, "/rooms/available?startDate=2030-01-10&endDate=2030-01-12", "")
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusTooManyRequests))
+			gomega.Expect(response.Body.String()).To(gomega.ContainSubstring(`"code":"RATE_LIMITED"`))
+			gomega.Expect(response.Header().Get("Retry-After")).To(gomega.Equal("60"))
+
+			response = send(handler, "198.51.100.7", nil, http.MethodPost, "/api", search)
+			gomega.Expect(errorCode(response)).To(gomega.BeNil())
+		})
+
+		ginkgo.It("keeps a separate budget for bookings", func() {
+			for i := 0; i < 3; i++ {
+				send(handler, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			}
+
+			booking := `{"roomId": "101", "checkinDate": "2030-01-10", "checkoutDate": "2030-01-12", "totalCharge": 210}`
+			response := send(handler, "192.0.2.1", nil, http.MethodPost, "/reservations", booking)
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusCreated))
+			gomega.Expect(response.Header().Get("RateLimit-Limit")).To(gomega.Equal("1"))
+
+			response = send(handler, "192.0.2.1", nil, http.MethodPost, "/reservations",
+				strings.Replace(booking, "2030-01-1", "2030-02-1", 2))
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusTooManyRequests))
+			gomega.Expect(response.Header().Get("Retry-After")).To(gomega.Equal("60"))
+		})
+
+		ginkgo.It("limits the operations sent over a WebSocket", func() {
+			running := serveWebSockets(handler)
+			dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+			conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(running.URL, "http")+"/api", http.Header{"Origin": {origin}})
+			gomega.Expect(err).To(gomega.BeNil())
+			defer conn.Close()
+
+			var message struct {
+				ID      string          `json:"id"`
+				Type    string          `json:"type"`
+				Payload json.RawMessage `json:"payload"`
+			}
+			gomega.Expect(conn.WriteJSON(map[string]interface{}{"type": "connection_init"})).To(gomega.Succeed())
+			gomega.Expect(conn.ReadJSON(&message)).To(gomega.Succeed())
+			gomega.Expect(message.Type).To(gomega.Equal("connection_ack"))
+
+			book := func(id string, checkin string, checkout string) {
+				gomega.Expect(conn.WriteJSON(map[string]interface{}{"id": id, "type": "subscribe", "payload": map[string]string{
+					"query": `mutation { createReservation(input: {RoomID: "101", CheckinDate: "` + checkin + `", CheckoutDate: "` + checkout + `"}) { Id } }`,
+				}})).To(gomega.Succeed())
+			}
+
+			book("1", "2030-01-10", "2030-01-12")
+			gomega.Expect(conn.ReadJSON(&message)).To(gomega.Succeed())
+			gomega.Expect(message.ID).To(gomega.Equal("1"))
+			gomega.Expect(message.Type).To(gomega.Equal("next"))
+			gomega.Expect(string(message.Payload)).NotTo(gomega.ContainSubstring("errors"))
+			gomega.Expect(conn.ReadJSON(&message)).To(gomega.Succeed())
+			gomega.Expect(message.Type).To(gomega.Equal("complete"))
+
+			book("2", "2030-02-10", "2030-02-12")
+			gomega.Expect(conn.ReadJSON(&message)).To(gomega.Succeed())
+			gomega.Expect(message.ID).To(gomega.Equal("2"))
+			gomega.Expect(message.Type).To(gomega.Equal("error"))
+			gomega.Expect(string(message.Payload)).To(gomega.ContainSubstring(`"code":"RATE_LIMITED"`))
+		})
+	})
+
+	ginkgo.Context("with authenticated callers", func() {
+		const secret = "a-shared-secret-of-at-least-32-bytes"
+
+		token := func(subject string) http.Header {
+			signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"sub": subject, "exp": time.Now().Add(time.Hour).Unix(),
+			}).SignedString([]byte(secret))
+			gomega.Expect(err).To(gomega.BeNil())
+			return http.Header{"Authorization": {"Bearer " + signed}}
+		}
+
+		ginkgo.It("gives every caller its own budget, wherever it calls from", func() {
+			configure(config.RateLimitMemory)
+			authenticator, err := auth.New(config.Auth{JWTSecret: secret})
+			gomega.Expect(err).To(gomega.BeNil())
+			settings.Authenticator = authenticator
+			db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+			gomega.Expect(err).To(gomega.BeNil())
+			ginkgo.DeferCleanup(db.Close)
+			handler := newHandler(db.Repositories())
+
+			for _, address := range []string{"192.0.2.1", "192.0.2.2"} {
+				gomega.Expect(errorCode(send(handler, address, token("aggregator"), http.MethodPost, "/api", search))).To(gomega.BeNil())
+			}
+			gomega.Expect(errorCode(send(handler, "192.0.2.3", token("aggregator"), http.MethodPost, "/api", search))).To(gomega.Equal("RATE_LIMITED"))
+			gomega.Expect(errorCode(send(handler, "192.0.2.3", token("guest-42"), http.MethodPost, "/api", search))).To(gomega.BeNil())
+			gomega.Expect(errorCode(send(handler, "192.0.2.3", nil, http.MethodPost, "/api", search))).To(gomega.BeNil())
+		})
+	})
+
+	ginkgo.Context("with several instances", func() {
+		var repos store.Repositories
+
+		ginkgo.BeforeEach(func() {
+			db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+			gomega.Expect(err).To(gomega.BeNil())
+			ginkgo.DeferCleanup(db.Close)
+			repos = db.Repositories()
+		})
+
+		searchTwice := func(first http.Handler, second http.Handler) interface{} {
+			send(first, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			send(first, "192.0.2.1", nil, http.MethodPost, "/api", search)
+			return errorCode(send(second, "192.0.2.1", nil, http.MethodPost, "/api", search))
+		}
+
+		ginkgo.It("shares the buckets through the database", func() {
+			configure(config.RateLimitDatabase)
+			gomega.Expect(searchTwice(newHandler(repos), newHandler(repos))).To(gomega.Equal("RATE_LIMITED"))
+		})
+
+		ginkgo.It("limits each instance on its own in memory", func() {
+			configure(config.RateLimitMemory)
+			gomega.Expect(searchTwice(newHandler(repos), newHandler(repos))).To(gomega.BeNil())
+		})
+	})
+
+	ginkgo.It("refills the buckets over time", func() {
+		buckets := ratelimit.NewMemory()
+		start := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+		take := func(at time.Duration) (float64, bool) {
+			tokens, taken, err := buckets.TakeToken(context.Background(), "searches:ip:192.0.2.1", 0.5, 2, start.Add(at))
+			gomega.Expect(err).To(gomega.BeNil())
+			return tokens, taken
+		}
+
+		tokens, taken := take(0)
+		gomega.Expect(taken).To(gomega.BeTrue())
+		gomega.Expect(tokens).To(gomega.Equal(1.0))
+		_, taken = take(0)
+		gomega.Expect(taken).To(gomega.BeTrue())
+		_, taken = take(time.Second)
+		gomega.Expect(taken).To(gomega.BeFalse())
+		_, taken = take(2 * time.Second)
+		gomega.Expect(taken).To(gomega.BeTrue())
+		tokens, _ = take(time.Hour)
+		gomega.Expect(tokens).To(gomega.Equal(1.0))
+	})
+
+	ginkgo.It("forgets only the buckets left alone", func() {
+		buckets := ratelimit.NewMemory()
+		now := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+		take := func() bool {
+			_, taken, err := buckets.TakeToken(context.Background(), "bookings:ip:192.0.2.1", 0.01, 1, now)
+			gomega.Expect(err).To(gomega.BeNil())
+			return taken
+		}
+
+		gomega.Expect(take()).To(gomega.BeTrue())
+		gomega.Expect(buckets.SweepBuckets(context.Background(), now.Add(-time.Second))).To(gomega.Succeed())
+		gomega.Expect(take()).To(gomega.BeFalse())
+		gomega.Expect(buckets.SweepBuckets(context.Background(), now.Add(time.Second))).To(gomega.Succeed())
+		gomega.Expect(take()).To(gomega.BeTrue())
+	})
+})
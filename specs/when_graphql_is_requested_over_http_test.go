@@ -0,0 +1,107 @@
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When GraphQL is requested over HTTP", func() {
+	var handler api.LambdaHandler
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		handler, err = api.NewGraphQlApiHandler(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	send := func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, map[string]interface{}) {
+		response, err := handler(context.Background(), request)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var body map[string]interface{}
+		gomega.Expect(json.Unmarshal([]byte(response.Body), &body)).To(gomega.Succeed())
+		return response, body
+	}
+
+	ginkgo.It("executes a JSON POST body", func() {
+		response, body := send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"content-type": "application/json"},
+			Body:       `{"query": "query Kind { __typename }"}`,
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(response.Headers["Content-Type"]).To(gomega.HavePrefix("application/json"))
+		gomega.Expect(body["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+	})
+
+	ginkgo.It("selects the operation named by operationName", func() {
+		_, body := send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       `{"query": "query A { a: __typename } query B { b: __typename }", "operationName": "B"}`,
+		})
+		gomega.Expect(body["data"]).To(gomega.Equal(map[string]interface{}{"b": "RootQuery"}))
+	})
+
+	ginkgo.It("executes a GET with query and variables parameters", func() {
+		response, body := send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodGet,
+			QueryStringParameters: map[string]string{
+				"query":     "query Kind($skip: Boolean!) { __typename @skip(if: $skip) }",
+				"variables": `{"skip": false}`,
+			},
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(body["data"]).To(gomega.Equal(map[string]interface{}{"__typename": "RootQuery"}))
+	})
+
+	ginkgo.It("refuses mutations sent with GET", func() {
+		response, _ := send(events.APIGatewayProxyRequest{
+			HTTPMethod:            http.MethodGet,
+			QueryStringParameters: map[string]string{"query": "mutation { __typename }"},
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusMethodNotAllowed))
+		gomega.Expect(response.Headers["Allow"]).To(gomega.Equal("POST"))
+	})
+
+	ginkgo.It("rejects unsupported methods, media types and malformed bodies", func() {
+		response, _ := send(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPut})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusMethodNotAllowed))
+
+		response, _ = send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "text/plain"},
+			Body:       "{ __typename }",
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusUnsupportedMediaType))
+
+		response, _ = send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       "{ __typename }",
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+	})
+
+	ginkgo.It("reports invalid documents as bad requests to graphql-response+json clients", func() {
+		response, body := send(events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+				"Accept":       "application/graphql-response+json",
+			},
+			Body: `{"query": "{ noSuchField }"}`,
+		})
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		gomega.Expect(response.Headers["Content-Type"]).To(gomega.HavePrefix("application/graphql-response+json"))
+		gomega.Expect(body["errors"]).NotTo(gomega.BeEmpty())
+	})
+})
@@ -0,0 +1,135 @@
+package specs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/client"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+var _ = ginkgo.Describe("When metrics are scraped", func() {
+	var running *httptest.Server
+	var c *client.Client
+	ctx := context.Background()
+
+	ginkgo.BeforeEach(func() {
+		db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+		loadFixtures(db.Repositories(), "test")
+
+		server, err := api.NewServer(api.Settings{}, db.Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		running = httptest.NewServer(server.Handler)
+		ginkgo.DeferCleanup(running.Close)
+		c = client.New(client.Remote(running.URL+"/api", running.Client()))
+	})
+
+	scrape := func() map[string]*dto.MetricFamily {
+		response, err := http.Get(running.URL + "/metrics")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+		var parser expfmt.TextParser
+		families, err := parser.TextToMetricFamilies(response.Body)
+		gomega.Expect(err).To(gomega.BeNil())
+		return families
+	}
+
+	// value is the value, or the sample count of a histogram, of the series
+	// of name with labels; metrics are shared by every spec, so the specs
+	// compare values before and after.
+	value := func(name string, labels ...string) float64 {
+		family, ok := scrape()[name]
+		if !ok {
+			return 0
+		}
+	series:
+		for _, metric := range family.Metric {
+			for i := 0; i < len(labels); i += 2 {
+				found := false
+				for _, label := range metric.Label {
+					found = found || (label.GetName() == labels[i] && label.GetValue() == labels[i+1])
+				}
+				if !found {
+					continue series
+				}
+			}
+			switch {
+			case metric.Counter != nil:
+				return metric.Counter.GetValue()
+			case metric.Gauge != nil:
+				return metric.Gauge.GetValue()
+			case metric.Histogram != nil:
+				return float64(metric.Histogram.GetSampleCount())
+			}
+		}
+		return 0
+	}
+
+	ginkgo.It("counts bookings, cancellations, conflicts and fruitless searches", func() {
+		created := value("hotel_reservations_created_total")
+		cancelled := value("hotel_reservations_cancelled_total")
+		conflicts := value("hotel_booking_conflicts_total")
+		empty := value("hotel_availability_searches_total", "result", "empty")
+		found := value("hotel_availability_searches_total", "result", "found")
+
+		rooms, err := c.AvailableRooms(ctx, "2023-03-10", "2023-03-12", 9, false)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(rooms).To(gomega.BeEmpty())
+		_, err = c.AvailableRooms(ctx, "2023-03-10", "2023-03-12", 1, false)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		reservation, err := c.CreateReservation(ctx, "103", "2023-03-10", "2023-03-12", 315)
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = c.CreateReservation(ctx, "103", "2023-03-11", "2023-03-13", 315)
+		gomega.Expect(err).To(gomega.MatchError(api.ErrConflict))
+		_, err = c.CancelReservation(ctx, reservation.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = c.CancelReservation(ctx, reservation.ID)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		gomega.Expect(value("hotel_reservations_created_total")).To(gomega.Equal(created + 1))
+		gomega.Expect(value("hotel_reservations_cancelled_total")).To(gomega.Equal(cancelled + 1))
+		gomega.Expect(value("hotel_booking_conflicts_total")).To(gomega.Equal(conflicts + 1))
+		gomega.Expect(value("hotel_availability_searches_total", "result", "empty")).To(gomega.Equal(empty + 1))
+		gomega.Expect(value("hotel_availability_searches_total", "result", "found")).To(gomega.Equal(found + 1))
+	})
+
+	ginkgo.It("counts GraphQL operations by name and outcome and times their resolvers", func() {
+		post := func(query string) {
+			response, err := http.Post(running.URL+"/api", "application/json", strings.NewReader(query))
+			gomega.Expect(err).To(gomega.BeNil())
+			response.Body.Close()
+		}
+		succeeded := value("hotel_graphql_operations_total", "operation", "ListRooms", "type", "query", "outcome", "success")
+		rejected := value("hotel_graphql_operations_total", "operation", "ListRooms", "type", "query", "outcome", "rejected")
+		timed := value("hotel_graphql_operation_duration_seconds", "operation", "ListRooms")
+		resolved := value("hotel_graphql_resolver_duration_seconds", "field", "RootQuery.rooms")
+
+		post(`{"query": "query ListRooms { rooms { ID } }"}`)
+		post(`{"query": "query ListRooms { rooms { unknown } }"}`)
+
+		gomega.Expect(value("hotel_graphql_operations_total", "operation", "ListRooms", "type", "query", "outcome", "success")).To(gomega.Equal(succeeded + 1))
+		gomega.Expect(value("hotel_graphql_operations_total", "operation", "ListRooms", "type", "query", "outcome", "rejected")).To(gomega.Equal(rejected + 1))
+		gomega.Expect(value("hotel_graphql_operation_duration_seconds", "operation", "ListRooms")).To(gomega.Equal(timed + 2))
+		gomega.Expect(value("hotel_graphql_resolver_duration_seconds", "field", "RootQuery.rooms")).To(gomega.Equal(resolved + 1))
+	})
+
+	ginkgo.It("publishes the statistics of the connection pool", func() {
+		families := scrape()
+		gomega.Expect(families).To(gomega.HaveKey("go_sql_max_open_connections"))
+		gomega.Expect(value("go_sql_max_open_connections", "db_name", "hotel")).To(gomega.Equal(1.0))
+		gomega.Expect(families).To(gomega.HaveKey("go_sql_in_use_connections"))
+		gomega.Expect(families).To(gomega.HaveKey("go_sql_wait_count_total"))
+	})
+})
@@ -0,0 +1,123 @@
+package specs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// countingRooms records the ids of every Rooms call.
+type countingRooms struct {
+	store.RoomRepository
+
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (c *countingRooms) Rooms(ctx context.Context, ids []string) ([]store.Room, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, ids)
+	c.mu.Unlock()
+	return c.RoomRepository.Rooms(ctx, ids)
+}
+
+// countingReservations records the room ids of every ListReservations call.
+type countingReservations struct {
+	store.ReservationRepository
+
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (c *countingReservations) ListReservations(ctx context.Context, query store.ReservationQuery) ([]store.Reservation, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, query.RoomIDs)
+	c.mu.Unlock()
+	return c.ReservationRepository.ListReservations(ctx, query)
+}
+
+var _ = ginkgo.Describe("When related rooms and reservations are requested", func() {
+	withEachStore(func(repositories func() store.Repositories) {
+		var schema graphql.Schema
+		var rooms *countingRooms
+		var reservations *countingReservations
+
+		ginkgo.BeforeEach(func() {
+			repos := repositories()
+			rooms = &countingRooms{RoomRepository: repos.Rooms}
+			reservations = &countingReservations{ReservationRepository: repos.Reservations}
+
+			var err error
+			schema, err = api.AppSchema(store.Repositories{Rooms: rooms, Reservations: reservations})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			saveRooms(repos,
+				api.Room{ID: "101", NumBeds: 1, AllowSmoking: false, DailyRate: 100.0, CleaningFee: 10.0},
+				api.Room{ID: "102", NumBeds: 1, AllowSmoking: false, DailyRate: 120.0, CleaningFee: 10.0},
+			)
+
+			saveReservations(repos,
+				api.Reservation{RoomID: "101", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05", TotalCharge: 310.0},
+				api.Reservation{RoomID: "101", CheckinDate: "2023-03-10", CheckoutDate: "2023-03-12", TotalCharge: 210.0},
+				api.Reservation{RoomID: "102", CheckinDate: "2023-03-05", CheckoutDate: "2023-03-08", TotalCharge: 370.0},
+			)
+		})
+
+		query := func(request string) map[string]interface{} {
+			result := graphql.Do(graphql.Params{Schema: schema, RequestString: request, Context: context.Background()})
+			gomega.Expect(result.Errors).To(gomega.BeEmpty())
+			return result.Data.(map[string]interface{})
+		}
+
+		ginkgo.It("resolves the room of each reservation", func() {
+			data := query(`{
+			reservations(orderBy: {field: CHECKIN_DATE}) {
+				edges { node { RoomId room { ID DailyRate } } }
+			}
+		}`)
+
+			edges := data["reservations"].(map[string]interface{})["edges"].([]interface{})
+			gomega.Expect(edges).To(gomega.HaveLen(3))
+			for _, edge := range edges {
+				node := edge.(map[string]interface{})["node"].(map[string]interface{})
+				room := node["room"].(map[string]interface{})
+				gomega.Expect(room["ID"]).To(gomega.Equal(node["RoomId"]))
+			}
+		})
+
+		ginkgo.It("resolves the reservations of a room within a date range", func() {
+			data := query(`{
+			reservations(filter: {roomIds: ["101"]}, first: 1) {
+				edges { node { room { ID reservations(dateRange: {start: "2023-03-09", end: "2023-03-20"}) { CheckinDate } } } }
+			}
+		}`)
+
+			edges := data["reservations"].(map[string]interface{})["edges"].([]interface{})
+			room := edges[0].(map[string]interface{})["node"].(map[string]interface{})["room"].(map[string]interface{})
+			gomega.Expect(room["reservations"]).To(gomega.Equal([]interface{}{
+				map[string]interface{}{"CheckinDate": "2023-03-10"},
+			}))
+		})
+
+		ginkgo.It("loads the rooms of every reservation with a single call", func() {
+			query(`{ reservations { edges { node { room { ID } } } } }`)
+
+			gomega.Expect(rooms.calls).To(gomega.HaveLen(1))
+			gomega.Expect(rooms.calls[0]).To(gomega.ConsistOf("101", "102"))
+		})
+
+		ginkgo.It("loads the reservations of every room with a single call", func() {
+			data := query(`{ rooms { ID reservations { CheckinDate } } }`)
+			gomega.Expect(data["rooms"]).To(gomega.HaveLen(2))
+
+			gomega.Expect(reservations.calls).To(gomega.HaveLen(1))
+			gomega.Expect(reservations.calls[0]).To(gomega.ConsistOf("101", "102"))
+		})
+	})
+})
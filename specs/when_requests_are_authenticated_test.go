@@ -0,0 +1,251 @@
+package specs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+// principalRooms records who listed the rooms, as the resolvers see it.
+type principalRooms struct {
+	store.RoomRepository
+	principals *[]*auth.Principal
+}
+
+func (r principalRooms) ListRooms(ctx context.Context) ([]store.Room, error) {
+	*r.principals = append(*r.principals, auth.FromContext(ctx))
+	return r.RoomRepository.ListRooms(ctx)
+}
+
+var _ = ginkgo.Describe("When requests are authenticated", func() {
+	const secret = "a-shared-secret-of-at-least-32-bytes"
+	const query = `{"query": "{ rooms { ID } }"}`
+	const origin = "https://hotel.example.com"
+	var signingKey *rsa.PrivateKey
+	var partnerKey string
+	var principals []*auth.Principal
+	var repos store.Repositories
+	var settings api.Settings
+	var handler http.Handler
+
+	// configure serves repos with the authenticator of cfg, with the JWKS of
+	// signingKey and the API key of the partner acme-travel, to pages from
+	// origin.
+	configure := func(cfg config.Auth) {
+		dir := ginkgo.GinkgoT().TempDir()
+		cfg.JWKSFile = filepath.Join(dir, "jwks.json")
+		cfg.APIKeysFile = filepath.Join(dir, "api-keys.json")
+
+		encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+		jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "2023-03", "use": "sig",
+			"n": encode(signingKey.N), "e": encode(big.NewInt(int64(signingKey.E))),
+		}}})
+		gomega.Expect(os.WriteFile(cfg.JWKSFile, jwks, 0o600)).To(gomega.Succeed())
+
+		key, entry, err := auth.NewAPIKey("acme-travel")
+		gomega.Expect(err).To(gomega.BeNil())
+		partnerKey = key
+		keys, _ := json.Marshal([]auth.APIKey{entry})
+		gomega.Expect(os.WriteFile(cfg.APIKeysFile, keys, 0o600)).To(gomega.Succeed())
+
+		authenticator, err := auth.New(cfg)
+		gomega.Expect(err).To(gomega.BeNil())
+		settings = api.Settings{
+			Config:        config.Config{API: config.API{AllowedOrigins: []string{origin}}},
+			Authenticator: authenticator,
+		}
+		server, err := api.NewServer(settings, repos)
+		gomega.Expect(err).To(gomega.BeNil())
+		handler = server.Handler
+	}
+
+	sign := func(method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		gomega.Expect(err).To(gomega.BeNil())
+		return signed
+	}
+
+	claims := func(subject string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": subject, "iss": "https://id.example.com", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+
+	post := func(path string, header http.Header) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(query))
+		request.Header.Set("Content-Type", "application/json")
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		principals = nil
+		repos = memory.New().Repositories()
+		repos.Rooms = principalRooms{repos.Rooms, &principals}
+	})
+
+	ginkgo.Context("with authentication required", func() {
+		ginkgo.BeforeEach(func() {
+			configure(config.Auth{Required: true, JWTSecret: secret, Issuer: "https://id.example.com"})
+		})
+
+		ginkgo.It("refuses requests without credentials", func() {
+			response := post("/api", nil)
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusUnauthorized))
+			gomega.Expect(response.Header().Get("WWW-Authenticate")).To(gomega.HavePrefix("Bearer"))
+			gomega.Expect(response.Body.String()).To(gomega.ContainSubstring(`"code":"UNAUTHENTICATED"`))
+			gomega.Expect(principals).To(gomega.BeEmpty())
+
+			response = post("/reservations", nil)
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusUnauthorized))
+			gomega.Expect(response.Body.String()).To(gomega.ContainSubstring(`"code":"UNAUTHENTICATED"`))
+		})
+
+		ginkgo.It("passes the subject of a JWT signed with the shared secret to the resolvers", func() {
+			token := sign(jwt.SigningMethodHS256, []byte(secret), "", claims("guest-42"))
+			response := post("/api", http.Header{"Authorization": {"Bearer " + token}})
+			gomega.Expect(response.Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(principals).To(gomega.ConsistOf(&auth.Principal{Subject: "guest-42", Method: auth.MethodJWT, Roles: []string{auth.RoleGuest}}))
+		})
+
+		ginkgo.It("verifies JWTs with the key of the JWKS named by their kid", func() {
+			token := sign(jwt.SigningMethodRS256, signingKey, "2023-03", claims("guest-43"))
+			gomega.Expect(post("/api", http.Header{"Authorization": {"Bearer " + token}}).Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(principals).To(gomega.ConsistOf(&auth.Principal{Subject: "guest-43", Method: auth.MethodJWT, Roles: []string{auth.RoleGuest}}))
+
+			token = sign(jwt.SigningMethodRS256, signingKey, "2022-11", claims("guest-43"))
+			gomega.Expect(post("/api", http.Header{"Authorization": {"Bearer " + token}}).Code).To(gomega.Equal(http.StatusUnauthorized))
+		})
+
+		ginkgo.It("refuses expired, foreign and unsigned JWTs", func() {
+			expired := claims("guest-42")
+			expired["exp"] = time.Now().Add(-time.Hour).Unix()
+			foreign := claims("guest-42")
+			foreign["iss"] = "https://elsewhere.example.com"
+			tokens := []string{
+				sign(jwt.SigningMethodHS256, []byte(secret), "", expired),
+				sign(jwt.SigningMethodHS256, []byte(secret), "", foreign),
+				sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims("guest-42")),
+				sign(jwt.SigningMethodHS256, []byte("another-secret-of-at-least-32-bytes"), "", claims("guest-42")),
+			}
+			for _, token := range tokens {
+				response := post("/api", http.Header{"Authorization": {"Bearer " + token}})
+				gomega.Expect(response.Code).To(gomega.Equal(http.StatusUnauthorized), token)
+			}
+			gomega.Expect(principals).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("authenticates partners by their API key", func() {
+			gomega.Expect(post("/api", http.Header{"X-Api-Key": {partnerKey}}).Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(principals).To(gomega.ConsistOf(&auth.Principal{Subject: "acme-travel", Method: auth.MethodAPIKey, Roles: []string{auth.RoleGuest}}))
+			gomega.Expect(post("/api", http.Header{"X-Api-Key": {partnerKey + "x"}}).Code).To(gomega.Equal(http.StatusUnauthorized))
+		})
+
+		ginkgo.It("serves the probes and the API description to anyone", func() {
+			for _, path := range []string{"/livez", "/openapi.yaml"} {
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+				gomega.Expect(response.Code).To(gomega.Equal(http.StatusOK), path)
+			}
+		})
+
+		ginkgo.It("authenticates Lambda invocations", func() {
+			lambda, err := api.NewGraphQlApiHandler(settings, memory.New().Repositories())
+			gomega.Expect(err).To(gomega.BeNil())
+			invoke := func(headers map[string]string) int {
+				response, err := lambda(context.Background(), events.APIGatewayProxyRequest{
+					HTTPMethod: http.MethodPost, Path: "/api", Headers: headers, Body: query,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				return response.StatusCode
+			}
+			gomega.Expect(invoke(nil)).To(gomega.Equal(http.StatusUnauthorized))
+			gomega.Expect(invoke(map[string]string{"x-api-key": partnerKey})).To(gomega.Equal(http.StatusOK))
+		})
+
+		ginkgo.It("authenticates subscriptions with the connection_init payload", func() {
+			running := serveWebSockets(handler)
+			dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+			url := "ws" + strings.TrimPrefix(running.URL, "http") + "/api"
+
+			header := http.Header{"Origin": {origin}}
+
+			refused, _, err := dialer.Dial(url, header)
+			gomega.Expect(err).To(gomega.BeNil())
+			defer refused.Close()
+			gomega.Expect(refused.WriteJSON(map[string]interface{}{"type": "connection_init"})).To(gomega.Succeed())
+			_, _, err = refused.ReadMessage()
+			gomega.Expect(websocket.IsCloseError(err, 4403)).To(gomega.BeTrue(), "%v", err)
+
+			accepted, _, err := dialer.Dial(url, header)
+			gomega.Expect(err).To(gomega.BeNil())
+			defer accepted.Close()
+			gomega.Expect(accepted.WriteJSON(map[string]interface{}{
+				"type": "connection_init", "payload": map[string]string{"apiKey": "ignored", "X-API-Key": partnerKey},
+			})).To(gomega.Succeed())
+			var ack map[string]interface{}
+			gomega.Expect(accepted.ReadJSON(&ack)).To(gomega.Succeed())
+			gomega.Expect(ack).To(gomega.HaveKeyWithValue("type", "connection_ack"))
+		})
+	})
+
+	ginkgo.Context("with authentication optional", func() {
+		ginkgo.BeforeEach(func() {
+			configure(config.Auth{JWTSecret: secret})
+		})
+
+		ginkgo.It("serves anonymous callers but still refuses invalid credentials", func() {
+			gomega.Expect(post("/api", nil).Code).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(principals).To(gomega.ConsistOf(gomega.BeNil()))
+			gomega.Expect(post("/api", http.Header{"Authorization": {"Bearer not-a-jwt"}}).Code).To(gomega.Equal(http.StatusUnauthorized))
+		})
+
+		ginkgo.It("only opens WebSockets for pages from the allowed origins", func() {
+			running := serveWebSockets(handler)
+			dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+			url := "ws" + strings.TrimPrefix(running.URL, "http") + "/api"
+
+			for _, header := range []http.Header{nil, {"Origin": {"https://attacker.example.com"}}} {
+				_, response, err := dialer.Dial(url, header)
+				gomega.Expect(err).To(gomega.Equal(websocket.ErrBadHandshake))
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusForbidden))
+			}
+
+			accepted, _, err := dialer.Dial(url, http.Header{"Origin": {origin}})
+			gomega.Expect(err).To(gomega.BeNil())
+			accepted.Close()
+		})
+	})
+})
@@ -0,0 +1,259 @@
+package specs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/auth"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When requests are authorized", func() {
+	const secret = "a-shared-secret-of-at-least-32-bytes"
+	var handler http.Handler
+
+	// token returns the authorization header of subject with roles.
+	token := func(subject string, roles ...string) http.Header {
+		claims := jwt.MapClaims{"sub": subject, "exp": time.Now().Add(time.Hour).Unix()}
+		if len(roles) > 0 {
+			claims["roles"] = roles
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		gomega.Expect(err).To(gomega.BeNil())
+		return http.Header{"Authorization": {"Bearer " + signed}}
+	}
+
+	send := func(method string, path string, header http.Header, body string) (int, map[string]interface{}) {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		var decoded map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &decoded)
+		return response.Code, decoded
+	}
+
+	// graphql runs query as the caller of header, returning its data and the
+	// code of its first error.
+	graphql := func(header http.Header, query string) (map[string]interface{}, interface{}) {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		_, result := send(http.MethodPost, "/api", header, string(body))
+		data, _ := result["data"].(map[string]interface{})
+		if errors, ok := result["errors"].([]interface{}); ok && len(errors) > 0 {
+			extensions, _ := errors[0].(map[string]interface{})["extensions"].(map[string]interface{})
+			return data, extensions["code"]
+		}
+		return data, nil
+	}
+
+	reserve := func(header http.Header, checkin string, checkout string) string {
+		data, code := graphql(header, `mutation { createReservation(input: {RoomID: "101", CheckinDate: "`+checkin+
+			`", CheckoutDate: "`+checkout+`"}) { Id } }`)
+		gomega.Expect(code).To(gomega.BeNil(), "%v", data)
+		return data["createReservation"].(map[string]interface{})["Id"].(string)
+	}
+
+	reservationIDs := func(header http.Header) []string {
+		data, code := graphql(header, `{ reservations { edges { node { Id } } } }`)
+		gomega.Expect(code).To(gomega.BeNil())
+		var ids []string
+		for _, edge := range data["reservations"].(map[string]interface{})["edges"].([]interface{}) {
+			ids = append(ids, edge.(map[string]interface{})["node"].(map[string]interface{})["Id"].(string))
+		}
+		return ids
+	}
+
+	withEachStore(func(repositories func() store.Repositories) {
+		var alice, bob http.Header
+		var alicesID, bobsID string
+
+		ginkgo.BeforeEach(func() {
+			authenticator, err := auth.New(config.Auth{Required: true, JWTSecret: secret})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			repos := repositories()
+			saveRooms(repos, api.Room{ID: "101", NumBeds: 2, DailyRate: 100, CleaningFee: 10})
+			server, err := api.NewServer(api.Settings{Authenticator: authenticator}, repos)
+			gomega.Expect(err).To(gomega.BeNil())
+			handler = server.Handler
+
+			alice, bob = token("alice"), token("bob", auth.RoleGuest)
+			alicesID = reserve(alice, "2030-01-10", "2030-01-12")
+			bobsID = reserve(bob, "2030-01-12", "2030-01-14")
+		})
+
+		ginkgo.It("shows guests only their own reservations", func() {
+			gomega.Expect(reservationIDs(alice)).To(gomega.ConsistOf(alicesID))
+			gomega.Expect(reservationIDs(bob)).To(gomega.ConsistOf(bobsID))
+
+			data, code := graphql(alice, `{ reservation(id: "`+alicesID+`") { GuestId } other: reservation(id: "`+bobsID+`") { Id } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["reservation"]).To(gomega.Equal(map[string]interface{}{"GuestId": "jwt:alice"}))
+			gomega.Expect(data["other"]).To(gomega.BeNil())
+
+			data, _ = graphql(alice, `{ rooms { reservations { Id } } }`)
+			gomega.Expect(data["rooms"]).To(gomega.Equal([]interface{}{
+				map[string]interface{}{"reservations": []interface{}{map[string]interface{}{"Id": alicesID}}},
+			}))
+
+			status, body := send(http.MethodGet, "/reservations/"+bobsID, alice, "")
+			gomega.Expect(status).To(gomega.Equal(http.StatusNotFound))
+			gomega.Expect(body["error"]).To(gomega.HaveKeyWithValue("code", "NOT_FOUND"))
+			status, body = send(http.MethodGet, "/reservations", alice, "")
+			gomega.Expect(status).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(body["totalCount"]).To(gomega.Equal(float64(1)))
+		})
+
+		ginkgo.It("lets guests change and cancel only their own reservations", func() {
+			_, code := graphql(alice, `mutation { cancelReservation(id: "`+bobsID+`") { Id } }`)
+			gomega.Expect(code).To(gomega.Equal("NOT_FOUND"))
+			_, code = graphql(alice, `mutation { updateReservation(id: "`+bobsID+`", input: {TotalCharge: 1}) { Id } }`)
+			gomega.Expect(code).To(gomega.Equal("NOT_FOUND"))
+
+			data, code := graphql(alice, `mutation { cancelReservation(id: "`+alicesID+`") { Status } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["cancelReservation"]).To(gomega.Equal(map[string]interface{}{"Status": "CANCELLED"}))
+		})
+
+		ginkgo.It("charges guests the price of the stay and refuses them any other", func() {
+			data, code := graphql(alice, `{ reservation(id: "`+alicesID+`") { TotalCharge } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["reservation"]).To(gomega.Equal(map[string]interface{}{"TotalCharge": float64(210)}))
+
+			for _, charge := range []string{"0", "210"} {
+				_, code = graphql(alice, `mutation { createReservation(input: {RoomID: "101", CheckinDate: "2030-02-01", CheckoutDate: "2030-02-03", TotalCharge: `+charge+`}) { Id } }`)
+				gomega.Expect(code).To(gomega.Equal("FORBIDDEN"))
+			}
+			_, code = graphql(alice, `mutation { updateReservation(id: "`+alicesID+`", input: {TotalCharge: 0}) { Id } }`)
+			gomega.Expect(code).To(gomega.Equal("FORBIDDEN"))
+			status, body := send(http.MethodPost, "/reservations", alice,
+				`{"roomId": "101", "checkinDate": "2030-02-01", "checkoutDate": "2030-02-03", "totalCharge": 0}`)
+			gomega.Expect(status).To(gomega.Equal(http.StatusForbidden))
+			gomega.Expect(body["error"]).To(gomega.HaveKeyWithValue("code", "FORBIDDEN"))
+
+			data, code = graphql(alice, `mutation { updateReservation(id: "`+alicesID+`", input: {CheckoutDate: "2030-01-11"}) { TotalCharge } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["updateReservation"]).To(gomega.Equal(map[string]interface{}{"TotalCharge": float64(110)}))
+
+			desk := token("desk-1", auth.RoleFrontDesk)
+			data, code = graphql(desk, `mutation { updateReservation(id: "`+alicesID+`", input: {TotalCharge: 0}) { TotalCharge } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["updateReservation"]).To(gomega.Equal(map[string]interface{}{"TotalCharge": float64(0)}))
+
+			_, code = graphql(desk, `mutation { updateReservation(id: "`+alicesID+`", input: {TotalCharge: 99.5}) { TotalCharge } }`)
+			gomega.Expect(code).To(gomega.Equal("BAD_USER_INPUT"))
+		})
+
+		ginkgo.It("refuses guests the mutations of the staff with FORBIDDEN", func() {
+			data, code := graphql(alice, `mutation { blockRoom(roomId: "101", startDate: "2030-02-01", endDate: "2030-02-03") { Id } }`)
+			gomega.Expect(code).To(gomega.Equal("FORBIDDEN"))
+			gomega.Expect(data["blockRoom"]).To(gomega.BeNil())
+			_, code = graphql(alice, `mutation { setRoomRates(roomId: "101", dailyRate: 1, cleaningFee: 0) { ID } }`)
+			gomega.Expect(code).To(gomega.Equal("FORBIDDEN"))
+		})
+
+		ginkgo.It("shows the front desk every reservation and lets it manage them, but not the rates", func() {
+			desk := token("desk-1", auth.RoleFrontDesk)
+			gomega.Expect(reservationIDs(desk)).To(gomega.ConsistOf(alicesID, bobsID))
+
+			data, code := graphql(desk, `{ reservation(id: "`+bobsID+`") { GuestId } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["reservation"]).To(gomega.Equal(map[string]interface{}{"GuestId": "jwt:bob"}))
+
+			_, code = graphql(desk, `mutation { cancelReservation(id: "`+bobsID+`") { Id } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			_, code = graphql(desk, `mutation { blockRoom(roomId: "101", startDate: "2030-02-01", endDate: "2030-02-03") { Id } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+
+			_, code = graphql(desk, `mutation { setRoomRates(roomId: "101", dailyRate: 1, cleaningFee: 0) { ID } }`)
+			gomega.Expect(code).To(gomega.Equal("FORBIDDEN"))
+		})
+
+		ginkgo.It("lets admins change room rates", func() {
+			admin := token("ops", auth.RoleAdmin)
+			data, code := graphql(admin, `mutation { setRoomRates(roomId: "101", dailyRate: 120, cleaningFee: 15) { DailyRate CleaningFee } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["setRoomRates"]).To(gomega.Equal(map[string]interface{}{"DailyRate": float64(120), "CleaningFee": float64(15)}))
+
+			data, _ = graphql(alice, `{ rooms { DailyRate } }`)
+			gomega.Expect(data["rooms"]).To(gomega.Equal([]interface{}{map[string]interface{}{"DailyRate": float64(120)}}))
+
+			_, code = graphql(admin, `mutation { setRoomRates(roomId: "999", dailyRate: 120, cleaningFee: 15) { ID } }`)
+			gomega.Expect(code).To(gomega.Equal("NOT_FOUND"))
+			gomega.Expect(reservationIDs(admin)).To(gomega.ConsistOf(alicesID, bobsID))
+		})
+	})
+
+	ginkgo.Context("with authentication optional", func() {
+		var apiKey string
+
+		ginkgo.BeforeEach(func() {
+			dir := ginkgo.GinkgoT().TempDir()
+			key, entry, err := auth.NewAPIKey("acme-desk", auth.RoleFrontDesk)
+			gomega.Expect(err).To(gomega.BeNil())
+			keys, _ := json.Marshal([]auth.APIKey{entry})
+			gomega.Expect(os.WriteFile(filepath.Join(dir, "api-keys.json"), keys, 0o600)).To(gomega.Succeed())
+
+			authenticator, err := auth.New(config.Auth{APIKeysFile: filepath.Join(dir, "api-keys.json"), JWTSecret: secret})
+			gomega.Expect(err).To(gomega.BeNil())
+
+			repos := memory.New().Repositories()
+			saveRooms(repos, api.Room{ID: "101", NumBeds: 2, DailyRate: 100, CleaningFee: 10})
+			server, err := api.NewServer(api.Settings{Authenticator: authenticator}, repos)
+			gomega.Expect(err).To(gomega.BeNil())
+			handler = server.Handler
+			apiKey = key
+		})
+
+		ginkgo.It("lets anonymous callers search rooms but not book them", func() {
+			data, code := graphql(nil, `{ rooms { ID } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			gomega.Expect(data["rooms"]).To(gomega.HaveLen(1))
+
+			_, code = graphql(nil, `{ reservations { totalCount } }`)
+			gomega.Expect(code).To(gomega.Equal("UNAUTHENTICATED"))
+			status, body := send(http.MethodPost, "/reservations", nil,
+				`{"roomId": "101", "checkinDate": "2030-01-10", "checkoutDate": "2030-01-12", "totalCharge": 210}`)
+			gomega.Expect(status).To(gomega.Equal(http.StatusUnauthorized))
+			gomega.Expect(body["error"]).To(gomega.HaveKeyWithValue("code", "UNAUTHENTICATED"))
+		})
+
+		ginkgo.It("gives partners the roles of their API key", func() {
+			header := http.Header{auth.APIKeyHeader: {apiKey}}
+			_, code := graphql(header, `mutation { blockRoom(roomId: "101", startDate: "2030-02-01", endDate: "2030-02-03") { Id } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			data, _ := graphql(header, `{ reservations { edges { node { GuestId Status } } } }`)
+			gomega.Expect(data["reservations"]).To(gomega.Equal(map[string]interface{}{"edges": []interface{}{
+				map[string]interface{}{"node": map[string]interface{}{"GuestId": "api-key:acme-desk", "Status": "BLOCKED"}},
+			}}))
+		})
+
+		ginkgo.It("does not let a JWT subject own the reservations of an API key of the same name", func() {
+			partner := http.Header{auth.APIKeyHeader: {apiKey}}
+			data, code := graphql(partner, `mutation { createReservation(input: {RoomID: "101", CheckinDate: "2030-01-10", CheckoutDate: "2030-01-12", TotalCharge: 210}) { Id } }`)
+			gomega.Expect(code).To(gomega.BeNil())
+			partnersID := data["createReservation"].(map[string]interface{})["Id"].(string)
+
+			impostor := token("acme-desk")
+			gomega.Expect(reservationIDs(impostor)).To(gomega.BeEmpty())
+			_, code = graphql(impostor, `mutation { cancelReservation(id: "`+partnersID+`") { Id } }`)
+			gomega.Expect(code).To(gomega.Equal("NOT_FOUND"))
+			gomega.Expect(reservationIDs(partner)).To(gomega.ConsistOf(partnersID))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package specs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+// brokenRooms fails to list the rooms, as a lost database would.
+type brokenRooms struct {
+	store.RoomRepository
+}
+
+func (brokenRooms) ListRooms(ctx context.Context) ([]store.Room, error) {
+	return nil, errors.New("connection refused")
+}
+
+var _ = ginkgo.Describe("When requests are logged", func() {
+	var output *bytes.Buffer
+
+	ginkgo.BeforeEach(func() {
+		output = &bytes.Buffer{}
+		logging.SetOutput(output)
+		gomega.Expect(logging.SetLevel("info")).To(gomega.Succeed())
+		ginkgo.DeferCleanup(func() {
+			logging.SetOutput(ginkgo.GinkgoWriter)
+			logging.SetLevel("info")
+		})
+	})
+
+	// lines returns the log lines with the message msg.
+	lines := func(msg string) []map[string]interface{} {
+		var found []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			var entry map[string]interface{}
+			gomega.Expect(json.Unmarshal([]byte(line), &entry)).To(gomega.Succeed(), line)
+			if entry["msg"] == msg {
+				found = append(found, entry)
+			}
+		}
+		return found
+	}
+
+	serve := func(repos store.Repositories, header http.Header, query string) *httptest.ResponseRecorder {
+		server, err := api.NewServer(api.Settings{}, repos)
+		gomega.Expect(err).To(gomega.BeNil())
+		body, _ := json.Marshal(api.GraphQLRequest{Query: query})
+		request := httptest.NewRequest(http.MethodPost, "/api", bytes.NewReader(body))
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	ginkgo.It("logs the operation and the request under the id the client sent", func() {
+		response := serve(memory.New().Repositories(), http.Header{"X-Request-Id": {"booking-42"}},
+			"query ListRooms { rooms { ID } }")
+		gomega.Expect(response.Code).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(response.Header().Get("X-Request-Id")).To(gomega.Equal("booking-42"))
+
+		operations := lines("graphql operation")
+		gomega.Expect(operations).To(gomega.HaveLen(1))
+		gomega.Expect(operations[0]).To(gomega.HaveKeyWithValue("level", "INFO"))
+		gomega.Expect(operations[0]).To(gomega.HaveKeyWithValue("requestId", "booking-42"))
+		gomega.Expect(operations[0]).To(gomega.HaveKeyWithValue("operationName", "ListRooms"))
+		gomega.Expect(operations[0]).To(gomega.HaveKeyWithValue("operationType", "query"))
+		gomega.Expect(operations[0]).To(gomega.HaveKey("durationMs"))
+
+		requests := lines("request")
+		gomega.Expect(requests).To(gomega.HaveLen(1))
+		gomega.Expect(requests[0]).To(gomega.HaveKeyWithValue("requestId", "booking-42"))
+		gomega.Expect(requests[0]).To(gomega.HaveKeyWithValue("path", "/api"))
+		gomega.Expect(requests[0]).To(gomega.HaveKeyWithValue("status", 200.0))
+	})
+
+	ginkgo.It("gives a request without a usable id a new one", func() {
+		response := serve(memory.New().Repositories(), http.Header{"X-Request-Id": {"not an id"}}, "{ rooms { ID } }")
+		id := response.Header().Get("X-Request-Id")
+		gomega.Expect(id).To(gomega.MatchRegexp("^[0-9a-f]{32}$"))
+		gomega.Expect(lines("request")[0]).To(gomega.HaveKeyWithValue("requestId", id))
+	})
+
+	ginkgo.It("logs resolver failures as errors", func() {
+		repos := memory.New().Repositories()
+		repos.Rooms = brokenRooms{repos.Rooms}
+		serve(repos, nil, "query ListRooms { rooms { ID } }")
+
+		operations := lines("graphql operation")
+		gomega.Expect(operations).To(gomega.HaveLen(1))
+		gomega.Expect(operations[0]).To(gomega.HaveKeyWithValue("level", "ERROR"))
+		gomega.Expect(operations[0]["errors"]).To(gomega.ConsistOf("rooms: connection refused"))
+	})
+
+	ginkgo.It("takes the id of a Lambda invocation from API Gateway", func() {
+		handler, err := api.NewGraphQlApiHandler(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod:     http.MethodPost,
+			Path:           "/api",
+			Body:           `{"query": "{ rooms { ID } }"}`,
+			RequestContext: events.APIGatewayProxyRequestContext{RequestID: "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"},
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(response.Headers).To(gomega.HaveKeyWithValue("X-Request-Id", "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"))
+		gomega.Expect(lines("graphql operation")[0]).To(gomega.HaveKeyWithValue("requestId", "c6af9ac6-7b61-11e6-9a41-93e8deadbeef"))
+	})
+
+	ginkgo.It("logs the SQL statements of the request at debug level", func() {
+		gomega.Expect(logging.SetLevel("debug")).To(gomega.Succeed())
+		db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+
+		serve(db.Repositories(), http.Header{"X-Request-Id": {"booking-43"}}, "{ rooms { ID } }")
+		statements := lines("sql")
+		gomega.Expect(statements).NotTo(gomega.BeEmpty())
+		gomega.Expect(statements[len(statements)-1]).To(gomega.HaveKeyWithValue("requestId", "booking-43"))
+		gomega.Expect(statements[len(statements)-1]["statement"]).To(gomega.HavePrefix("select id, num_beds"))
+	})
+})
@@ -0,0 +1,107 @@
+package specs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+var _ = ginkgo.Describe("When requests are traced", func() {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var recorder *tracetest.SpanRecorder
+
+	ginkgo.BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		ginkgo.DeferCleanup(func() {
+			otel.SetTracerProvider(provider)
+			otel.SetTextMapPropagator(propagator)
+		})
+	})
+
+	// span returns the ended span named name.
+	span := func(name string) sdktrace.ReadOnlySpan {
+		var names []string
+		for _, span := range recorder.Ended() {
+			if span.Name() == name {
+				return span
+			}
+			names = append(names, span.Name())
+		}
+		ginkgo.Fail("no span " + name + " among " + strings.Join(names, ", "))
+		return nil
+	}
+
+	ginkgo.It("continues the trace of the request down to the SQL statements", func() {
+		db, err := sqlite.Open(config.Database{Driver: config.DriverSQLite, Path: ":memory:"})
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+		server, err := api.NewServer(api.Settings{}, db.Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+
+		request := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(`{"query": "query ListRooms { rooms { ID } }"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("traceparent", traceparent)
+		server.Handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		root := span("POST /api")
+		operation := span("query ListRooms")
+		resolver := span("RootQuery.rooms")
+		statement := span("SELECT")
+		gomega.Expect(root.SpanContext().TraceID().String()).To(gomega.Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		gomega.Expect(root.Parent().SpanID().String()).To(gomega.Equal("00f067aa0ba902b7"))
+		gomega.Expect(operation.Parent().SpanID()).To(gomega.Equal(root.SpanContext().SpanID()))
+		gomega.Expect(resolver.Parent().SpanID()).To(gomega.Equal(operation.SpanContext().SpanID()))
+		gomega.Expect(statement.Parent().SpanID()).To(gomega.Equal(resolver.SpanContext().SpanID()))
+	})
+
+	ginkgo.It("marks the operation failed when the result has errors", func() {
+		server, err := api.NewServer(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+
+		request := httptest.NewRequest(http.MethodPost, "/api", strings.NewReader(`{"query": "query ListRooms { rooms { unknown } }"}`))
+		request.Header.Set("Content-Type", "application/json")
+		server.Handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		gomega.Expect(span("query ListRooms").Status().Description).To(gomega.ContainSubstring("unknown"))
+	})
+
+	ginkgo.It("leaves health checks out", func() {
+		server, err := api.NewServer(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		server.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		gomega.Expect(recorder.Ended()).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("traces Lambda invocations", func() {
+		handler, err := api.NewGraphQlApiHandler(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: http.MethodPost,
+			Path:       "/api",
+			Headers:    map[string]string{"traceparent": traceparent},
+			Body:       `{"query": "query ListRooms { rooms { ID } }"}`,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		root := span("POST /api")
+		gomega.Expect(root.SpanContext().TraceID().String()).To(gomega.Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		gomega.Expect(span("query ListRooms").Parent().SpanID()).To(gomega.Equal(root.SpanContext().SpanID()))
+	})
+})
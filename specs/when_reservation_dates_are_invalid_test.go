@@ -0,0 +1,55 @@
+package specs
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When reservation dates are invalid", func() {
+	availableRooms := func(startDate string, endDate string) error {
+		_, err := api.GetAvailableRooms(memory.New())(graphql.ResolveParams{
+			Args: map[string]interface{}{
+				"startDate":    startDate,
+				"endDate":      endDate,
+				"numBeds":      1,
+				"allowSmoking": false,
+			},
+		})
+		return err
+	}
+
+	ginkgo.It("rejects dates that do not exist", func() {
+		gomega.Expect(availableRooms("2023-13-45", "2023-03-05")).To(gomega.MatchError(gomega.ContainSubstring("invalid date")))
+	})
+
+	ginkgo.It("rejects a checkout that is not after the checkin", func() {
+		gomega.Expect(availableRooms("2023-03-05", "2023-03-05")).To(gomega.MatchError(gomega.ContainSubstring("must be after")))
+		gomega.Expect(availableRooms("2023-03-05", "2023-03-01")).To(gomega.MatchError(gomega.ContainSubstring("must be after")))
+	})
+
+	ginkgo.It("rejects arrivals in the past", func() {
+		gomega.Expect(availableRooms("2023-01-15", "2023-01-20")).To(gomega.MatchError(gomega.ContainSubstring("in the past")))
+	})
+
+	ginkgo.It("rejects stays longer than the maximum", func() {
+		gomega.Expect(availableRooms("2023-03-01", "2024-03-01")).To(gomega.MatchError(gomega.ContainSubstring("exceeds the maximum")))
+	})
+
+	ginkgo.It("rejects malformed dates at the schema layer", func() {
+		schema, err := api.AppSchema(memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+
+		result := graphql.Do(graphql.Params{
+			Schema: schema,
+			RequestString: `{
+				availableRooms(startDate: "2023-13-45", endDate: "2023-03-05", numBeds: 1, allowSmoking: false) { ID }
+			}`,
+		})
+		gomega.Expect(result.Errors).NotTo(gomega.BeEmpty())
+		gomega.Expect(result.Errors[0].Message).To(gomega.ContainSubstring("startDate"))
+	})
+})
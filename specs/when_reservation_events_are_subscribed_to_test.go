@@ -0,0 +1,140 @@
+package specs
+
+import (
+	"context"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When reservation events are subscribed to", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	ginkgo.BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	ginkgo.AfterEach(func() {
+		cancel()
+	})
+
+	ginkgo.It("fans every event out to each interested subscriber", func() {
+		hub := api.NewHub(api.SubscriberBufferSize)
+		all := hub.Subscribe(ctx, nil)
+		onlyRoom101 := hub.Subscribe(ctx, func(event interface{}) bool {
+			return event.(api.ReservationEvent).Reservation.RoomID == "101"
+		})
+
+		hub.Publish(api.ReservationEvent{Action: api.ReservationCreatedAction, Reservation: api.Reservation{RoomID: "102"}})
+		hub.Publish(api.ReservationEvent{Action: api.ReservationCreatedAction, Reservation: api.Reservation{RoomID: "101"}})
+
+		gomega.Expect(all).To(gomega.HaveLen(2))
+		gomega.Expect(onlyRoom101).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("disconnects subscribers that fall behind instead of blocking", func() {
+		hub := api.NewHub(1)
+		slow := hub.Subscribe(ctx, nil)
+
+		hub.Publish(api.ReservationEvent{})
+		hub.Publish(api.ReservationEvent{})
+
+		gomega.Expect(hub.Len()).To(gomega.Equal(0))
+		<-slow
+		gomega.Eventually(slow).Should(gomega.BeClosed())
+	})
+
+	ginkgo.It("stops delivering once the subscriber goes away", func() {
+		hub := api.NewHub(api.SubscriberBufferSize)
+		subscriberCtx, unsubscribe := context.WithCancel(ctx)
+		events := hub.Subscribe(subscriberCtx, nil)
+
+		unsubscribe()
+		gomega.Eventually(events).Should(gomega.BeClosed())
+		gomega.Expect(hub.Len()).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("streams room availability changes within the requested dates", func() {
+		schema, err := api.AppSchema(memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+
+		results := graphql.Subscribe(graphql.Params{
+			Schema: schema,
+			RequestString: `subscription {
+				roomAvailabilityChanged(dateRange: {start: "2023-03-01", end: "2023-03-10"}) { roomId start end available }
+			}`,
+			Context: ctx,
+		})
+		gomega.Eventually(api.Events.Len).Should(gomega.BeNumerically(">", 0))
+
+		api.Events.Publish(api.ReservationEvent{
+			Action:      api.ReservationCreatedAction,
+			Reservation: api.Reservation{RoomID: "101", CheckinDate: "2023-04-02", CheckoutDate: "2023-04-05"},
+		})
+		api.Events.Publish(api.ReservationEvent{
+			Action:      api.ReservationCancelledAction,
+			Reservation: api.Reservation{RoomID: "102", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05"},
+		})
+
+		var result *graphql.Result
+		gomega.Eventually(results, time.Second).Should(gomega.Receive(&result))
+		gomega.Expect(result.Errors).To(gomega.BeEmpty())
+		gomega.Expect(result.Data).To(gomega.Equal(map[string]interface{}{
+			"roomAvailabilityChanged": map[string]interface{}{
+				"roomId":    "102",
+				"start":     "2023-03-02",
+				"end":       "2023-03-05",
+				"available": true,
+			},
+		}))
+	})
+
+	ginkgo.It("releases the dates a reservation is moved away from", func() {
+		repos := memory.New().Repositories()
+		saveRooms(repos, api.Room{ID: "101", NumBeds: 1, DailyRate: 100})
+		reservation, err := repos.Reservations.CreateReservation(context.Background(), api.Reservation{
+			RoomID: "101", CheckinDate: "2030-03-02", CheckoutDate: "2030-03-05", TotalCharge: 300, Status: api.ReservationConfirmed,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		schema, err := api.AppSchema(repos)
+		gomega.Expect(err).To(gomega.BeNil())
+
+		// Wait for the subscriptions of earlier specs to go away, so that the
+		// one below is known to be listening once the hub has a subscriber.
+		gomega.Eventually(api.Events.Len).Should(gomega.BeZero())
+		results := graphql.Subscribe(graphql.Params{
+			Schema: schema,
+			RequestString: `subscription {
+				roomAvailabilityChanged(dateRange: {start: "2030-03-01", end: "2030-03-10"}) { roomId start end available }
+			}`,
+			Context: ctx,
+		})
+		gomega.Eventually(api.Events.Len).Should(gomega.BeNumerically(">", 0))
+
+		moved := graphql.Do(graphql.Params{
+			Schema:        schema,
+			RequestString: `mutation { updateReservation(id: "` + reservation.ID + `", input: {CheckinDate: "2030-04-02", CheckoutDate: "2030-04-05"}) { Id } }`,
+			Context:       context.Background(),
+		})
+		gomega.Expect(moved.Errors).To(gomega.BeEmpty())
+
+		var result *graphql.Result
+		gomega.Eventually(results, time.Second).Should(gomega.Receive(&result))
+		gomega.Expect(result.Errors).To(gomega.BeEmpty())
+		gomega.Expect(result.Data).To(gomega.Equal(map[string]interface{}{
+			"roomAvailabilityChanged": map[string]interface{}{
+				"roomId":    "101",
+				"start":     "2030-03-02",
+				"end":       "2030-03-05",
+				"available": true,
+			},
+		}))
+		gomega.Consistently(results, 100*time.Millisecond).ShouldNot(gomega.Receive())
+	})
+})
@@ -0,0 +1,100 @@
+package specs
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+var _ = ginkgo.Describe("When reservations are listed", func() {
+	withEachStore(func(repositories func() store.Repositories) {
+		var repos store.Repositories
+
+		ginkgo.BeforeEach(func() {
+			repos = repositories()
+
+			saveRooms(repos,
+				api.Room{ID: "101", NumBeds: 1, AllowSmoking: false, DailyRate: 100.0, CleaningFee: 10.0},
+				api.Room{ID: "102", NumBeds: 1, AllowSmoking: false, DailyRate: 120.0, CleaningFee: 10.0},
+			)
+
+			saveReservations(repos,
+				api.Reservation{RoomID: "101", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05", TotalCharge: 310.0},
+				api.Reservation{RoomID: "102", CheckinDate: "2023-03-05", CheckoutDate: "2023-03-08", TotalCharge: 370.0},
+				api.Reservation{RoomID: "101", CheckinDate: "2023-03-10", CheckoutDate: "2023-03-12", TotalCharge: 210.0},
+				api.Reservation{RoomID: "102", CheckinDate: "2023-03-20", CheckoutDate: "2023-03-21", TotalCharge: 130.0, Status: api.ReservationCancelled},
+			)
+		})
+
+		list := func(args map[string]interface{}) *api.ReservationConnection {
+			result, err := api.GetAllReservations(repos.Reservations)(graphql.ResolveParams{Args: args})
+			gomega.Expect(err).To(gomega.BeNil())
+			return result.(*api.ReservationConnection)
+		}
+
+		checkinDates := func(connection *api.ReservationConnection) []string {
+			dates := []string{}
+			for _, edge := range connection.Edges {
+				dates = append(dates, edge.Node.CheckinDate)
+			}
+			return dates
+		}
+
+		ginkgo.It("pages through reservations with cursors", func() {
+			orderBy := map[string]interface{}{"field": "checkin_date", "direction": "asc"}
+
+			page := list(map[string]interface{}{"first": 2, "orderBy": orderBy})
+			gomega.Expect(checkinDates(page)).To(gomega.Equal([]string{"2023-03-02", "2023-03-05"}))
+			gomega.Expect(page.PageInfo.HasNextPage).To(gomega.BeTrue())
+			gomega.Expect(page.PageInfo.HasPreviousPage).To(gomega.BeFalse())
+
+			page = list(map[string]interface{}{"first": 2, "after": *page.PageInfo.EndCursor, "orderBy": orderBy})
+			gomega.Expect(checkinDates(page)).To(gomega.Equal([]string{"2023-03-10", "2023-03-20"}))
+			gomega.Expect(page.PageInfo.HasNextPage).To(gomega.BeFalse())
+			gomega.Expect(page.PageInfo.HasPreviousPage).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("filters by room, overlapping dates and status", func() {
+			page := list(map[string]interface{}{
+				"filter": map[string]interface{}{"roomIds": []interface{}{"101"}},
+			})
+			gomega.Expect(page.Edges).To(gomega.HaveLen(2))
+
+			page = list(map[string]interface{}{
+				"filter": map[string]interface{}{
+					"overlaps": map[string]interface{}{"start": "2023-03-04", "end": "2023-03-06"},
+				},
+			})
+			gomega.Expect(checkinDates(page)).To(gomega.ConsistOf("2023-03-02", "2023-03-05"))
+
+			page = list(map[string]interface{}{
+				"filter": map[string]interface{}{"status": []interface{}{api.ReservationCancelled}},
+			})
+			gomega.Expect(checkinDates(page)).To(gomega.Equal([]string{"2023-03-20"}))
+		})
+
+		ginkgo.It("sorts by total charge, most expensive first", func() {
+			page := list(map[string]interface{}{
+				"orderBy": map[string]interface{}{"field": "total_charge", "direction": "desc"},
+			})
+			charges := []float64{}
+			for _, edge := range page.Edges {
+				charges = append(charges, edge.Node.TotalCharge)
+			}
+			gomega.Expect(charges).To(gomega.Equal([]float64{370, 310, 210, 130}))
+		})
+
+		ginkgo.It("rejects a cursor issued for a different sort order", func() {
+			page := list(map[string]interface{}{"first": 1})
+
+			_, err := api.GetAllReservations(repos.Reservations)(graphql.ResolveParams{Args: map[string]interface{}{
+				"after":   *page.PageInfo.EndCursor,
+				"orderBy": map[string]interface{}{"field": "total_charge", "direction": "asc"},
+			}})
+			gomega.Expect(err).NotTo(gomega.BeNil())
+		})
+	})
+})
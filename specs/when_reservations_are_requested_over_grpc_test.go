@@ -0,0 +1,130 @@
+package specs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/hotelpb"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When reservations are requested over gRPC", func() {
+	var server *grpc.Server
+	var conn *grpc.ClientConn
+	var client hotelpb.ReservationServiceClient
+	var repos store.Repositories
+
+	ginkgo.BeforeEach(func() {
+		listener := bufconn.Listen(1024 * 1024)
+		server = grpc.NewServer()
+		repos = memory.New().Repositories()
+		hotelpb.RegisterReservationServiceServer(server, api.NewReservationServer(repos))
+		go server.Serve(listener)
+
+		var err error
+		conn, err = grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		gomega.Expect(err).To(gomega.BeNil())
+		client = hotelpb.NewReservationServiceClient(conn)
+	})
+
+	ginkgo.AfterEach(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	code := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	ginkgo.It("rejects invalid stays with INVALID_ARGUMENT", func() {
+		_, err := client.SearchAvailability(context.Background(), &hotelpb.SearchAvailabilityRequest{
+			StartDate: "2023-03-05", EndDate: "2023-03-01", NumBeds: 1,
+		})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.InvalidArgument))
+		gomega.Expect(status.Convert(err).Message()).To(gomega.ContainSubstring("must be after"))
+
+		_, err = client.CreateReservation(context.Background(), &hotelpb.CreateReservationRequest{
+			RoomId: "101", CheckinDate: "2023-03-01", CheckoutDate: "2023-07-01", TotalCharge: 100,
+		})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.InvalidArgument))
+	})
+
+	ginkgo.It("stops gracefully and returns when its context is done", func() {
+		free, err := net.Listen("tcp", "localhost:0")
+		gomega.Expect(err).To(gomega.BeNil())
+		port := free.Addr().(*net.TCPAddr).Port
+		gomega.Expect(free.Close()).To(gomega.Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		settings := api.Settings{Config: config.Config{
+			API:  config.API{ShutdownTimeout: config.Duration{Duration: time.Second}},
+			GRPC: config.GRPC{Port: port},
+		}}
+		served := make(chan error, 1)
+		go func() {
+			served <- api.GrpcApiHandler(ctx, settings, repos)
+		}()
+
+		running, err := grpc.Dial(fmt.Sprintf("localhost:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		gomega.Expect(err).To(gomega.BeNil())
+		defer running.Close()
+		gomega.Eventually(func() codes.Code {
+			_, err := hotelpb.NewReservationServiceClient(running).GetReservation(context.Background(), &hotelpb.GetReservationRequest{Id: "not-a-number"})
+			return code(err)
+		}).Should(gomega.Equal(codes.NotFound))
+
+		cancel()
+		gomega.Eventually(served, 5*time.Second).Should(gomega.Receive(gomega.BeNil()))
+	})
+
+	ginkgo.It("reports unknown reservations as NOT_FOUND", func() {
+		_, err := client.GetReservation(context.Background(), &hotelpb.GetReservationRequest{Id: "not-a-number"})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.NotFound))
+
+		_, err = client.CancelReservation(context.Background(), &hotelpb.CancelReservationRequest{Id: "not-a-number"})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.NotFound))
+	})
+
+	ginkgo.It("validates the listing order and page size", func() {
+		_, err := client.ListReservations(context.Background(), &hotelpb.ListReservationsRequest{OrderBy: "checkin_date sideways"})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.InvalidArgument))
+
+		_, err = client.ListReservations(context.Background(), &hotelpb.ListReservationsRequest{PageSize: 1000})
+		gomega.Expect(code(err)).To(gomega.Equal(codes.InvalidArgument))
+	})
+
+	ginkgo.It("reports and filters blocked rooms as BLOCKED", func() {
+		saveRooms(repos, api.Room{ID: "101", NumBeds: 2, DailyRate: 100, CleaningFee: 10})
+		block, err := repos.Reservations.CreateReservation(context.Background(), store.Reservation{
+			RoomID: "101", CheckinDate: "2030-02-01", CheckoutDate: "2030-02-03", Status: store.ReservationBlocked,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		reservation, err := client.GetReservation(context.Background(), &hotelpb.GetReservationRequest{Id: block.ID})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(reservation.Status).To(gomega.Equal(hotelpb.ReservationStatus_RESERVATION_STATUS_BLOCKED))
+
+		page, err := client.ListReservations(context.Background(), &hotelpb.ListReservationsRequest{
+			Statuses: []hotelpb.ReservationStatus{hotelpb.ReservationStatus_RESERVATION_STATUS_BLOCKED},
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(page.Reservations).To(gomega.HaveLen(1))
+		gomega.Expect(page.Reservations[0].Id).To(gomega.Equal(block.ID))
+	})
+})
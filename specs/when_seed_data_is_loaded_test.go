@@ -0,0 +1,84 @@
+package specs
+
+import (
+	"context"
+	"testing/fstest"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/seed"
+)
+
+var _ = ginkgo.Describe("When seed data is loaded", func() {
+	ginkgo.It("reads the development set from JSON and validates it", func() {
+		set, err := seed.Read(fstest.MapFS{
+			"rooms.json":        {Data: []byte(`[{"id": "101", "num_beds": 1, "allow_smoking": false, "daily_rate": 100, "cleaning_fee": 10}]`)},
+			"reservations.json": {Data: []byte(`[{"room_id": "101", "checkin_date": "2023-03-02", "checkout_date": "2023-03-05", "total_charge": 310}]`)},
+		}, ".")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(set.Rooms).To(gomega.Equal([]store.Room{{ID: "101", NumBeds: 1, DailyRate: 100, CleaningFee: 10}}))
+		gomega.Expect(set.Reservations).To(gomega.Equal([]store.Reservation{{
+			RoomID: "101", CheckinDate: "2023-03-02", CheckoutDate: "2023-03-05", TotalCharge: 310, Status: api.ReservationConfirmed,
+		}}))
+		gomega.Expect(set.Validate()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("reports every invalid room and reservation", func() {
+		set, err := seed.Read(fstest.MapFS{
+			"rooms.csv": {Data: []byte("id,num_beds,allow_smoking,daily_rate,cleaning_fee\n101,1,false,100,10\n101,0,false,100,10\n")},
+			"reservations.csv": {Data: []byte("room_id,checkin_date,checkout_date,total_charge\n" +
+				"999,2023-03-02,2023-03-05,310\n" +
+				"101,03/02/2023,2023-03-05,310\n" +
+				"101,2023-03-05,2023-03-02,310\n" +
+				"101,2023-03-02,2023-03-05,310\n" +
+				"101,2023-03-04,2023-03-06,210\n")},
+		}, ".")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		err = set.Validate()
+		var invalid *seed.Error
+		gomega.Expect(err).To(gomega.BeAssignableToTypeOf(invalid))
+		gomega.Expect(err.(*seed.Error).Problems).To(gomega.ConsistOf(
+			"room 101 is listed twice",
+			"room 101 has 0 beds",
+			"reservation 1 (room 999, 2023-03-02 to 2023-03-05): unknown room",
+			"reservation 2 (room 101, 03/02/2023 to 2023-03-05): dates must be YYYY-MM-DD",
+			"reservation 3 (room 101, 2023-03-05 to 2023-03-02): checkout is not after checkin",
+			"reservation 5 (room 101, 2023-03-04 to 2023-03-06): overlaps 2023-03-02 to 2023-03-05",
+		))
+	})
+
+	withEachStore(func(repositories func() store.Repositories) {
+		ginkgo.It("loads the test fixtures once, however often they are loaded", func() {
+			repos := repositories()
+			set := loadFixtures(repos, "test")
+			loadFixtures(repos, "test")
+
+			reservations, err := repos.Reservations.ListReservations(context.Background(), store.ReservationQuery{})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservations).To(gomega.HaveLen(len(set.Reservations)))
+			rooms, err := repos.Rooms.Rooms(context.Background(), []string{"101", "102", "103", "201"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rooms).To(gomega.HaveLen(len(set.Rooms)))
+		})
+
+		ginkgo.It("stores nothing when a reservation overlaps a stored one", func() {
+			repos := repositories()
+			loadFixtures(repos, "test")
+
+			err := seed.Load(context.Background(), repos, seed.Set{
+				Rooms: []store.Room{{ID: "301", NumBeds: 1, DailyRate: 90, CleaningFee: 10}},
+				Reservations: []store.Reservation{
+					{RoomID: "101", CheckinDate: "2023-03-04", CheckoutDate: "2023-03-07", TotalCharge: 310, Status: api.ReservationConfirmed},
+				},
+			})
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("overlaps stored reservation")))
+			rooms, err := repos.Rooms.Rooms(context.Background(), []string{"301"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rooms).To(gomega.BeEmpty())
+		})
+	})
+})
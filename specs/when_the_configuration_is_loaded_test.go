@@ -0,0 +1,184 @@
+package specs
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+)
+
+var _ = ginkgo.Describe("When the configuration is loaded", func() {
+	variables := []string{
+		"CONFIG_FILE", "ENV", "API_PORT", "SHUTDOWN_TIMEOUT", "GRPC_PORT", "AWS_LAMBDA_FUNCTION_NAME",
+		"API_ALLOWED_ORIGINS",
+		"DB_DRIVER", "DB_PATH", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWD", "DB_PASSWD_FILE", "DB_NAME", "DB_SSLMODE",
+		"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME",
+		"GRAPHQL_MAX_DEPTH", "GRAPHQL_MAX_COMPLEXITY", "PERSISTED_QUERIES_MODE", "PERSISTED_QUERIES_FILE", "LOG_LEVEL",
+		"TRACING_EXPORTER", "TRACING_ENDPOINT", "DIAGNOSTICS_TOKEN",
+		"AUTH_REQUIRED", "AUTH_JWKS_FILE", "AUTH_JWT_SECRET", "AUTH_JWT_ISSUER", "AUTH_JWT_AUDIENCE", "AUTH_API_KEYS_FILE",
+		"RATE_LIMIT_STORE", "RATE_LIMIT_SEARCHES_PER_MINUTE", "RATE_LIMIT_SEARCH_BURST",
+		"RATE_LIMIT_BOOKINGS_PER_MINUTE", "RATE_LIMIT_BOOKING_BURST",
+	}
+	saved := map[string]string{}
+
+	ginkgo.BeforeEach(func() {
+		for _, name := range variables {
+			saved[name] = os.Getenv(name)
+			os.Unsetenv(name)
+		}
+	})
+
+	ginkgo.AfterEach(func() {
+		for name, value := range saved {
+			if value == "" {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, value)
+			}
+		}
+	})
+
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(ginkgo.GinkgoT().TempDir(), name)
+		gomega.Expect(os.WriteFile(path, []byte(content), 0o600)).To(gomega.Succeed())
+		return path
+	}
+
+	ginkgo.It("picks defaults for the environment", func() {
+		development, err := config.Load(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(development.Env).To(gomega.Equal(config.Development))
+		gomega.Expect(development.Database.Name).To(gomega.Equal("hotel_development"))
+		gomega.Expect(development.Database.SSLMode).To(gomega.Equal("disable"))
+
+		gomega.Expect(development.Auth.Required).To(gomega.BeFalse())
+		gomega.Expect(development.API.AllowedOrigins).To(gomega.Equal([]string{"http://localhost:8080"}))
+
+		os.Setenv("ENV", config.Production)
+		_, err = config.Load(nil)
+		gomega.Expect(err.(*config.Error).Problems).To(gomega.ConsistOf(gomega.HavePrefix("auth.required needs")))
+
+		os.Setenv("AUTH_API_KEYS_FILE", "api-keys.json")
+		production, err := config.Load(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(production.Auth.Required).To(gomega.BeTrue())
+		gomega.Expect(production.Database.SSLMode).To(gomega.Equal("require"))
+		gomega.Expect(production.GraphQL.MaxComplexity).To(gomega.BeNumerically("<", development.GraphQL.MaxComplexity))
+		gomega.Expect(production.API.AllowedOrigins).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("keeps a small connection pool and shared rate limits inside Lambda", func() {
+		server := config.Defaults(config.Production)
+		os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "hotel-api")
+		lambda := config.Defaults(config.Production)
+
+		gomega.Expect(lambda.Database.MaxOpenConns).To(gomega.BeNumerically("<", server.Database.MaxOpenConns))
+		gomega.Expect(lambda.Database.MaxIdleConns).To(gomega.BeNumerically("<=", lambda.Database.MaxOpenConns))
+		gomega.Expect(server.RateLimit.Store).To(gomega.Equal(config.RateLimitMemory))
+		gomega.Expect(lambda.RateLimit.Store).To(gomega.Equal(config.RateLimitDatabase))
+	})
+
+	ginkgo.It("defaults to production inside Lambda", func() {
+		os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "hotel-api")
+		os.Setenv("AUTH_API_KEYS_FILE", "api-keys.json")
+		cfg, err := config.Load(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(cfg.Env).To(gomega.Equal(config.Production))
+		gomega.Expect(cfg.Auth.Required).To(gomega.BeTrue())
+		gomega.Expect(cfg.Database.SSLMode).To(gomega.Equal("require"))
+
+		os.Setenv("ENV", config.Development)
+		cfg, err = config.Load(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(cfg.Env).To(gomega.Equal(config.Development))
+	})
+
+	ginkgo.It("lets the environment override the file and flags override both", func() {
+		path := writeFile("config.json", `{
+			"env": "test",
+			"api": {"port": 9000, "shutdownTimeout": "10s"},
+			"database": {"host": "db.internal", "port": 6432}
+		}`)
+		os.Setenv("DB_PORT", "7432")
+		os.Setenv("API_ALLOWED_ORIGINS", "https://hotel.example.com, https://admin.hotel.example.com")
+
+		cfg, err := config.Load([]string{"-config", path, "-db-port", "8432"})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(cfg.Env).To(gomega.Equal(config.Test))
+		gomega.Expect(cfg.Database.Name).To(gomega.Equal("hotel_test"))
+		gomega.Expect(cfg.API.Port).To(gomega.Equal(9000))
+		gomega.Expect(cfg.API.ShutdownTimeout.Duration).To(gomega.Equal(10 * time.Second))
+		gomega.Expect(cfg.Database.Host).To(gomega.Equal("db.internal"))
+		gomega.Expect(cfg.Database.Port).To(gomega.Equal(8432))
+		gomega.Expect(cfg.API.AllowedOrigins).To(gomega.Equal([]string{"https://hotel.example.com", "https://admin.hotel.example.com"}))
+	})
+
+	ginkgo.It("reads secrets from the files named by _FILE variables", func() {
+		os.Setenv("DB_PASSWD_FILE", writeFile("password", "s3cret pass\n"))
+
+		cfg, err := config.Load(nil)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(cfg.Database.Password).To(gomega.Equal("s3cret pass"))
+		gomega.Expect(cfg.Database.DataSourceName()).To(gomega.ContainSubstring(`password='s3cret pass'`))
+
+		os.Setenv("DB_PASSWD", "other")
+		_, err = config.Load(nil)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("DB_PASSWD and DB_PASSWD_FILE are both set")))
+	})
+
+	ginkgo.It("reports every invalid setting at once", func() {
+		os.Setenv("API_PORT", "eighty")
+		os.Setenv("API_ALLOWED_ORIGINS", "https://hotel.example.com,hotel.example.com/app")
+		os.Setenv("DB_SSLMODE", "sometimes")
+		os.Setenv("DB_MAX_OPEN_CONNS", "2")
+		os.Setenv("DB_MAX_IDLE_CONNS", "5")
+		os.Setenv("PERSISTED_QUERIES_MODE", config.PersistedQueriesAllowList)
+		os.Setenv("TRACING_EXPORTER", "jaeger")
+		os.Setenv("DIAGNOSTICS_TOKEN", "letmein")
+		os.Setenv("AUTH_REQUIRED", "yes please")
+		os.Setenv("AUTH_JWT_SECRET", "secret")
+		os.Setenv("RATE_LIMIT_STORE", "redis")
+		os.Setenv("RATE_LIMIT_SEARCH_BURST", "0")
+
+		_, err := config.Load(nil)
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(err.(*config.Error).Problems).To(gomega.ConsistOf(
+			gomega.HavePrefix("API_PORT: must be an integer"),
+			gomega.HavePrefix(`api.allowedOrigins must be origins such as https://hotel.example.com, not "hotel.example.com/app"`),
+			gomega.HavePrefix("database.sslMode must be"),
+			gomega.HavePrefix("database.maxIdleConns (5) must not exceed"),
+			gomega.HavePrefix("persistedQueries.mode allowlist requires"),
+			gomega.HavePrefix("tracing.exporter must be"),
+			gomega.HavePrefix("api.diagnosticsToken must be at least 16"),
+			gomega.HavePrefix("AUTH_REQUIRED: must be true or false"),
+			gomega.HavePrefix("auth.jwtSecret must be at least 32"),
+			gomega.HavePrefix(`rateLimit.store must be memory or database, not "redis"`),
+			gomega.HavePrefix("rateLimit.searchBurst must be at least 1"),
+		))
+	})
+
+	ginkgo.It("needs only a file for SQLite", func() {
+		os.Setenv("DB_DRIVER", config.DriverSQLite)
+		os.Setenv("DB_HOST", "")
+		os.Setenv("DB_SSLMODE", "sometimes")
+
+		cfg, err := config.Load([]string{"-db-path", "/var/lib/hotel/hotel.db"})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(cfg.Database.Path).To(gomega.Equal("/var/lib/hotel/hotel.db"))
+
+		os.Setenv("DB_DRIVER", "mysql")
+		_, err = config.Load(nil)
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`database.driver must be postgres or sqlite, not "mysql"`)))
+	})
+
+	ginkgo.It("rejects unknown keys in the file", func() {
+		path := writeFile("config.json", `{"database": {"hostname": "db.internal"}}`)
+
+		_, err := config.Load([]string{"-config", path})
+		gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring(`unknown field "hostname"`)))
+	})
+})
@@ -0,0 +1,138 @@
+package specs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/migrate"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+var _ = ginkgo.Describe("When the database is migrated", func() {
+	var (
+		ctx      context.Context
+		backend  *sqlite.Store
+		migrator *migrate.Migrator
+	)
+
+	ginkgo.BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		backend, err = sqlite.Connect(config.Database{
+			Driver: config.DriverSQLite,
+			Path:   filepath.Join(ginkgo.GinkgoT().TempDir(), "hotel.db"),
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(backend.Close)
+		migrator = backend.Migrator()
+	})
+
+	ginkgo.It("applies every migration in order and records them", func() {
+		statuses, err := migrator.Status(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(statuses).NotTo(gomega.BeEmpty())
+		for _, status := range statuses {
+			gomega.Expect(status.Applied).To(gomega.BeFalse())
+		}
+
+		ran, err := migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(ran).To(gomega.HaveLen(len(statuses)))
+		gomega.Expect(migrator.Version(ctx)).To(gomega.Equal(migrator.Latest()))
+
+		ran, err = migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(ran).To(gomega.BeEmpty())
+		saveRooms(backend.Repositories(), api.Room{ID: "101", NumBeds: 1, DailyRate: 100, CleaningFee: 10})
+	})
+
+	ginkgo.It("rolls back the last migration and migrates to any version", func() {
+		_, err := migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		statuses, err := migrator.Status(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(len(statuses)).To(gomega.BeNumerically(">=", 2))
+
+		ran, err := migrator.Down(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(ran).To(gomega.HaveLen(1))
+		gomega.Expect(ran[0].Version).To(gomega.Equal(migrator.Latest()))
+		gomega.Expect(migrator.Version(ctx)).To(gomega.Equal(statuses[len(statuses)-2].Version))
+
+		_, err = migrator.To(ctx, 0)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(migrator.Version(ctx)).To(gomega.BeZero())
+
+		_, err = migrator.To(ctx, statuses[0].Version)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(migrator.Version(ctx)).To(gomega.Equal(statuses[0].Version))
+
+		_, err = migrator.To(ctx, 42)
+		gomega.Expect(err).To(gomega.MatchError(migrate.ErrUnknownVersion))
+	})
+
+	ginkgo.It("adopts a file migrated when the migrations were numbered", func() {
+		_, err := backend.DB().Exec(`CREATE TABLE schema_migrations (
+			version integer PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+		gomega.Expect(err).To(gomega.BeNil())
+		// The numbered migrations are the first two of today, renamed since.
+		numbered := []struct{ name, current string }{
+			{"0001_create_rooms_and_reservations", "20230213225130_create_rooms_and_reservations_tables"},
+			{"0002_add_status_to_reservations", "20261019090000_add_status_to_reservations"},
+		}
+		for i, migration := range numbered {
+			statements, err := os.ReadFile(filepath.Join("..", "store", "sqlite", "migrations", migration.current+".up.sql"))
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = backend.DB().Exec(string(statements))
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = backend.DB().Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", i+1, migration.name)
+			gomega.Expect(err).To(gomega.BeNil())
+		}
+		saveRooms(backend.Repositories(), api.Room{ID: "101", NumBeds: 1, DailyRate: 100, CleaningFee: 10})
+
+		ran, err := migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		for _, migration := range ran {
+			gomega.Expect(migration.Version).To(gomega.BeNumerically(">", 20261019090000))
+		}
+		gomega.Expect(migrator.Version(ctx)).To(gomega.Equal(migrator.Latest()))
+		statuses, err := migrator.Status(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		for _, status := range statuses {
+			gomega.Expect(status.Applied).To(gomega.BeTrue())
+		}
+		var versions []int64
+		gomega.Expect(backend.DB().Select(&versions, "SELECT version FROM schema_migrations WHERE version < 3")).To(gomega.Succeed())
+		gomega.Expect(versions).To(gomega.BeEmpty())
+
+		rooms, err := backend.Repositories().Rooms.ListRooms(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(rooms).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("refuses to run when the database is ahead of the binary", func() {
+		_, err := migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeNil())
+		_, err = backend.DB().Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+			migrator.Latest()+1, "from_a_newer_release")
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var ahead *migrate.AheadError
+		_, err = migrator.Up(ctx)
+		gomega.Expect(err).To(gomega.BeAssignableToTypeOf(ahead))
+		_, err = migrator.Down(ctx)
+		gomega.Expect(err).To(gomega.BeAssignableToTypeOf(ahead))
+		_, err = migrator.Status(ctx)
+		gomega.Expect(err).To(gomega.BeAssignableToTypeOf(ahead))
+		gomega.Expect(migrator.Version(ctx)).To(gomega.Equal(migrator.Latest() + 1))
+	})
+})
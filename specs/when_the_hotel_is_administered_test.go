@@ -0,0 +1,163 @@
+package specs
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/client"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+	"github.com/willsams/go-hotel-reservation-service/store/seed"
+)
+
+var _ = ginkgo.Describe("When the hotel is administered", func() {
+	// administer adds the specs once for each way hotelctl connects.
+	administer := func(body func(c func() *client.Client)) {
+		var c *client.Client
+		clientFunc := func() *client.Client { return c }
+
+		ginkgo.Context("on the database", func() {
+			withEachStore(func(repositories func() store.Repositories) {
+				ginkgo.BeforeEach(func() {
+					repos := repositories()
+					loadFixtures(repos, "test")
+					exec, err := client.Local(repos)
+					gomega.Expect(err).To(gomega.BeNil())
+					c = client.New(exec)
+				})
+
+				body(clientFunc)
+			})
+		})
+
+		ginkgo.Context("on a running server", func() {
+			ginkgo.BeforeEach(func() {
+				repos := memory.New().Repositories()
+				loadFixtures(repos, "test")
+				server, err := api.NewServer(api.Settings{}, repos)
+				gomega.Expect(err).To(gomega.BeNil())
+				running := httptest.NewServer(server.Handler)
+				ginkgo.DeferCleanup(running.Close)
+				c = client.New(client.Remote(running.URL+"/api", running.Client()))
+			})
+
+			body(clientFunc)
+		})
+	}
+
+	administer(func(c func() *client.Client) {
+		var ctx context.Context
+
+		ginkgo.BeforeEach(func() {
+			ctx = context.Background()
+		})
+
+		ginkgo.It("lists every room and the rooms free for a stay", func() {
+			rooms, err := c().Rooms(ctx)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(rooms).To(gomega.HaveLen(4))
+			gomega.Expect(rooms[0].ID).To(gomega.Equal("101"))
+
+			available, err := c().AvailableRooms(ctx, "2023-03-02", "2023-03-04", 2, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(available).To(gomega.HaveLen(1))
+			gomega.Expect(available[0].ID).To(gomega.Equal("103"))
+			gomega.Expect(available[0].TotalCharge).To(gomega.Equal(315.0))
+		})
+
+		ginkgo.It("creates, modifies and cancels reservations with the checks of the API", func() {
+			reservation, err := c().CreateReservation(ctx, "103", "2023-03-10", "2023-03-12", 315)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservation.Status).To(gomega.Equal(api.ReservationConfirmed))
+
+			charge := 300.0
+			reservation, err = c().UpdateReservation(ctx, reservation.ID, client.ReservationChanges{CheckoutDate: "2023-03-13", TotalCharge: &charge})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservation.CheckoutDate).To(gomega.Equal("2023-03-13"))
+			gomega.Expect(reservation.TotalCharge).To(gomega.Equal(300.0))
+
+			_, err = c().CreateReservation(ctx, "103", "2023-03-12", "2023-03-14", 315)
+			gomega.Expect(err).To(gomega.MatchError(api.ErrConflict))
+
+			reservation, err = c().CancelReservation(ctx, reservation.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservation.Status).To(gomega.Equal(api.ReservationCancelled))
+
+			_, err = c().Reservation(ctx, "999999")
+			gomega.Expect(err).To(gomega.MatchError(api.ErrNotFound))
+		})
+
+		ginkgo.It("blocks a room until the block is cancelled", func() {
+			block, err := c().BlockRoom(ctx, "103", "2023-03-01", "2023-03-08")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(block.Status).To(gomega.Equal(api.ReservationBlocked))
+			gomega.Expect(block.TotalCharge).To(gomega.BeZero())
+
+			available, err := c().AvailableRooms(ctx, "2023-03-02", "2023-03-04", 2, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(available).To(gomega.BeEmpty())
+			_, err = c().BlockRoom(ctx, "101", "2023-03-01", "2023-03-08")
+			gomega.Expect(err).To(gomega.MatchError(api.ErrConflict))
+
+			blocks, err := c().Reservations(ctx, client.ReservationFilter{Statuses: []string{api.ReservationBlocked}})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(blocks).To(gomega.Equal([]api.Reservation{block}))
+
+			_, err = c().CancelReservation(ctx, block.ID)
+			gomega.Expect(err).To(gomega.BeNil())
+			available, err = c().AvailableRooms(ctx, "2023-03-02", "2023-03-04", 2, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(available).To(gomega.HaveLen(1))
+		})
+
+		ginkgo.It("changes the rates of a room", func() {
+			room, err := c().SetRoomRates(ctx, "103", 200, 20)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.DailyRate).To(gomega.Equal(200.0))
+			gomega.Expect(room.CleaningFee).To(gomega.Equal(20.0))
+
+			available, err := c().AvailableRooms(ctx, "2023-03-02", "2023-03-04", 2, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(available[0].TotalCharge).To(gomega.Equal(420.0))
+
+			_, err = c().SetRoomRates(ctx, "999", 200, 20)
+			gomega.Expect(err).To(gomega.MatchError(api.ErrNotFound))
+		})
+
+		ginkgo.It("keeps rates to whole amounts, which every store can hold", func() {
+			before, err := c().Rooms(ctx)
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = c().SetRoomRates(ctx, "103", 99.5, 20)
+			gomega.Expect(err).To(gomega.MatchError(api.ErrInvalidInput))
+			_, err = c().SetRoomRates(ctx, "103", 100, 0.25)
+			gomega.Expect(err).To(gomega.MatchError(api.ErrInvalidInput))
+
+			after, err := c().Rooms(ctx)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(after).To(gomega.Equal(before))
+		})
+
+		ginkgo.It("exports every room and reservation as a seed set", func() {
+			rooms, err := c().Rooms(ctx)
+			gomega.Expect(err).To(gomega.BeNil())
+			reservations, err := c().Reservations(ctx, client.ReservationFilter{})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(reservations).To(gomega.HaveLen(3))
+
+			dir := filepath.Join(ginkgo.GinkgoT().TempDir(), "export")
+			gomega.Expect(seed.Write(dir, seed.Set{Rooms: rooms, Reservations: reservations}, "csv")).To(gomega.Succeed())
+			set, err := seed.Read(os.DirFS(dir), ".")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(set.Rooms).To(gomega.Equal(rooms))
+			gomega.Expect(set.Reservations).To(gomega.HaveLen(3))
+			gomega.Expect(set.Validate()).To(gomega.Succeed())
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When the REST API is used", func() {
+	var handler api.LambdaHandler
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		handler, err = api.NewGraphQlApiHandler(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+	})
+
+	send := func(method string, path string, query map[string]string, body string) (events.APIGatewayProxyResponse, map[string]interface{}) {
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod:            method,
+			Path:                  path,
+			Headers:               map[string]string{"Content-Type": "application/json"},
+			QueryStringParameters: query,
+			Body:                  body,
+		})
+		gomega.Expect(err).To(gomega.BeNil())
+
+		var decoded map[string]interface{}
+		json.Unmarshal([]byte(response.Body), &decoded)
+		return response, decoded
+	}
+	errorCode := func(body map[string]interface{}) interface{} {
+		return body["error"].(map[string]interface{})["code"]
+	}
+
+	ginkgo.It("serves its OpenAPI document", func() {
+		response, _ := send(http.MethodGet, "/openapi.yaml", nil, "")
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(response.Body).To(gomega.HavePrefix("openapi: 3."))
+	})
+
+	ginkgo.It("rejects invalid stays with the same validation as GraphQL", func() {
+		response, body := send(http.MethodGet, "/rooms/available", map[string]string{
+			"startDate": "2023-03-05", "endDate": "2023-03-01",
+		}, "")
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		gomega.Expect(errorCode(body)).To(gomega.Equal("BAD_USER_INPUT"))
+		gomega.Expect(body["error"].(map[string]interface{})["message"]).To(gomega.ContainSubstring("must be after"))
+
+		response, _ = send(http.MethodPost, "/reservations", nil,
+			`{"roomId": "101", "checkinDate": "2023-01-10", "checkoutDate": "2023-01-12", "totalCharge": 210}`)
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+	})
+
+	ginkgo.It("rejects unknown fields, routes and methods", func() {
+		response, _ := send(http.MethodPost, "/reservations", nil, `{"room": "101"}`)
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+
+		response, body := send(http.MethodGet, "/reservations/not-a-number", nil, "")
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		gomega.Expect(errorCode(body)).To(gomega.Equal("NOT_FOUND"))
+
+		response, _ = send(http.MethodPut, "/reservations/1", nil, "{}")
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusMethodNotAllowed))
+		gomega.Expect(response.Headers["Allow"]).To(gomega.Equal("GET, PATCH, DELETE"))
+	})
+
+	ginkgo.Context("with rooms", func() {
+		withEachStore(func(repositories func() store.Repositories) {
+			ginkgo.BeforeEach(func() {
+				repos := repositories()
+
+				var err error
+				handler, err = api.NewGraphQlApiHandler(api.Settings{}, repos)
+				gomega.Expect(err).To(gomega.BeNil())
+
+				saveRooms(repos,
+					api.Room{ID: "101", NumBeds: 1, AllowSmoking: false, DailyRate: 100.0, CleaningFee: 10.0},
+					api.Room{ID: "102", NumBeds: 1, AllowSmoking: false, DailyRate: 120.0, CleaningFee: 10.0},
+				)
+			})
+
+			ginkgo.It("creates, reads, moves and cancels a reservation", func() {
+				response, created := send(http.MethodPost, "/reservations", nil,
+					`{"roomId": "101", "checkinDate": "2023-03-02", "checkoutDate": "2023-03-05", "totalCharge": 310}`)
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusCreated))
+				gomega.Expect(created["status"]).To(gomega.Equal("confirmed"))
+				location := response.Headers["Location"]
+				gomega.Expect(location).To(gomega.Equal("/reservations/" + created["id"].(string)))
+
+				response, _ = send(http.MethodPost, "/reservations", nil,
+					`{"roomId": "101", "checkinDate": "2023-03-04", "checkoutDate": "2023-03-06", "totalCharge": 210}`)
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusConflict))
+
+				response, fetched := send(http.MethodGet, location, nil, "")
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+				gomega.Expect(fetched).To(gomega.Equal(created))
+
+				response, moved := send(http.MethodPatch, location, nil, `{"roomId": "102"}`)
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+				gomega.Expect(moved["roomId"]).To(gomega.Equal("102"))
+				gomega.Expect(moved["checkinDate"]).To(gomega.Equal("2023-03-02"))
+
+				_, rooms := send(http.MethodGet, "/reservations", map[string]string{"roomId": "102"}, "")
+				gomega.Expect(rooms["totalCount"]).To(gomega.BeEquivalentTo(1))
+
+				response, cancelled := send(http.MethodDelete, location, nil, "")
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+				gomega.Expect(cancelled["status"]).To(gomega.Equal("cancelled"))
+
+				response, _ = send(http.MethodPatch, location, nil, `{"totalCharge": 100}`)
+				gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusConflict))
+			})
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package specs
+
+import (
+	"os"
+	"strings"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When the schema changes", func() {
+	var snapshot, current string
+
+	ginkgo.BeforeEach(func() {
+		committed, err := os.ReadFile("../schema.graphql")
+		gomega.Expect(err).To(gomega.BeNil())
+		snapshot = string(committed)
+
+		schema, err := api.AppSchema(memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		current = api.PrintSchema(schema)
+	})
+
+	ginkgo.It("has no breaking changes against the committed snapshot", func() {
+		changes, err := api.BreakingChanges(snapshot, current)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(changes).To(gomega.BeEmpty(),
+			"breaking schema changes:\n  %s\nif they are intended, run `make schema ALLOW_BREAKING=1`",
+			strings.Join(changes, "\n  "))
+	})
+
+	ginkgo.It("matches the committed snapshot", func() {
+		gomega.Expect(current).To(gomega.Equal(snapshot), "schema.graphql is out of date, run `make schema`")
+	})
+
+	ginkgo.It("reports removed fields and changed nullability as breaking", func() {
+		previous := `
+type Query { room(id: ID!): Room, rooms: [Room!]! }
+type Room { id: ID!, numBeds: Int, dailyRate: Float! }
+input Filter { numBeds: Int }`
+		next := `
+type Query { room(id: ID!, date: String!): Room!, rooms: [Room] }
+type Room { id: ID!, numBeds: Int! }
+input Filter { numBeds: Int!, smoking: Boolean }`
+
+		changes, err := api.BreakingChanges(previous, next)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(changes).To(gomega.ConsistOf(
+			"Filter field numBeds changed type from Int to Int!",
+			"Query.room has a new required argument date",
+			"Query.rooms changed type from [Room!]! to [Room]",
+			"Room.dailyRate was removed",
+		))
+	})
+})
@@ -0,0 +1,123 @@
+package specs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+	"github.com/willsams/go-hotel-reservation-service/store/sqlite"
+)
+
+var _ = ginkgo.Describe("When the server is probed", func() {
+	const token = "0123456789abcdef0123"
+	database := config.Database{Driver: config.DriverSQLite, Path: ":memory:"}
+	var settings api.Settings
+
+	ginkgo.BeforeEach(func() {
+		settings = api.Settings{Config: config.Config{API: config.API{DiagnosticsToken: token}}}
+	})
+
+	get := func(repos store.Repositories, path string, header http.Header) (int, map[string]interface{}) {
+		server, err := api.NewServer(settings, repos)
+		gomega.Expect(err).To(gomega.BeNil())
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		response := httptest.NewRecorder()
+		server.Handler.ServeHTTP(response, request)
+
+		var body map[string]interface{}
+		if response.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+			gomega.Expect(json.Unmarshal(response.Body.Bytes(), &body)).To(gomega.Succeed(), response.Body.String())
+		}
+		return response.Code, body
+	}
+
+	ginkgo.It("is alive without checking the database", func() {
+		db, err := sqlite.Open(database)
+		gomega.Expect(err).To(gomega.BeNil())
+		db.Close()
+
+		status, body := get(db.Repositories(), "/livez", nil)
+		gomega.Expect(status).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(body).To(gomega.HaveKeyWithValue("status", "ok"))
+	})
+
+	ginkgo.It("is ready once the database answers and is migrated", func() {
+		db, err := sqlite.Open(database)
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+
+		status, body := get(db.Repositories(), "/readyz", nil)
+		gomega.Expect(status).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(body).To(gomega.HaveKeyWithValue("status", "ready"))
+		checks := body["checks"].(map[string]interface{})
+		gomega.Expect(checks["database"]).To(gomega.HaveKeyWithValue("status", "ok"))
+		migrations := checks["migrations"].(map[string]interface{})
+		gomega.Expect(migrations).To(gomega.HaveKeyWithValue("status", "ok"))
+		gomega.Expect(migrations["version"]).To(gomega.Equal(float64(db.Migrator().Latest())))
+	})
+
+	ginkgo.It("is not ready while migrations are pending", func() {
+		db, err := sqlite.Connect(database)
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+
+		status, body := get(db.Repositories(), "/readyz", nil)
+		gomega.Expect(status).To(gomega.Equal(http.StatusServiceUnavailable))
+		gomega.Expect(body).To(gomega.HaveKeyWithValue("status", "unavailable"))
+		gomega.Expect(body["checks"]).To(gomega.HaveKeyWithValue("migrations", gomega.HaveKeyWithValue("status", "pending")))
+	})
+
+	ginkgo.It("is not ready when the database does not answer", func() {
+		db, err := sqlite.Open(database)
+		gomega.Expect(err).To(gomega.BeNil())
+		db.Close()
+
+		status, body := get(db.Repositories(), "/readyz", nil)
+		gomega.Expect(status).To(gomega.Equal(http.StatusServiceUnavailable))
+		gomega.Expect(body["checks"]).To(gomega.HaveKeyWithValue("database", gomega.HaveKeyWithValue("status", "failed")))
+	})
+
+	ginkgo.It("reports diagnostics only to the bearer of the token", func() {
+		status, _ := get(memory.New().Repositories(), "/diagnostics", nil)
+		gomega.Expect(status).To(gomega.Equal(http.StatusUnauthorized))
+		status, _ = get(memory.New().Repositories(), "/diagnostics", http.Header{"Authorization": {"Bearer wrong"}})
+		gomega.Expect(status).To(gomega.Equal(http.StatusUnauthorized))
+
+		server, err := api.NewServer(api.Settings{}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/diagnostics", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		server.Handler.ServeHTTP(response, request)
+		gomega.Expect(response.Code).To(gomega.Equal(http.StatusNotFound))
+	})
+
+	ginkgo.It("reports the build, the configuration without secrets and the pool", func() {
+		cfg := config.Defaults(config.Test)
+		cfg.Database.Password = "hunter2"
+		cfg.API.DiagnosticsToken = token
+		var err error
+		settings, err = api.NewSettings(cfg)
+		gomega.Expect(err).To(gomega.BeNil())
+		db, err := sqlite.Open(database)
+		gomega.Expect(err).To(gomega.BeNil())
+		ginkgo.DeferCleanup(db.Close)
+
+		status, body := get(db.Repositories(), "/diagnostics", http.Header{"Authorization": {"Bearer " + token}})
+		gomega.Expect(status).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(body["build"]).To(gomega.HaveKey("goVersion"))
+		gomega.Expect(body["config"]).To(gomega.HaveKeyWithValue("database", gomega.HaveKeyWithValue("password", "[redacted]")))
+		gomega.Expect(body["config"]).To(gomega.HaveKeyWithValue("api", gomega.HaveKeyWithValue("diagnosticsToken", "[redacted]")))
+		gomega.Expect(body["pool"]).To(gomega.HaveKeyWithValue("maxOpenConnections", 1.0))
+	})
+})
@@ -0,0 +1,125 @@
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/v2"
+	"github.com/onsi/gomega"
+
+	"github.com/willsams/go-hotel-reservation-service/api"
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store/memory"
+)
+
+var _ = ginkgo.Describe("When the server is started", func() {
+	var server *http.Server
+	var listener *httptest.Server
+
+	ginkgo.BeforeEach(func() {
+		var err error
+		server, err = api.NewServer(api.Settings{Config: testConfig}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+		listener = httptest.NewServer(server.Handler)
+	})
+
+	ginkgo.AfterEach(func() {
+		listener.Close()
+	})
+
+	ginkgo.It("sets read and write timeouts", func() {
+		gomega.Expect(server.ReadHeaderTimeout).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(server.ReadTimeout).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(server.WriteTimeout).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(server.IdleTimeout).To(gomega.BeNumerically(">", 0))
+	})
+
+	ginkgo.It("reports that it is healthy", func() {
+		response, err := http.Get(listener.URL + "/healthz")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+
+		var body map[string]string
+		gomega.Expect(json.NewDecoder(response.Body).Decode(&body)).To(gomega.Succeed())
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(body).To(gomega.Equal(map[string]string{"status": "ok"}))
+	})
+
+	ginkgo.It("publishes request metrics", func() {
+		healthz, err := http.Get(listener.URL + "/healthz")
+		gomega.Expect(err).To(gomega.BeNil())
+		healthz.Body.Close()
+
+		response, err := http.Get(listener.URL + "/metrics")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+
+		metrics, err := io.ReadAll(response.Body)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(response.Header.Get("Content-Type")).To(gomega.HavePrefix("text/plain"))
+		gomega.Expect(string(metrics)).To(gomega.ContainSubstring(`hotel_http_requests_total{code="200"}`))
+		gomega.Expect(string(metrics)).To(gomega.ContainSubstring("hotel_http_requests_in_flight"))
+	})
+
+	ginkgo.It("serves a playground page that sends its queries to /api", func() {
+		response, err := http.Get(listener.URL + "/playground")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+
+		page, err := io.ReadAll(response.Body)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+		gomega.Expect(response.Header.Get("Content-Type")).To(gomega.HavePrefix("text/html"))
+		gomega.Expect(string(page)).To(gomega.ContainSubstring("endpoint: '/api'"))
+	})
+
+	ginkgo.It("does not answer queries at the playground", func() {
+		response, err := http.Post(listener.URL+"/playground", "application/json",
+			strings.NewReader(`{"query": "{ rooms { edges { node { id } } } }"}`))
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusMethodNotAllowed))
+		gomega.Expect(string(body)).NotTo(gomega.ContainSubstring("rooms"))
+	})
+
+	ginkgo.It("does not serve the playground in production", func() {
+		production := testConfig
+		production.Env = config.Production
+		server, err := api.NewServer(api.Settings{Config: production}, memory.New().Repositories())
+		gomega.Expect(err).To(gomega.BeNil())
+
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/playground", nil))
+		gomega.Expect(recorder.Code).To(gomega.Equal(http.StatusNotFound))
+	})
+
+	ginkgo.It("does not serve routes registered on the default mux", func() {
+		http.HandleFunc("/default-mux-only", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "default mux")
+		})
+
+		response, err := http.Get(listener.URL + "/default-mux-only")
+		gomega.Expect(err).To(gomega.BeNil())
+		defer response.Body.Close()
+		gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+	})
+
+	ginkgo.It("stops when its context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- api.Serve(ctx, api.Settings{Config: config.Config{API: config.API{ShutdownTimeout: config.Duration{Duration: time.Second}}}}, memory.New().Repositories())
+		}()
+
+		cancel()
+		gomega.Eventually(stopped).Should(gomega.Receive(gomega.BeNil()))
+	})
+})
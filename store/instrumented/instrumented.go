@@ -0,0 +1,94 @@
+// Package instrumented wraps the connection pool of the SQL stores so that
+// every statement they run is logged and traced under the request that ran
+// it.
+package instrumented
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+
+	"github.com/willsams/go-hotel-reservation-service/logging"
+	"github.com/willsams/go-hotel-reservation-service/tracing"
+)
+
+// DB is a connection pool that logs the statements run through it, at
+// debug level, and records a span for each. Arguments are neither logged
+// nor traced: they hold guests' data.
+type DB struct {
+	*sqlx.DB
+	system attribute.KeyValue
+}
+
+func New(db *sqlx.DB) DB {
+	system := semconv.DBSystemKey.String(db.DriverName())
+	switch db.DriverName() {
+	case "postgres":
+		system = semconv.DBSystemPostgreSQL
+	case "sqlite3":
+		system = semconv.DBSystemSqlite
+	}
+	return DB{DB: db, system: system}
+}
+
+func (db DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, done := db.start(ctx, query)
+	err := db.DB.SelectContext(ctx, dest, query, args...)
+	done(err)
+	return err
+}
+
+func (db DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, done := db.start(ctx, query)
+	err := db.DB.GetContext(ctx, dest, query, args...)
+	done(err)
+	return err
+}
+
+func (db DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, done := db.start(ctx, query)
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+// start opens the span of query and returns the function that ends it and
+// logs the statement.
+func (db DB) start(ctx context.Context, query string) (context.Context, func(error)) {
+	statement := strings.Join(strings.Fields(query), " ")
+	verb, _, _ := strings.Cut(statement, " ")
+	ctx, span := tracing.Tracer().Start(ctx, strings.ToUpper(verb),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(db.system, semconv.DBStatement(statement)))
+	start := time.Now()
+
+	return ctx, func(err error) {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		logger := logging.FromContext(ctx)
+		if !logger.Enabled(ctx, slog.LevelDebug) {
+			return
+		}
+		attrs := []interface{}{
+			"statement", statement,
+			"durationMs", logging.Milliseconds(time.Since(start)),
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err.Error())
+		}
+		logger.Log(ctx, slog.LevelDebug, "sql", attrs...)
+	}
+}
@@ -0,0 +1,309 @@
+// Package memory keeps rooms and reservations in memory, with the same
+// semantics as the postgres package. It suits tests and demonstrations.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+// Store implements the room and reservation repositories in memory. It is
+// safe for concurrent use.
+type Store struct {
+	mu           sync.RWMutex
+	rooms        map[string]store.Room
+	reservations map[int]store.Reservation
+	lastID       int
+}
+
+func New() *Store {
+	return &Store{rooms: map[string]store.Room{}, reservations: map[int]store.Reservation{}}
+}
+
+// Repositories returns the store as both repositories.
+func (s *Store) Repositories() store.Repositories {
+	return store.Repositories{Rooms: s, Reservations: s}
+}
+
+func (s *Store) AvailableRooms(ctx context.Context, query store.AvailabilityQuery) ([]store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Like the SQL, a room is taken when one reservation spans both dates.
+	taken := map[string]bool{}
+	for _, reservation := range s.reservations {
+		if reservation.Status != store.ReservationCancelled &&
+			between(query.End, reservation.CheckinDate, reservation.CheckoutDate) &&
+			between(query.Start, reservation.CheckinDate, reservation.CheckoutDate) {
+			taken[reservation.RoomID] = true
+		}
+	}
+
+	var rooms []store.Room
+	for _, room := range s.rooms {
+		if room.AllowSmoking != query.AllowSmoking || room.NumBeds < query.NumBeds || taken[room.ID] {
+			continue
+		}
+		room.TotalCharge = room.DailyRate*float64(query.Nights) + room.CleaningFee
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].TotalCharge != rooms[j].TotalCharge {
+			return rooms[i].TotalCharge < rooms[j].TotalCharge
+		}
+		if rooms[i].NumBeds != rooms[j].NumBeds {
+			return rooms[i].NumBeds < rooms[j].NumBeds
+		}
+		return rooms[i].ID < rooms[j].ID
+	})
+	return rooms, nil
+}
+
+func between(date string, first string, last string) bool {
+	return first <= date && date <= last
+}
+
+func (s *Store) Rooms(ctx context.Context, ids []string) ([]store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rooms []store.Room
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if room, ok := s.rooms[id]; ok && !seen[id] {
+			seen[id] = true
+			room.TotalCharge = 0
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (s *Store) ListRooms(ctx context.Context) ([]store.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rooms := make([]store.Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		room.TotalCharge = 0
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms, nil
+}
+
+func (s *Store) SaveRoom(ctx context.Context, room store.Room) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room.TotalCharge = 0
+	s.rooms[room.ID] = room
+	return nil
+}
+
+func (s *Store) Reservation(ctx context.Context, id string) (store.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, err := strconv.Atoi(id)
+	reservation, ok := s.reservations[key]
+	if err != nil || !ok {
+		return store.Reservation{}, fmt.Errorf("%w: reservation %s", store.ErrNotFound, id)
+	}
+	return reservation, nil
+}
+
+func matches(filter store.ReservationFilter, reservation store.Reservation) bool {
+	if len(filter.RoomIDs) > 0 && !contains(filter.RoomIDs, reservation.RoomID) {
+		return false
+	}
+	if filter.OverlapStart != "" && filter.OverlapEnd != "" &&
+		!(reservation.CheckinDate < filter.OverlapEnd && reservation.CheckoutDate > filter.OverlapStart) {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !contains(filter.Statuses, reservation.Status) {
+		return false
+	}
+	if filter.GuestID != "" && reservation.GuestID != filter.GuestID {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders a and b by field and then by id, comparing numbers as
+// numbers and dates as text, the way the Postgres columns do.
+func compare(field string, a store.Reservation, b store.Reservation) int {
+	var order int
+	switch field {
+	case store.SortByCheckinDate:
+		order = compareStrings(a.CheckinDate, b.CheckinDate)
+	case store.SortByCheckoutDate:
+		order = compareStrings(a.CheckoutDate, b.CheckoutDate)
+	case store.SortByTotalCharge:
+		order = compareFloats(a.TotalCharge, b.TotalCharge)
+	}
+	if order != 0 {
+		return order
+	}
+	aID, _ := strconv.Atoi(a.ID)
+	bID, _ := strconv.Atoi(b.ID)
+	return compareFloats(float64(aID), float64(bID))
+}
+
+func compareStrings(a string, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// positioned is a reservation standing in for a Position, so that it can
+// be compared with compare.
+func positioned(field string, position store.Position) store.Reservation {
+	reservation := store.Reservation{ID: position.ID}
+	switch field {
+	case store.SortByCheckinDate:
+		reservation.CheckinDate = position.Value
+	case store.SortByCheckoutDate:
+		reservation.CheckoutDate = position.Value
+	case store.SortByTotalCharge:
+		reservation.TotalCharge, _ = strconv.ParseFloat(position.Value, 64)
+	}
+	return reservation
+}
+
+func (s *Store) ListReservations(ctx context.Context, query store.ReservationQuery) ([]store.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+
+	reservations := []store.Reservation{}
+	for _, reservation := range s.reservations {
+		if !matches(query.ReservationFilter, reservation) {
+			continue
+		}
+		if query.After != nil && direction*compare(query.SortField, reservation, positioned(query.SortField, *query.After)) <= 0 {
+			continue
+		}
+		reservations = append(reservations, reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return direction*compare(query.SortField, reservations[i], reservations[j]) < 0
+	})
+	if query.Limit > 0 && len(reservations) > query.Limit {
+		reservations = reservations[:query.Limit]
+	}
+	return reservations, nil
+}
+
+func (s *Store) CountReservations(ctx context.Context, filter store.ReservationFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, reservation := range s.reservations {
+		if matches(filter, reservation) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) IsRoomAvailable(ctx context.Context, roomID string, checkinDate string, checkoutDate string, exceptID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.reservations {
+		if r.RoomID != roomID || r.Status == store.ReservationCancelled || r.ID == exceptID {
+			continue
+		}
+		if (r.CheckinDate >= checkinDate && r.CheckinDate < checkoutDate) ||
+			(r.CheckoutDate > checkinDate && r.CheckoutDate <= checkoutDate) ||
+			(r.CheckinDate <= checkinDate && r.CheckoutDate >= checkoutDate) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *Store) CreateReservation(ctx context.Context, reservation store.Reservation) (store.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reservation.Status == "" {
+		reservation.Status = store.ReservationConfirmed
+	}
+	if err := s.checkConstraints(reservation); err != nil {
+		return reservation, err
+	}
+	s.lastID++
+	reservation.ID = strconv.Itoa(s.lastID)
+	s.reservations[s.lastID] = reservation
+	return reservation, nil
+}
+
+func (s *Store) UpdateReservation(ctx context.Context, reservation store.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := strconv.Atoi(reservation.ID)
+	if _, ok := s.reservations[key]; err != nil || !ok {
+		return fmt.Errorf("%w: reservation %s", store.ErrNotFound, reservation.ID)
+	}
+	if err := s.checkConstraints(reservation); err != nil {
+		return err
+	}
+	s.reservations[key] = reservation
+	return nil
+}
+
+// checkConstraints enforces what the Postgres schema does: the room must
+// exist, and no two reservations that are not cancelled may have the same
+// room and dates.
+func (s *Store) checkConstraints(reservation store.Reservation) error {
+	if _, ok := s.rooms[reservation.RoomID]; !ok {
+		return fmt.Errorf("%w: room %s", store.ErrNotFound, reservation.RoomID)
+	}
+	if reservation.Status == store.ReservationCancelled {
+		return nil
+	}
+	for _, other := range s.reservations {
+		if other.ID != reservation.ID && other.Status != store.ReservationCancelled && other.RoomID == reservation.RoomID &&
+			other.CheckinDate == reservation.CheckinDate && other.CheckoutDate == reservation.CheckoutDate {
+			return fmt.Errorf("%w: room %s is already reserved from %s to %s",
+				store.ErrConflict, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+// Package migrate applies and rolls back the SQL migrations embedded in a
+// binary, recording the applied versions in the schema_migrations table.
+//
+// A migration is a pair of files, VERSION_name.up.sql and
+// VERSION_name.down.sql, where VERSION orders the migrations. Each one runs
+// in its own transaction.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration is one step of the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// AheadError is returned when the database has migrations this binary does
+// not know, so it was migrated by a newer release.
+type AheadError struct {
+	Version int64
+	Latest  int64
+}
+
+func (e *AheadError) Error() string {
+	return fmt.Sprintf("the database is at version %d, ahead of this binary's latest migration %d; run a newer release", e.Version, e.Latest)
+}
+
+// ErrUnknownVersion is returned when migrating to a version that is neither
+// 0 nor one of the migrations.
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+// Load reads the migrations in dir of fsys, in version order.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, file := range files {
+		base := path.Base(file)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s is neither .up.sql nor .down.sql", file)
+		}
+		name := strings.TrimSuffix(base, "."+direction+".sql")
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s does not start with a version number", file)
+		}
+
+		statements, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m.Name, name, version)
+		}
+		if direction == "up" {
+			m.Up = string(statements)
+		} else {
+			m.Down = string(statements)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no .up.sql", m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MustLoad is Load for migrations embedded in the binary, which are known
+// to be well formed; it panics when they are not.
+func MustLoad(fsys fs.FS, dir string) []Migration {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
+
+// Migrator migrates one database.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+
+	// Adopt, when set, returns the versions an earlier migration tool has
+	// already applied. They are recorded the first time the migrator finds
+	// none of its versions recorded, so that an existing schema is not
+	// created again. Adopt runs in the transaction that records them, which
+	// it may also use to clear what the earlier tool recorded.
+	Adopt func(ctx context.Context, tx *sqlx.Tx) ([]int64, error)
+}
+
+func New(db *sqlx.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest is the version of the last migration known to the binary, or 0.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version is the version of the last migration applied to the database, or 0.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var version int64
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Status lists the migrations known to the binary and whether each is
+// applied. It also reports an *AheadError, with the list, when the
+// database is ahead of the binary.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, m.checkAhead(applied)
+}
+
+// Up applies every pending migration and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the last applied migration and returns it, or nothing
+// when no migration is applied.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil || version == 0 {
+		return nil, err
+	}
+	previous := int64(0)
+	for _, migration := range m.migrations {
+		if migration.Version < version {
+			previous = migration.Version
+		}
+	}
+	return m.To(ctx, previous)
+}
+
+// To applies or rolls back migrations until version, 0 for an empty schema,
+// is the last one applied, and returns those it ran in the order it ran
+// them. Migrations missing below version are applied too.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && m.find(version) < 0 {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkAhead(applied); err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+			continue
+		}
+		if err := m.run(ctx, migration, false); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		if err := m.run(ctx, migration, true); err != nil {
+			return ran, err
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+func (m *Migrator) find(version int64) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Migrator) checkAhead(applied map[int64]time.Time) error {
+	for version := range applied {
+		if m.find(version) < 0 && version > m.Latest() {
+			return &AheadError{Version: version, Latest: m.Latest()}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, migration Migration, up bool) error {
+	statements := migration.Up
+	record := m.db.Rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)")
+	args := []interface{}{migration.Version, migration.Name}
+	if !up {
+		if migration.Down == "" {
+			return fmt.Errorf("migration %s cannot be rolled back: it has no .down.sql", migration.Name)
+		}
+		statements = migration.Down
+		record = m.db.Rebind("DELETE FROM schema_migrations WHERE version = ?")
+		args = args[:1]
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return fmt.Errorf("%s: %w", migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied returns when each recorded version was applied, creating the
+// schema_migrations table and adopting earlier versions when needed.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.db.SelectContext(ctx, &rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time, len(rows))
+	known := false
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+		known = known || m.find(row.Version) >= 0
+	}
+	if !known && m.Adopt != nil {
+		adopted, err := m.adopt(ctx)
+		if err != nil || !adopted {
+			return applied, err
+		}
+		return m.applied(ctx)
+	}
+	return applied, nil
+}
+
+// adopt records the versions Adopt returns, reporting whether it recorded
+// any.
+func (m *Migrator) adopt(ctx context.Context) (bool, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	versions, err := m.Adopt(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+	adopted := false
+	for _, version := range versions {
+		i := m.find(version)
+		if i < 0 {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"),
+			version, m.migrations[i].Name)
+		if err != nil {
+			return false, err
+		}
+		adopted = true
+	}
+	if !adopted {
+		return false, nil
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// TakeToken implements ratelimit.Store with a single upsert, so that the
+// instances sharing the database take from the same buckets. Times are
+// stored as Unix seconds; a clock behind the one that last refilled a
+// bucket adds nothing to it.
+func (s *Store) TakeToken(ctx context.Context, key string, rate float64, burst float64, now time.Time) (float64, bool, error) {
+	var bucket struct {
+		Tokens float64 `db:"tokens"`
+		Taken  bool    `db:"taken"`
+	}
+	err := s.db.GetContext(ctx, &bucket, `
+		INSERT INTO rate_limit_buckets AS b (bucket, tokens, updated_at, taken)
+		VALUES ($1, $2::float8 - 1, $3::float8, true)
+		ON CONFLICT (bucket) DO UPDATE SET
+			tokens = least($2::float8, b.tokens + greatest(0, $3::float8 - b.updated_at) * $4::float8)
+				- CASE WHEN least($2::float8, b.tokens + greatest(0, $3::float8 - b.updated_at) * $4::float8) >= 1 THEN 1 ELSE 0 END,
+			taken = least($2::float8, b.tokens + greatest(0, $3::float8 - b.updated_at) * $4::float8) >= 1,
+			updated_at = greatest(b.updated_at, $3::float8)
+		RETURNING tokens, taken
+	`, key, burst, unixSeconds(now), rate)
+	return bucket.Tokens, bucket.Taken, err
+}
+
+// SweepBuckets implements ratelimit.Sweeper.
+func (s *Store) SweepBuckets(ctx context.Context, idleSince time.Time) error {
+	_, err := s.db.ExecContext(ctx, "delete from rate_limit_buckets where updated_at < $1", unixSeconds(idleSince))
+	return err
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/store/migrate"
+)
+
+// migrations hold the schema as VERSION_name.up.sql and .down.sql files.
+// The versions are those of the knex migrations they replace.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrator migrates the database of the store. A database migrated by knex
+// is adopted: the versions in knex_migrations count as applied.
+func (s *Store) Migrator() *migrate.Migrator {
+	m := migrate.New(s.db.DB, migrate.MustLoad(migrations, "migrations"))
+	m.Adopt = adoptKnex
+	return m
+}
+
+// adoptKnex returns the versions recorded by knex, whose migration names
+// start with the same VERSION_ as ours.
+func adoptKnex(ctx context.Context, tx *sqlx.Tx) ([]int64, error) {
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, "select to_regclass('knex_migrations') is not null"); err != nil || !exists {
+		return nil, err
+	}
+	var names []string
+	if err := tx.SelectContext(ctx, &names, "select name from knex_migrations"); err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(names))
+	for _, name := range names {
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
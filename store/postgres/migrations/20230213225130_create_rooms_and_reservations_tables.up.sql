@@ -0,0 +1,19 @@
+CREATE TABLE rooms (
+	id varchar(255) NOT NULL,
+	num_beds integer,
+	allow_smoking boolean,
+	daily_rate integer,
+	cleaning_fee integer,
+	CONSTRAINT rooms_pkey PRIMARY KEY (id),
+	CONSTRAINT rooms_id_unique UNIQUE (id)
+);
+
+CREATE TABLE reservations (
+	id serial PRIMARY KEY,
+	room_id varchar(255),
+	checkin_date varchar(255),
+	checkout_date varchar(255),
+	total_charge integer,
+	CONSTRAINT reservations_room_id_foreign FOREIGN KEY (room_id) REFERENCES rooms (id),
+	CONSTRAINT reservations_room_id_checkin_date_checkout_date_unique UNIQUE (room_id, checkin_date, checkout_date)
+);
@@ -0,0 +1,3 @@
+DROP INDEX reservations_guest_id_index;
+
+ALTER TABLE reservations DROP COLUMN guest_id;
@@ -0,0 +1,3 @@
+ALTER TABLE reservations ADD COLUMN guest_id varchar(255) NOT NULL DEFAULT '';
+
+CREATE INDEX reservations_guest_id_index ON reservations (guest_id);
@@ -0,0 +1,4 @@
+DROP INDEX reservations_room_id_checkin_date_checkout_date_unique;
+
+ALTER TABLE reservations ADD CONSTRAINT reservations_room_id_checkin_date_checkout_date_unique
+	UNIQUE (room_id, checkin_date, checkout_date);
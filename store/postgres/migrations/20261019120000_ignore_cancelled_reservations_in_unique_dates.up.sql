@@ -0,0 +1,4 @@
+ALTER TABLE reservations DROP CONSTRAINT reservations_room_id_checkin_date_checkout_date_unique;
+
+CREATE UNIQUE INDEX reservations_room_id_checkin_date_checkout_date_unique
+	ON reservations (room_id, checkin_date, checkout_date) WHERE status <> 'cancelled';
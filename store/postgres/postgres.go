@@ -0,0 +1,275 @@
+// Package postgres stores rooms and reservations in PostgreSQL.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/instrumented"
+)
+
+// pingTimeout bounds the check that the database answers.
+const pingTimeout = 5 * time.Second
+
+// Store implements the room and reservation repositories on a connection pool.
+type Store struct {
+	db instrumented.DB
+}
+
+func New(db *sqlx.DB) *Store {
+	return &Store{db: instrumented.New(db)}
+}
+
+// Open opens the connection pool described by cfg and checks that the
+// database answers. Open it once per process and share it.
+func Open(cfg config.Database) (*Store, error) {
+	db, err := sqlx.Open("postgres", cfg.DataSourceName())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime.Duration)
+
+	s := New(db)
+	if err := s.Ping(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// DB is the connection pool of the store.
+func (s *Store) DB() *sqlx.DB {
+	return s.db.DB
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Repositories returns the store as both repositories.
+func (s *Store) Repositories() store.Repositories {
+	return store.Repositories{Rooms: s, Reservations: s}
+}
+
+// Ping checks that the database answers, replacing connections that went
+// stale while the process was idle or frozen.
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return s.db.PingContext(ctx)
+}
+
+func (s *Store) AvailableRooms(ctx context.Context, query store.AvailabilityQuery) ([]store.Room, error) {
+	var rooms []store.Room
+	err := s.db.SelectContext(ctx, &rooms, `select ro.id, ro.num_beds, ro.allow_smoking, ro.daily_rate, ro.cleaning_fee,
+			ro.daily_rate * $1 + ro.cleaning_fee as total_charge
+		from rooms as ro
+		where ro.allow_smoking = $2
+			and ro.num_beds >= $3
+			and ro.id not in (
+				select room_id
+				from reservations
+				where status <> 'cancelled'
+					and $4 between checkin_date and checkout_date
+					and $5 between checkin_date and checkout_date
+			)
+		order by 6, 2, 1`, query.Nights, query.AllowSmoking, query.NumBeds, query.End, query.Start)
+	return rooms, err
+}
+
+func (s *Store) Rooms(ctx context.Context, ids []string) ([]store.Room, error) {
+	var rooms []store.Room
+	err := s.db.SelectContext(ctx, &rooms, `select id, num_beds, allow_smoking, daily_rate, cleaning_fee
+		from rooms
+		where id = any($1)`, pq.Array(ids))
+	return rooms, err
+}
+
+func (s *Store) ListRooms(ctx context.Context) ([]store.Room, error) {
+	var rooms []store.Room
+	err := s.db.SelectContext(ctx, &rooms, `select id, num_beds, allow_smoking, daily_rate, cleaning_fee
+		from rooms
+		order by id`)
+	return rooms, err
+}
+
+func (s *Store) SaveRoom(ctx context.Context, room store.Room) error {
+	_, err := s.db.ExecContext(ctx, `insert into rooms (id, num_beds, allow_smoking, daily_rate, cleaning_fee)
+		values ($1, $2, $3, $4, $5)
+		on conflict (id) do update
+		set num_beds = excluded.num_beds, allow_smoking = excluded.allow_smoking,
+			daily_rate = excluded.daily_rate, cleaning_fee = excluded.cleaning_fee`,
+		room.ID, room.NumBeds, room.AllowSmoking, room.DailyRate, room.CleaningFee)
+	return err
+}
+
+func (s *Store) Reservation(ctx context.Context, id string) (store.Reservation, error) {
+	var reservation store.Reservation
+	// Ids are serial integers, and Postgres refuses to compare them with anything else.
+	if _, err := strconv.Atoi(id); err != nil {
+		return reservation, fmt.Errorf("%w: reservation %s", store.ErrNotFound, id)
+	}
+	err := s.db.GetContext(ctx, &reservation, `select id, room_id, checkin_date, checkout_date, total_charge, status, guest_id
+		from reservations
+		where id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reservation, fmt.Errorf("%w: reservation %s", store.ErrNotFound, id)
+	}
+	return reservation, err
+}
+
+// sortColumns maps the sortable fields to their columns.
+var sortColumns = map[string]string{
+	store.SortByID:           "id",
+	store.SortByCheckinDate:  "checkin_date",
+	store.SortByCheckoutDate: "checkout_date",
+	store.SortByTotalCharge:  "total_charge",
+}
+
+// where renders the filter as a SQL condition, appending its parameters to args.
+func where(filter store.ReservationFilter, args []interface{}) (string, []interface{}) {
+	conditions := []string{"true"}
+	if len(filter.RoomIDs) > 0 {
+		args = append(args, pq.Array(filter.RoomIDs))
+		conditions = append(conditions, fmt.Sprintf("room_id = any($%d)", len(args)))
+	}
+	if filter.OverlapStart != "" && filter.OverlapEnd != "" {
+		args = append(args, filter.OverlapEnd, filter.OverlapStart)
+		conditions = append(conditions, fmt.Sprintf("checkin_date < $%d and checkout_date > $%d", len(args)-1, len(args)))
+	}
+	if len(filter.Statuses) > 0 {
+		args = append(args, pq.Array(filter.Statuses))
+		conditions = append(conditions, fmt.Sprintf("status = any($%d)", len(args)))
+	}
+	if filter.GuestID != "" {
+		args = append(args, filter.GuestID)
+		conditions = append(conditions, fmt.Sprintf("guest_id = $%d", len(args)))
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func (s *Store) ListReservations(ctx context.Context, query store.ReservationQuery) ([]store.Reservation, error) {
+	condition, args := where(query.ReservationFilter, nil)
+
+	column, ok := sortColumns[query.SortField]
+	if !ok {
+		column = "id"
+	}
+	direction, comparison := "asc", ">"
+	if query.Descending {
+		direction, comparison = "desc", "<"
+	}
+
+	// Keyset pagination: continue strictly after the (sort value, id) of the position.
+	if query.After != nil {
+		if column == "id" {
+			args = append(args, query.After.ID)
+			condition += fmt.Sprintf(" and id %s $%d", comparison, len(args))
+		} else {
+			args = append(args, query.After.Value, query.After.ID)
+			condition += fmt.Sprintf(" and (%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args))
+		}
+	}
+
+	order := "id " + direction
+	if column != "id" {
+		order = fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	}
+
+	statement := fmt.Sprintf(`select id, room_id, checkin_date, checkout_date, total_charge, status, guest_id
+		from reservations
+		where %s
+		order by %s`, condition, order)
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		statement += fmt.Sprintf(" limit $%d", len(args))
+	}
+
+	var reservations []store.Reservation
+	err := s.db.SelectContext(ctx, &reservations, statement, args...)
+	return reservations, err
+}
+
+func (s *Store) CountReservations(ctx context.Context, filter store.ReservationFilter) (int, error) {
+	condition, args := where(filter, nil)
+	var count int
+	err := s.db.GetContext(ctx, &count, "select count(*) from reservations where "+condition, args...)
+	return count, err
+}
+
+func (s *Store) IsRoomAvailable(ctx context.Context, roomID string, checkinDate string, checkoutDate string, exceptID string) (bool, error) {
+	var count int
+	err := s.db.GetContext(ctx, &count, `
+		SELECT COUNT(*)
+		FROM reservations
+		WHERE room_id = $1
+		AND status <> 'cancelled'
+		AND id::text <> $4
+		AND (
+			(checkin_date >= $2 AND checkin_date < $3) OR
+			(checkout_date > $2 AND checkout_date <= $3) OR
+			(checkin_date <= $2 AND checkout_date >= $3)
+		)
+	`, roomID, checkinDate, checkoutDate, exceptID)
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+func (s *Store) CreateReservation(ctx context.Context, reservation store.Reservation) (store.Reservation, error) {
+	if reservation.Status == "" {
+		reservation.Status = store.ReservationConfirmed
+	}
+	err := s.db.GetContext(ctx, &reservation.ID, `
+		INSERT INTO reservations (room_id, checkin_date, checkout_date, total_charge, status, guest_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate, reservation.TotalCharge, reservation.Status, reservation.GuestID)
+	return reservation, constraintError(err, reservation)
+}
+
+func (s *Store) UpdateReservation(ctx context.Context, reservation store.Reservation) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE reservations
+		SET room_id = $2, checkin_date = $3, checkout_date = $4, total_charge = $5, status = $6, guest_id = $7
+		WHERE id::text = $1
+	`, reservation.ID, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate, reservation.TotalCharge, reservation.Status, reservation.GuestID)
+	if err != nil {
+		return constraintError(err, reservation)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf("%w: reservation %s", store.ErrNotFound, reservation.ID)
+	}
+	return nil
+}
+
+// constraintError turns the violations of the reservations constraints into
+// the errors of the repository.
+func constraintError(err error, reservation store.Reservation) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code.Name() {
+	case "foreign_key_violation":
+		return fmt.Errorf("%w: room %s", store.ErrNotFound, reservation.RoomID)
+	case "unique_violation":
+		return fmt.Errorf("%w: room %s is already reserved from %s to %s",
+			store.ErrConflict, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate)
+	}
+	return err
+}
@@ -0,0 +1,387 @@
+// Package seed loads sets of rooms and reservations, the seed data of an
+// environment or the fixtures of the specs, into a store.
+//
+// A set is a directory holding rooms.json or rooms.csv, and optionally
+// reservations.json or reservations.csv. The JSON files are arrays of
+// objects and the CSV files have a header row, both with the column names
+// of the tables: id, num_beds, allow_smoking, daily_rate and cleaning_fee
+// for rooms; room_id, checkin_date, checkout_date, total_charge and
+// status, confirmed by default, for reservations.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/willsams/go-hotel-reservation-service/store"
+)
+
+const dateLayout = "2006-01-02"
+
+// Set is the rooms and reservations to load.
+type Set struct {
+	Rooms        []store.Room
+	Reservations []store.Reservation
+}
+
+// Error lists every problem found in a set.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid seed data:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+type room struct {
+	ID           string  `json:"id"`
+	NumBeds      int     `json:"num_beds"`
+	AllowSmoking bool    `json:"allow_smoking"`
+	DailyRate    float64 `json:"daily_rate"`
+	CleaningFee  float64 `json:"cleaning_fee"`
+}
+
+type reservation struct {
+	RoomID       string  `json:"room_id"`
+	CheckinDate  string  `json:"checkin_date"`
+	CheckoutDate string  `json:"checkout_date"`
+	TotalCharge  float64 `json:"total_charge"`
+	Status       string  `json:"status"`
+}
+
+// Read reads the set in dir of fsys.
+func Read(fsys fs.FS, dir string) (Set, error) {
+	var set Set
+
+	var rooms []room
+	found, err := readTable(fsys, path.Join(dir, "rooms"), &rooms, func(record map[string]string) (err error) {
+		r := room{ID: record["id"]}
+		if r.NumBeds, err = strconv.Atoi(record["num_beds"]); err != nil {
+			return err
+		}
+		if r.AllowSmoking, err = strconv.ParseBool(record["allow_smoking"]); err != nil {
+			return err
+		}
+		if r.DailyRate, err = strconv.ParseFloat(record["daily_rate"], 64); err != nil {
+			return err
+		}
+		if r.CleaningFee, err = strconv.ParseFloat(record["cleaning_fee"], 64); err != nil {
+			return err
+		}
+		rooms = append(rooms, r)
+		return nil
+	})
+	if err != nil {
+		return set, err
+	}
+	if !found {
+		return set, fmt.Errorf("%s: no rooms.json or rooms.csv", dir)
+	}
+	for _, r := range rooms {
+		set.Rooms = append(set.Rooms, store.Room{
+			ID:           r.ID,
+			NumBeds:      r.NumBeds,
+			AllowSmoking: r.AllowSmoking,
+			DailyRate:    r.DailyRate,
+			CleaningFee:  r.CleaningFee,
+		})
+	}
+
+	var reservations []reservation
+	_, err = readTable(fsys, path.Join(dir, "reservations"), &reservations, func(record map[string]string) (err error) {
+		r := reservation{
+			RoomID:       record["room_id"],
+			CheckinDate:  record["checkin_date"],
+			CheckoutDate: record["checkout_date"],
+			Status:       record["status"],
+		}
+		if r.TotalCharge, err = strconv.ParseFloat(record["total_charge"], 64); err != nil {
+			return err
+		}
+		reservations = append(reservations, r)
+		return nil
+	})
+	if err != nil {
+		return set, err
+	}
+	for _, r := range reservations {
+		status := r.Status
+		if status == "" {
+			status = store.ReservationConfirmed
+		}
+		set.Reservations = append(set.Reservations, store.Reservation{
+			RoomID:       r.RoomID,
+			CheckinDate:  r.CheckinDate,
+			CheckoutDate: r.CheckoutDate,
+			TotalCharge:  r.TotalCharge,
+			Status:       status,
+		})
+	}
+	return set, nil
+}
+
+// readTable decodes name.json into rows, or passes each record of name.csv,
+// keyed by the header, to add. It reports whether either file exists.
+func readTable(fsys fs.FS, name string, rows interface{}, add func(record map[string]string) error) (bool, error) {
+	data, err := fs.ReadFile(fsys, name+".json")
+	if err == nil {
+		if err := json.Unmarshal(data, rows); err != nil {
+			return true, fmt.Errorf("%s.json: %w", name, err)
+		}
+		return true, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	file, err := fsys.Open(name + ".csv")
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return true, fmt.Errorf("%s.csv: %w", name, err)
+	}
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return true, fmt.Errorf("%s.csv: %w", name, err)
+		}
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			record[strings.TrimSpace(column)] = strings.TrimSpace(values[i])
+		}
+		if err := add(record); err != nil {
+			return true, fmt.Errorf("%s.csv line %d: %w", name, line, err)
+		}
+	}
+}
+
+// Validate checks the set on its own: the rooms are unique, the
+// reservations are for rooms of the set, with valid dates and without
+// overlapping each other. Every problem found is reported in an *Error.
+func (s Set) Validate() error {
+	return s.validate(nil)
+}
+
+// validate is Validate, accepting reservations for the rooms of known too.
+func (s Set) validate(known map[string]bool) error {
+	var problems []string
+	rooms := map[string]bool{}
+	for i, r := range s.Rooms {
+		switch {
+		case r.ID == "":
+			problems = append(problems, fmt.Sprintf("room %d has no id", i+1))
+		case rooms[r.ID]:
+			problems = append(problems, fmt.Sprintf("room %s is listed twice", r.ID))
+		}
+		if r.NumBeds < 1 {
+			problems = append(problems, fmt.Sprintf("room %s has %d beds", r.ID, r.NumBeds))
+		}
+		if r.DailyRate < 0 || r.CleaningFee < 0 {
+			problems = append(problems, fmt.Sprintf("room %s has a negative rate or fee", r.ID))
+		}
+		if r.DailyRate != math.Trunc(r.DailyRate) || r.CleaningFee != math.Trunc(r.CleaningFee) {
+			problems = append(problems, fmt.Sprintf("room %s has a rate or fee that is not a whole amount", r.ID))
+		}
+		rooms[r.ID] = true
+	}
+
+	var valid []store.Reservation
+	for i, r := range s.Reservations {
+		name := fmt.Sprintf("reservation %d (room %s, %s to %s)", i+1, r.RoomID, r.CheckinDate, r.CheckoutDate)
+		ok := true
+		if !rooms[r.RoomID] && !known[r.RoomID] {
+			problems = append(problems, name+": unknown room")
+			ok = false
+		}
+		checkin, errIn := time.Parse(dateLayout, r.CheckinDate)
+		checkout, errOut := time.Parse(dateLayout, r.CheckoutDate)
+		switch {
+		case errIn != nil || errOut != nil:
+			problems = append(problems, name+": dates must be YYYY-MM-DD")
+			ok = false
+		case !checkout.After(checkin):
+			problems = append(problems, name+": checkout is not after checkin")
+			ok = false
+		}
+		if r.Status != store.ReservationConfirmed && r.Status != store.ReservationCancelled && r.Status != store.ReservationBlocked {
+			problems = append(problems, fmt.Sprintf("%s: status must be %s, %s or %s", name,
+				store.ReservationConfirmed, store.ReservationCancelled, store.ReservationBlocked))
+			ok = false
+		}
+		if r.TotalCharge < 0 {
+			problems = append(problems, name+": negative total charge")
+		}
+		if r.TotalCharge != math.Trunc(r.TotalCharge) {
+			problems = append(problems, name+": total charge is not a whole amount")
+		}
+		if !ok || r.Status == store.ReservationCancelled {
+			continue
+		}
+		for _, other := range valid {
+			if overlap(r, other) {
+				problems = append(problems, fmt.Sprintf("%s: overlaps %s to %s", name, other.CheckinDate, other.CheckoutDate))
+			}
+		}
+		valid = append(valid, r)
+	}
+
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+	return nil
+}
+
+// overlap reports whether two reservations of the same room share a night.
+// YYYY-MM-DD dates compare as strings.
+func overlap(a, b store.Reservation) bool {
+	return a.RoomID == b.RoomID && a.CheckinDate < b.CheckoutDate && a.CheckoutDate > b.CheckinDate
+}
+
+// Load validates set and stores it in repos. Rooms are created or updated;
+// reservations may be for rooms already stored, and must not overlap the
+// stored ones. A reservation already stored with the same room and dates
+// is left as it is, so loading a set twice loads it once. Nothing is
+// stored unless the whole set is valid.
+func Load(ctx context.Context, repos store.Repositories, set Set) error {
+	var missing []string
+	for _, r := range set.Reservations {
+		missing = append(missing, r.RoomID)
+	}
+	stored, err := repos.Rooms.Rooms(ctx, missing)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, r := range stored {
+		known[r.ID] = true
+	}
+	if err := set.validate(known); err != nil {
+		return err
+	}
+
+	var problems []string
+	var pending []store.Reservation
+	for i, r := range set.Reservations {
+		existing, err := repos.Reservations.ListReservations(ctx, store.ReservationQuery{
+			ReservationFilter: store.ReservationFilter{
+				RoomIDs:      []string{r.RoomID},
+				OverlapStart: r.CheckinDate,
+				OverlapEnd:   r.CheckoutDate,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		seeded := false
+		for _, e := range existing {
+			switch {
+			case e.CheckinDate == r.CheckinDate && e.CheckoutDate == r.CheckoutDate:
+				seeded = true
+			case e.Status != store.ReservationCancelled && r.Status != store.ReservationCancelled:
+				problems = append(problems, fmt.Sprintf("reservation %d (room %s, %s to %s): overlaps stored reservation %s",
+					i+1, r.RoomID, r.CheckinDate, r.CheckoutDate, e.ID))
+			}
+		}
+		if !seeded {
+			pending = append(pending, r)
+		}
+	}
+	if len(problems) > 0 {
+		return &Error{Problems: problems}
+	}
+
+	for _, r := range set.Rooms {
+		if err := repos.Rooms.SaveRoom(ctx, r); err != nil {
+			return fmt.Errorf("room %s: %w", r.ID, err)
+		}
+	}
+	for _, r := range pending {
+		if _, err := repos.Reservations.CreateReservation(ctx, r); err != nil {
+			return fmt.Errorf("reservation for room %s from %s to %s: %w", r.RoomID, r.CheckinDate, r.CheckoutDate, err)
+		}
+	}
+	return nil
+}
+
+// Write writes set to dir, creating it if needed, as rooms and
+// reservations files in format, json or csv, that Read reads back.
+func Write(dir string, set Set, format string) error {
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("format must be json or csv, not %q", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	rooms := make([]room, len(set.Rooms))
+	roomRecords := [][]string{{"id", "num_beds", "allow_smoking", "daily_rate", "cleaning_fee"}}
+	for i, r := range set.Rooms {
+		rooms[i] = room{ID: r.ID, NumBeds: r.NumBeds, AllowSmoking: r.AllowSmoking, DailyRate: r.DailyRate, CleaningFee: r.CleaningFee}
+		roomRecords = append(roomRecords, []string{r.ID, strconv.Itoa(r.NumBeds), strconv.FormatBool(r.AllowSmoking),
+			formatAmount(r.DailyRate), formatAmount(r.CleaningFee)})
+	}
+	reservations := make([]reservation, len(set.Reservations))
+	reservationRecords := [][]string{{"room_id", "checkin_date", "checkout_date", "total_charge", "status"}}
+	for i, r := range set.Reservations {
+		reservations[i] = reservation{RoomID: r.RoomID, CheckinDate: r.CheckinDate, CheckoutDate: r.CheckoutDate, TotalCharge: r.TotalCharge, Status: r.Status}
+		reservationRecords = append(reservationRecords, []string{r.RoomID, r.CheckinDate, r.CheckoutDate,
+			formatAmount(r.TotalCharge), r.Status})
+	}
+
+	if format == "json" {
+		if err := writeJSON(filepath.Join(dir, "rooms.json"), rooms); err != nil {
+			return err
+		}
+		return writeJSON(filepath.Join(dir, "reservations.json"), reservations)
+	}
+	if err := writeCSV(filepath.Join(dir, "rooms.csv"), roomRecords); err != nil {
+		return err
+	}
+	return writeCSV(filepath.Join(dir, "reservations.csv"), reservationRecords)
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+func writeJSON(name string, rows interface{}) error {
+	data, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+func writeCSV(name string, records [][]string) error {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buffer.Bytes(), 0o644)
+}
@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+// TakeToken implements ratelimit.Store with a single upsert, so that the
+// processes sharing the file take from the same buckets. Times are stored
+// as Unix seconds; a clock behind the one that last refilled a bucket adds
+// nothing to it.
+func (s *Store) TakeToken(ctx context.Context, key string, rate float64, burst float64, now time.Time) (float64, bool, error) {
+	var bucket struct {
+		Tokens float64 `db:"tokens"`
+		Taken  bool    `db:"taken"`
+	}
+	err := s.db.GetContext(ctx, &bucket, `
+		INSERT INTO rate_limit_buckets (bucket, tokens, updated_at, taken)
+		VALUES (?1, ?2 - 1, ?3, 1)
+		ON CONFLICT (bucket) DO UPDATE SET
+			tokens = min(?2, tokens + max(0, ?3 - updated_at) * ?4)
+				- (min(?2, tokens + max(0, ?3 - updated_at) * ?4) >= 1),
+			taken = min(?2, tokens + max(0, ?3 - updated_at) * ?4) >= 1,
+			updated_at = max(updated_at, ?3)
+		RETURNING tokens, taken
+	`, key, burst, unixSeconds(now), rate)
+	return bucket.Tokens, bucket.Taken, err
+}
+
+// SweepBuckets implements ratelimit.Sweeper.
+func (s *Store) SweepBuckets(ctx context.Context, idleSince time.Time) error {
+	_, err := s.db.ExecContext(ctx, "delete from rate_limit_buckets where updated_at < ?", unixSeconds(idleSince))
+	return err
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
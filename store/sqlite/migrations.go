@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/store/migrate"
+)
+
+// migrations hold the schema as VERSION_name.up.sql and .down.sql files,
+// with the versions of the Postgres migrations.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// numberedVersions are the versions the first migrations of the store were
+// numbered with, before they took those of the Postgres migrations.
+var numberedVersions = map[int64]int64{
+	1: 20230213225130,
+	2: 20261019090000,
+}
+
+// Migrator migrates the file of the store; Open already brings it up to date.
+// A file migrated when the migrations were numbered is adopted: the versions
+// recorded then count as applied under their current numbers.
+func (s *Store) Migrator() *migrate.Migrator {
+	m := migrate.New(s.db.DB, migrate.MustLoad(migrations, "migrations"))
+	m.Adopt = adoptNumbered
+	return m
+}
+
+// adoptNumbered returns the current versions of the numbered versions
+// recorded in schema_migrations, and clears those.
+func adoptNumbered(ctx context.Context, tx *sqlx.Tx) ([]int64, error) {
+	var recorded []int64
+	if err := tx.SelectContext(ctx, &recorded, "SELECT version FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	var versions []int64
+	for _, version := range recorded {
+		if current, ok := numberedVersions[version]; ok {
+			versions = append(versions, current)
+			if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,16 @@
+CREATE TABLE rooms (
+	id varchar(255) PRIMARY KEY,
+	num_beds integer,
+	allow_smoking boolean,
+	daily_rate integer,
+	cleaning_fee integer
+);
+
+CREATE TABLE reservations (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	room_id varchar(255) REFERENCES rooms (id),
+	checkin_date varchar(255),
+	checkout_date varchar(255),
+	total_charge integer,
+	UNIQUE (room_id, checkin_date, checkout_date)
+);
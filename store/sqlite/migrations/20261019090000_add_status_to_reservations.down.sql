@@ -0,0 +1,3 @@
+DROP INDEX reservations_checkin_date_id_index;
+
+ALTER TABLE reservations DROP COLUMN status;
@@ -0,0 +1,3 @@
+ALTER TABLE reservations ADD COLUMN status varchar(255) NOT NULL DEFAULT 'confirmed';
+
+CREATE INDEX reservations_checkin_date_id_index ON reservations (checkin_date, id);
@@ -0,0 +1,8 @@
+CREATE TABLE rate_limit_buckets (
+	bucket text PRIMARY KEY,
+	tokens real NOT NULL,
+	updated_at real NOT NULL,
+	taken boolean NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_index ON rate_limit_buckets (updated_at);
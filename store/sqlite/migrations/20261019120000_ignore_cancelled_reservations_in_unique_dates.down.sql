@@ -0,0 +1,21 @@
+CREATE TABLE reservations_old (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	room_id varchar(255) REFERENCES rooms (id),
+	checkin_date varchar(255),
+	checkout_date varchar(255),
+	total_charge integer,
+	status varchar(255) NOT NULL DEFAULT 'confirmed',
+	guest_id varchar(255) NOT NULL DEFAULT '',
+	UNIQUE (room_id, checkin_date, checkout_date)
+);
+
+INSERT INTO reservations_old (id, room_id, checkin_date, checkout_date, total_charge, status, guest_id)
+	SELECT id, room_id, checkin_date, checkout_date, total_charge, status, guest_id FROM reservations;
+
+DROP TABLE reservations;
+
+ALTER TABLE reservations_old RENAME TO reservations;
+
+CREATE INDEX reservations_checkin_date_id_index ON reservations (checkin_date, id);
+
+CREATE INDEX reservations_guest_id_index ON reservations (guest_id);
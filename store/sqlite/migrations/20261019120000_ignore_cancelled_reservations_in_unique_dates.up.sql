@@ -0,0 +1,25 @@
+-- SQLite cannot drop the UNIQUE constraint of a table, so the table is
+-- rebuilt without it.
+CREATE TABLE reservations_new (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	room_id varchar(255) REFERENCES rooms (id),
+	checkin_date varchar(255),
+	checkout_date varchar(255),
+	total_charge integer,
+	status varchar(255) NOT NULL DEFAULT 'confirmed',
+	guest_id varchar(255) NOT NULL DEFAULT ''
+);
+
+INSERT INTO reservations_new (id, room_id, checkin_date, checkout_date, total_charge, status, guest_id)
+	SELECT id, room_id, checkin_date, checkout_date, total_charge, status, guest_id FROM reservations;
+
+DROP TABLE reservations;
+
+ALTER TABLE reservations_new RENAME TO reservations;
+
+CREATE INDEX reservations_checkin_date_id_index ON reservations (checkin_date, id);
+
+CREATE INDEX reservations_guest_id_index ON reservations (guest_id);
+
+CREATE UNIQUE INDEX reservations_room_id_checkin_date_checkout_date_unique
+	ON reservations (room_id, checkin_date, checkout_date) WHERE status <> 'cancelled';
@@ -0,0 +1,287 @@
+// Package sqlite stores rooms and reservations in a SQLite file, for
+// single-property and offline deployments. It needs cgo.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+	"github.com/willsams/go-hotel-reservation-service/store"
+	"github.com/willsams/go-hotel-reservation-service/store/instrumented"
+)
+
+// Store implements the room and reservation repositories on a SQLite file.
+type Store struct {
+	db instrumented.DB
+}
+
+// Open opens the SQLite file at cfg.Path, creating it if needed, and
+// migrates it to the schema of this binary.
+func Open(cfg config.Database) (*Store, error) {
+	s, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Migrator().Up(context.Background()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("migrating %s: %w", cfg.Path, err)
+	}
+	return s, nil
+}
+
+// Connect opens the SQLite file at cfg.Path, creating it if needed, but
+// leaves its schema as it is. The pool settings of cfg do not apply:
+// SQLite allows a single writer, so the store keeps a single connection,
+// which also keeps a ":memory:" database alive.
+func Connect(cfg config.Database) (*Store, error) {
+	db, err := sqlx.Open("sqlite3", "file:"+cfg.Path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return &Store{db: instrumented.New(db)}, nil
+}
+
+// DB is the connection pool of the store.
+func (s *Store) DB() *sqlx.DB {
+	return s.db.DB
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Repositories returns the store as both repositories.
+func (s *Store) Repositories() store.Repositories {
+	return store.Repositories{Rooms: s, Reservations: s}
+}
+
+func (s *Store) AvailableRooms(ctx context.Context, query store.AvailabilityQuery) ([]store.Room, error) {
+	var rooms []store.Room
+	err := s.db.SelectContext(ctx, &rooms, `select ro.id, ro.num_beds, ro.allow_smoking, ro.daily_rate, ro.cleaning_fee,
+			ro.daily_rate * ? + ro.cleaning_fee as total_charge
+		from rooms as ro
+		where ro.allow_smoking = ?
+			and ro.num_beds >= ?
+			and ro.id not in (
+				select room_id
+				from reservations
+				where status <> 'cancelled'
+					and ? between checkin_date and checkout_date
+					and ? between checkin_date and checkout_date
+			)
+		order by 6, 2, 1`, query.Nights, query.AllowSmoking, query.NumBeds, query.End, query.Start)
+	return rooms, err
+}
+
+func (s *Store) Rooms(ctx context.Context, ids []string) ([]store.Room, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	statement, args, err := sqlx.In(`select id, num_beds, allow_smoking, daily_rate, cleaning_fee
+		from rooms
+		where id in (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var rooms []store.Room
+	err = s.db.SelectContext(ctx, &rooms, statement, args...)
+	return rooms, err
+}
+
+func (s *Store) ListRooms(ctx context.Context) ([]store.Room, error) {
+	var rooms []store.Room
+	err := s.db.SelectContext(ctx, &rooms, `select id, num_beds, allow_smoking, daily_rate, cleaning_fee
+		from rooms
+		order by id`)
+	return rooms, err
+}
+
+func (s *Store) SaveRoom(ctx context.Context, room store.Room) error {
+	_, err := s.db.ExecContext(ctx, `insert into rooms (id, num_beds, allow_smoking, daily_rate, cleaning_fee)
+		values (?, ?, ?, ?, ?)
+		on conflict (id) do update
+		set num_beds = excluded.num_beds, allow_smoking = excluded.allow_smoking,
+			daily_rate = excluded.daily_rate, cleaning_fee = excluded.cleaning_fee`,
+		room.ID, room.NumBeds, room.AllowSmoking, room.DailyRate, room.CleaningFee)
+	return err
+}
+
+func (s *Store) Reservation(ctx context.Context, id string) (store.Reservation, error) {
+	var reservation store.Reservation
+	err := s.db.GetContext(ctx, &reservation, `select id, room_id, checkin_date, checkout_date, total_charge, status, guest_id
+		from reservations
+		where id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return reservation, fmt.Errorf("%w: reservation %s", store.ErrNotFound, id)
+	}
+	return reservation, err
+}
+
+// sortColumns maps the sortable fields to their columns.
+var sortColumns = map[string]string{
+	store.SortByID:           "id",
+	store.SortByCheckinDate:  "checkin_date",
+	store.SortByCheckoutDate: "checkout_date",
+	store.SortByTotalCharge:  "total_charge",
+}
+
+// where renders the filter as a SQL condition, appending its parameters to args.
+func where(filter store.ReservationFilter, args []interface{}) (string, []interface{}) {
+	conditions := []string{"true"}
+	if len(filter.RoomIDs) > 0 {
+		conditions = append(conditions, "room_id in ("+placeholders(len(filter.RoomIDs))+")")
+		for _, id := range filter.RoomIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.OverlapStart != "" && filter.OverlapEnd != "" {
+		conditions = append(conditions, "checkin_date < ? and checkout_date > ?")
+		args = append(args, filter.OverlapEnd, filter.OverlapStart)
+	}
+	if len(filter.Statuses) > 0 {
+		conditions = append(conditions, "status in ("+placeholders(len(filter.Statuses))+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.GuestID != "" {
+		conditions = append(conditions, "guest_id = ?")
+		args = append(args, filter.GuestID)
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *Store) ListReservations(ctx context.Context, query store.ReservationQuery) ([]store.Reservation, error) {
+	condition, args := where(query.ReservationFilter, nil)
+
+	column, ok := sortColumns[query.SortField]
+	if !ok {
+		column = "id"
+	}
+	direction, comparison := "asc", ">"
+	if query.Descending {
+		direction, comparison = "desc", "<"
+	}
+
+	// Keyset pagination: continue strictly after the (sort value, id) of the position.
+	if query.After != nil {
+		if column == "id" {
+			condition += fmt.Sprintf(" and id %s ?", comparison)
+			args = append(args, query.After.ID)
+		} else {
+			condition += fmt.Sprintf(" and (%s, id) %s (?, ?)", column, comparison)
+			args = append(args, query.After.Value, query.After.ID)
+		}
+	}
+
+	order := "id " + direction
+	if column != "id" {
+		order = fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	}
+
+	statement := fmt.Sprintf(`select id, room_id, checkin_date, checkout_date, total_charge, status, guest_id
+		from reservations
+		where %s
+		order by %s`, condition, order)
+	if query.Limit > 0 {
+		statement += " limit ?"
+		args = append(args, query.Limit)
+	}
+
+	var reservations []store.Reservation
+	err := s.db.SelectContext(ctx, &reservations, statement, args...)
+	return reservations, err
+}
+
+func (s *Store) CountReservations(ctx context.Context, filter store.ReservationFilter) (int, error) {
+	condition, args := where(filter, nil)
+	var count int
+	err := s.db.GetContext(ctx, &count, "select count(*) from reservations where "+condition, args...)
+	return count, err
+}
+
+func (s *Store) IsRoomAvailable(ctx context.Context, roomID string, checkinDate string, checkoutDate string, exceptID string) (bool, error) {
+	var count int
+	err := s.db.GetContext(ctx, &count, `
+		SELECT COUNT(*)
+		FROM reservations
+		WHERE room_id = ?1
+		AND status <> 'cancelled'
+		AND CAST(id AS TEXT) <> ?4
+		AND (
+			(checkin_date >= ?2 AND checkin_date < ?3) OR
+			(checkout_date > ?2 AND checkout_date <= ?3) OR
+			(checkin_date <= ?2 AND checkout_date >= ?3)
+		)
+	`, roomID, checkinDate, checkoutDate, exceptID)
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+func (s *Store) CreateReservation(ctx context.Context, reservation store.Reservation) (store.Reservation, error) {
+	if reservation.Status == "" {
+		reservation.Status = store.ReservationConfirmed
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO reservations (room_id, checkin_date, checkout_date, total_charge, status, guest_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate, reservation.TotalCharge, reservation.Status, reservation.GuestID)
+	if err != nil {
+		return reservation, constraintError(err, reservation)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return reservation, err
+	}
+	reservation.ID = fmt.Sprint(id)
+	return reservation, nil
+}
+
+func (s *Store) UpdateReservation(ctx context.Context, reservation store.Reservation) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE reservations
+		SET room_id = ?2, checkin_date = ?3, checkout_date = ?4, total_charge = ?5, status = ?6, guest_id = ?7
+		WHERE id = ?1
+	`, reservation.ID, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate, reservation.TotalCharge, reservation.Status, reservation.GuestID)
+	if err != nil {
+		return constraintError(err, reservation)
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return fmt.Errorf("%w: reservation %s", store.ErrNotFound, reservation.ID)
+	}
+	return nil
+}
+
+// constraintError turns the violations of the reservations constraints into
+// the errors of the repository.
+func constraintError(err error, reservation store.Reservation) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: room %s", store.ErrNotFound, reservation.RoomID)
+	case sqlite3.ErrConstraintUnique:
+		return fmt.Errorf("%w: room %s is already reserved from %s to %s",
+			store.ErrConflict, reservation.RoomID, reservation.CheckinDate, reservation.CheckoutDate)
+	}
+	return err
+}
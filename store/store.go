@@ -0,0 +1,155 @@
+// Package store defines the storage the reservation service runs on. The
+// postgres and memory packages implement it with the same semantics.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/willsams/go-hotel-reservation-service/store/migrate"
+)
+
+const (
+	ReservationConfirmed = "confirmed"
+	ReservationCancelled = "cancelled"
+	// ReservationBlocked holds a room out of service, unpriced, like a
+	// confirmed reservation holds it for a guest.
+	ReservationBlocked = "blocked"
+)
+
+var (
+	// ErrNotFound is returned for a reservation or room that does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write collides with existing data.
+	ErrConflict = errors.New("conflict")
+)
+
+type Room struct {
+	ID           string  `db:"id" json:"id"`
+	NumBeds      int     `db:"num_beds" json:"numBeds"`
+	AllowSmoking bool    `db:"allow_smoking" json:"allowSmoking"`
+	DailyRate    float64 `db:"daily_rate" json:"dailyRate"`
+	CleaningFee  float64 `db:"cleaning_fee" json:"cleaningFee"`
+	TotalCharge  float64 `db:"total_charge" json:"totalCharge,omitempty"`
+}
+
+type Reservation struct {
+	ID           string  `db:"id" json:"id"`
+	RoomID       string  `db:"room_id" json:"roomId"`
+	CheckinDate  string  `db:"checkin_date" json:"checkinDate"`
+	CheckoutDate string  `db:"checkout_date" json:"checkoutDate"`
+	TotalCharge  float64 `db:"total_charge" json:"totalCharge"`
+	Status       string  `db:"status" json:"status"`
+	// GuestID is the subject of the principal that made the reservation,
+	// empty for those made without credentials.
+	GuestID string `db:"guest_id" json:"guestId,omitempty"`
+}
+
+// AvailabilityQuery asks for rooms free for a stay. Dates are YYYY-MM-DD.
+type AvailabilityQuery struct {
+	Start        string
+	End          string
+	Nights       int
+	NumBeds      int
+	AllowSmoking bool
+}
+
+// ReservationFilter narrows a listing of reservations. Empty fields match
+// every reservation.
+type ReservationFilter struct {
+	RoomIDs []string
+	// OverlapStart and OverlapEnd, given together, keep the reservations
+	// with at least one night between them.
+	OverlapStart string
+	OverlapEnd   string
+	Statuses     []string
+	// GuestID keeps the reservations of one guest.
+	GuestID string
+}
+
+// Sortable reservation fields.
+const (
+	SortByID           = "id"
+	SortByCheckinDate  = "checkin_date"
+	SortByCheckoutDate = "checkout_date"
+	SortByTotalCharge  = "total_charge"
+)
+
+// ReservationQuery is a filtered, sorted page of reservations. Ties on the
+// sort field are broken by id in the same direction.
+type ReservationQuery struct {
+	ReservationFilter
+	SortField  string
+	Descending bool
+	// After continues the listing strictly after this position.
+	After *Position
+	// Limit caps the number of reservations returned; 0 returns them all.
+	Limit int
+}
+
+// Position is the place of a reservation in a sorted listing: the value of
+// its sort field and its id.
+type Position struct {
+	Value string
+	ID    string
+}
+
+type RoomRepository interface {
+	// AvailableRooms returns the rooms matching the query that are free for
+	// the stay, priced for it and cheapest first.
+	AvailableRooms(ctx context.Context, query AvailabilityQuery) ([]Room, error)
+	// Rooms returns the rooms with the given ids, skipping unknown ones.
+	Rooms(ctx context.Context, ids []string) ([]Room, error)
+	// ListRooms returns every room, by id.
+	ListRooms(ctx context.Context) ([]Room, error)
+	// SaveRoom creates the room or replaces the one with its id.
+	SaveRoom(ctx context.Context, room Room) error
+}
+
+type ReservationRepository interface {
+	// Reservation returns the reservation with id, or ErrNotFound.
+	Reservation(ctx context.Context, id string) (Reservation, error)
+	ListReservations(ctx context.Context, query ReservationQuery) ([]Reservation, error)
+	CountReservations(ctx context.Context, filter ReservationFilter) (int, error)
+	// IsRoomAvailable reports whether no confirmed reservation other than
+	// exceptID holds the room for any night of the stay.
+	IsRoomAvailable(ctx context.Context, roomID string, checkinDate string, checkoutDate string, exceptID string) (bool, error)
+	// CreateReservation stores a new reservation and returns it with its id.
+	// It returns ErrNotFound for an unknown room and ErrConflict when the
+	// room already has a reservation for exactly the same dates.
+	CreateReservation(ctx context.Context, reservation Reservation) (Reservation, error)
+	// UpdateReservation overwrites the reservation with the same id. It
+	// returns ErrNotFound when there is none, and otherwise the errors of
+	// CreateReservation.
+	UpdateReservation(ctx context.Context, reservation Reservation) error
+}
+
+// Repositories are the storage a service instance runs on.
+type Repositories struct {
+	Rooms        RoomRepository
+	Reservations ReservationRepository
+}
+
+// Backend is an open store, closed once the process is done with it.
+type Backend interface {
+	Repositories() Repositories
+	Migrator() *migrate.Migrator
+	Close() error
+}
+
+// Pinger is implemented by repositories whose connection can go stale.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Pooled is implemented by repositories on a database connection pool.
+type Pooled interface {
+	DB() *sqlx.DB
+}
+
+// Migrated is implemented by repositories on a schema that is migrated.
+type Migrated interface {
+	Migrator() *migrate.Migrator
+}
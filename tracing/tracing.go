@@ -0,0 +1,87 @@
+// Package tracing sets up the OpenTelemetry spans of the service: where
+// they are exported and how trace context travels in request headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/willsams/go-hotel-reservation-service/config"
+)
+
+// ServiceName names the service in the exported spans.
+const ServiceName = "hotel-reservation-service"
+
+const instrumentationName = "github.com/willsams/go-hotel-reservation-service"
+
+// Tracer starts the spans of the service. Until Setup installs an exporter,
+// its spans are not recorded but still carry the incoming trace context.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the W3C trace context and baggage propagators and the
+// exporter of cfg. The returned function flushes the spans not exported
+// yet and stops the exporter; call it before the process exits.
+func Setup(ctx context.Context, cfg config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Tracing.Exporter {
+	case config.TracingStdout:
+		exporter, err = stdouttrace.New()
+	case config.TracingOTLP:
+		exporter, err = newOTLPExporter(ctx, cfg.Tracing.Endpoint)
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(ServiceName),
+			semconv.DeploymentEnvironment(cfg.Env),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newOTLPExporter sends spans to the OTLP/HTTP collector at endpoint, such
+// as http://localhost:4318.
+func newOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("tracing endpoint: %w", err)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(parsed.Host)}
+	if parsed.Scheme == "http" {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if parsed.Path != "" && parsed.Path != "/" {
+		options = append(options, otlptracehttp.WithURLPath(parsed.Path))
+	}
+	return otlptracehttp.New(ctx, options...)
+}
+
+// Flush exports the spans ended so far. Lambda calls it at the end of each
+// invocation, since the environment may be frozen before the batch is due.
+func Flush(ctx context.Context) {
+	if provider, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		provider.ForceFlush(ctx)
+	}
+}